@@ -11,6 +11,9 @@ import (
 
 	"github.com/flant/shell-operator/pkg/app"
 	"github.com/flant/shell-operator/pkg/debug"
+	"github.com/flant/shell-operator/pkg/executor"
+	"github.com/flant/shell-operator/pkg/hook/context_generator"
+	"github.com/flant/shell-operator/pkg/hook/validate"
 	shell_operator "github.com/flant/shell-operator/pkg/shell-operator"
 	utils_signal "github.com/flant/shell-operator/pkg/utils/signal"
 )
@@ -42,6 +45,9 @@ func main() {
 			// Init rand generator.
 			rand.Seed(time.Now().UnixNano())
 
+			// Reap orphaned hook processes if shell-operator is PID 1 in the container.
+			executor.StartReaper()
+
 			// Init logging and initialize a ShellOperator instance.
 			operator, err := shell_operator.Init()
 			if err != nil {
@@ -61,6 +67,9 @@ func main() {
 
 	debug.DefineDebugCommands(kpApp)
 	debug.DefineDebugCommandsSelf(kpApp)
+	validate.DefineValidateCommand(kpApp.GetCommand("hook"))
+	shell_operator.DefineHookRunCommand(kpApp.GetCommand("hook"))
+	context_generator.DefineGenerateContextCommand(kpApp.GetCommand("hook"))
 
 	kingpin.MustParse(kpApp.Parse(os.Args[1:]))
 }
@@ -135,6 +135,7 @@ func DefineStartCommandFlags(kpApp *kingpin.Application, cmd *kingpin.CmdClause)
 	}
 
 	DefineKubeClientFlags(cmd)
+	DefineHookFlags(cmd)
 	DefineValidatingWebhookFlags(cmd)
 	DefineConversionWebhookFlags(cmd)
 	DefineJqFlags(cmd)
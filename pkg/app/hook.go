@@ -0,0 +1,103 @@
+package app
+
+import (
+	"strconv"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// HookTerminationGracePeriod is a delay between SIGTERM and SIGKILL for hooks exceeded the execution timeout.
+var HookTerminationGracePeriod = 10 * time.Second
+
+// HookEnvAllowlist and HookEnvDenylist are comma-separated lists of environment
+// variables of Shell-operator passed to hooks. Patterns with '*' are allowed.
+var HookEnvAllowlist = ""
+var HookEnvDenylist = ""
+
+// HookOutputTailSize is a size of stdout and stderr tails kept for the last hook run.
+var HookOutputTailSize = 4096
+
+// Maximum sizes of hook responses and log lines in bytes.
+var HookMaxMetricsSize = 16 * 1024 * 1024
+var HookMaxKubernetesPatchSize = 64 * 1024 * 1024
+var HookMaxValidatingResponseSize = 1024 * 1024
+var HookMaxConversionResponseSize = 64 * 1024 * 1024
+var HookMaxLogLineSize = 1024 * 1024
+
+// HooksReload enables reloading of hooks on changes in the hooks directory.
+var HooksReload = false
+
+// HooksReloadScanPeriod is a period of checksum scans of the hooks directory.
+// The scan is a fallback for filesystems without change notifications.
+var HooksReloadScanPeriod = time.Minute
+
+// HooksConfigLoadParallelism is a number of hooks executed with --config at the same time.
+var HooksConfigLoadParallelism = 4
+
+// Policies to handle hooks with invalid configurations.
+const (
+	// HooksLoadPolicyStrict stops Shell-operator if some hook has an invalid configuration.
+	HooksLoadPolicyStrict = "strict"
+	// HooksLoadPolicySkipInvalid quarantines hooks with invalid configurations and runs the rest.
+	HooksLoadPolicySkipInvalid = "skip-invalid"
+)
+
+// HooksLoadPolicy defines what to do with hooks with invalid configurations.
+var HooksLoadPolicy = HooksLoadPolicyStrict
+
+// DefineHookFlags defines flags for hook execution.
+func DefineHookFlags(cmd *kingpin.CmdClause) {
+	cmd.Flag("hooks-load-policy", "What to do with hooks with invalid configurations: 'strict' to exit with error or 'skip-invalid' to quarantine such hooks and run the rest. Can be set with $SHELL_OPERATOR_HOOKS_LOAD_POLICY.").
+		Envar("SHELL_OPERATOR_HOOKS_LOAD_POLICY").
+		Default(HooksLoadPolicy).
+		EnumVar(&HooksLoadPolicy, HooksLoadPolicyStrict, HooksLoadPolicySkipInvalid)
+	cmd.Flag("hooks-config-load-parallelism", "A number of hooks executed with --config at the same time to load their configurations. Can be set with $SHELL_OPERATOR_HOOKS_CONFIG_LOAD_PARALLELISM.").
+		Envar("SHELL_OPERATOR_HOOKS_CONFIG_LOAD_PARALLELISM").
+		Default(strconv.Itoa(HooksConfigLoadParallelism)).
+		IntVar(&HooksConfigLoadParallelism)
+	cmd.Flag("hooks-reload", "Watch the hooks directory and reload added, removed and changed hooks without restart. Can be set with $SHELL_OPERATOR_HOOKS_RELOAD.").
+		Envar("SHELL_OPERATOR_HOOKS_RELOAD").
+		Default(strconv.FormatBool(HooksReload)).
+		BoolVar(&HooksReload)
+	cmd.Flag("hooks-reload-scan-period", "A period to scan the hooks directory for changes if --hooks-reload is enabled. Set to 0 to rely on file events only. Can be set with $SHELL_OPERATOR_HOOKS_RELOAD_SCAN_PERIOD.").
+		Envar("SHELL_OPERATOR_HOOKS_RELOAD_SCAN_PERIOD").
+		Default(HooksReloadScanPeriod.String()).
+		DurationVar(&HooksReloadScanPeriod)
+	cmd.Flag("hook-termination-grace-period", "A delay between SIGTERM and SIGKILL sent to a hook that exceeds its executionTimeout. Can be set with $SHELL_OPERATOR_HOOK_TERMINATION_GRACE_PERIOD.").
+		Envar("SHELL_OPERATOR_HOOK_TERMINATION_GRACE_PERIOD").
+		Default(HookTerminationGracePeriod.String()).
+		DurationVar(&HookTerminationGracePeriod)
+	cmd.Flag("hook-env-allowlist", "A comma-separated list of Shell-operator's environment variables passed to hooks, e.g. 'PATH,HOME,KUBERNETES_*'. All variables are passed if empty. Can be set with $SHELL_OPERATOR_HOOK_ENV_ALLOWLIST.").
+		Envar("SHELL_OPERATOR_HOOK_ENV_ALLOWLIST").
+		Default(HookEnvAllowlist).
+		StringVar(&HookEnvAllowlist)
+	cmd.Flag("hook-env-denylist", "A comma-separated list of Shell-operator's environment variables that are not passed to hooks, e.g. 'AWS_*,HTTPS_PROXY'. Can be set with $SHELL_OPERATOR_HOOK_ENV_DENYLIST.").
+		Envar("SHELL_OPERATOR_HOOK_ENV_DENYLIST").
+		Default(HookEnvDenylist).
+		StringVar(&HookEnvDenylist)
+	cmd.Flag("hook-max-metrics-size", "A maximum size of metrics written by a hook in bytes. Can be set with $SHELL_OPERATOR_HOOK_MAX_METRICS_SIZE.").
+		Envar("SHELL_OPERATOR_HOOK_MAX_METRICS_SIZE").
+		Default(strconv.Itoa(HookMaxMetricsSize)).
+		IntVar(&HookMaxMetricsSize)
+	cmd.Flag("hook-max-kubernetes-patch-size", "A maximum size of Kubernetes patch operations written by a hook in bytes. Can be set with $SHELL_OPERATOR_HOOK_MAX_KUBERNETES_PATCH_SIZE.").
+		Envar("SHELL_OPERATOR_HOOK_MAX_KUBERNETES_PATCH_SIZE").
+		Default(strconv.Itoa(HookMaxKubernetesPatchSize)).
+		IntVar(&HookMaxKubernetesPatchSize)
+	cmd.Flag("hook-max-validating-response-size", "A maximum size of a validating response written by a hook in bytes. Can be set with $SHELL_OPERATOR_HOOK_MAX_VALIDATING_RESPONSE_SIZE.").
+		Envar("SHELL_OPERATOR_HOOK_MAX_VALIDATING_RESPONSE_SIZE").
+		Default(strconv.Itoa(HookMaxValidatingResponseSize)).
+		IntVar(&HookMaxValidatingResponseSize)
+	cmd.Flag("hook-max-conversion-response-size", "A maximum size of a conversion response written by a hook in bytes. Can be set with $SHELL_OPERATOR_HOOK_MAX_CONVERSION_RESPONSE_SIZE.").
+		Envar("SHELL_OPERATOR_HOOK_MAX_CONVERSION_RESPONSE_SIZE").
+		Default(strconv.Itoa(HookMaxConversionResponseSize)).
+		IntVar(&HookMaxConversionResponseSize)
+	cmd.Flag("hook-max-log-line-size", "A maximum length of a line in hook's stdout and stderr in bytes. Longer lines are truncated and the hook run fails. Can be set with $SHELL_OPERATOR_HOOK_MAX_LOG_LINE_SIZE.").
+		Envar("SHELL_OPERATOR_HOOK_MAX_LOG_LINE_SIZE").
+		Default(strconv.Itoa(HookMaxLogLineSize)).
+		IntVar(&HookMaxLogLineSize)
+	cmd.Flag("hook-output-tail-size", "A number of last bytes of hook's stdout and stderr to keep for failure messages and the debug server. Can be set with $SHELL_OPERATOR_HOOK_OUTPUT_TAIL_SIZE.").
+		Envar("SHELL_OPERATOR_HOOK_OUTPUT_TAIL_SIZE").
+		Default(strconv.Itoa(HookOutputTailSize)).
+		IntVar(&HookOutputTailSize)
+}
@@ -15,6 +15,9 @@ var KubeClientQps float32
 var KubeClientBurstDefault = "10" // DefaultBurst from k8s.io/client-go/rest/config.go
 var KubeClientBurst int
 
+var KubeListPageSizeDefault = "500" // A page size of client-go's reflector.
+var KubeListPageSize int64
+
 var ObjectPatcherKubeClientQpsDefault = "5" // DefaultQPS from k8s.io/client-go/rest/config.go
 var ObjectPatcherKubeClientQps float32
 var ObjectPatcherKubeClientBurstDefault = "10" // DefaultBurst from k8s.io/client-go/rest/config.go
@@ -46,6 +49,10 @@ func DefineKubeClientFlags(cmd *kingpin.CmdClause) {
 		Envar("KUBE_CLIENT_BURST").
 		Default(KubeClientBurstDefault).
 		IntVar(&KubeClientBurst)
+	cmd.Flag("kube-list-page-size", "A limit for requests of the initial LIST of objects for kubernetes bindings. 0 disables pagination. Can be set with $KUBE_LIST_PAGE_SIZE.").
+		Envar("KUBE_LIST_PAGE_SIZE").
+		Default(KubeListPageSizeDefault).
+		Int64Var(&KubeListPageSize)
 
 	// Settings for 'object_patcher' kube client
 	cmd.Flag("object-patcher-kube-client-qps", "QPS for a rate limiter of a Kubernetes client for Object patcher. Can be set with $OBJECT_PATCHER_KUBE_CLIENT_QPS.").
@@ -102,6 +102,19 @@ func DefineDebugCommandsSelf(kpApp *kingpin.Application) {
 	AddOutputJsonYamlTextFlag(hookListCmd)
 	app.DefineDebugUnixSocketFlag(hookListCmd)
 
+	// Get hooks with invalid and disabled hooks
+	hookStatusCmd := hookCmd.Command("status", "List all hooks, disabled hooks and invalid hooks with errors.").
+		Action(func(c *kingpin.ParseContext) error {
+			outBytes, err := Hook(DefaultClient()).Status(OutputFormat)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(outBytes))
+			return nil
+		})
+	AddOutputJsonYamlTextFlag(hookStatusCmd)
+	app.DefineDebugUnixSocketFlag(hookStatusCmd)
+
 	// Get hook snapshots
 	var hookName string
 	hookSnapshotCmd := hookCmd.Command("snapshot", "Dump hook snapshots.").
@@ -116,6 +129,48 @@ func DefineDebugCommandsSelf(kpApp *kingpin.Application) {
 	hookSnapshotCmd.Arg("hook_name", "").Required().StringVar(&hookName)
 	AddOutputJsonYamlTextFlag(hookSnapshotCmd)
 	app.DefineDebugUnixSocketFlag(hookSnapshotCmd)
+
+	// Get the last hook run
+	hookLastRunCmd := hookCmd.Command("last-run", "Show the last hook run: exit code, duration, binding, usage and output.").
+		Action(func(c *kingpin.ParseContext) error {
+			outBytes, err := Hook(DefaultClient()).Name(hookName).LastRun(OutputFormat)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(outBytes))
+			return nil
+		})
+	hookLastRunCmd.Arg("hook_name", "").Required().StringVar(&hookName)
+	AddOutputJsonYamlTextFlag(hookLastRunCmd)
+	app.DefineDebugUnixSocketFlag(hookLastRunCmd)
+
+	// Disable and enable hook at runtime
+	var dropTasks bool
+	hookDisableCmd := hookCmd.Command("disable", "Disable the hook: schedule and kubernetes events are ignored until the hook is enabled.").
+		Action(func(c *kingpin.ParseContext) error {
+			outBytes, err := Hook(DefaultClient()).Name(hookName).Disable(dropTasks)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(outBytes))
+			return nil
+		})
+	hookDisableCmd.Arg("hook_name", "").Required().StringVar(&hookName)
+	hookDisableCmd.Flag("drop-tasks", "Remove queued tasks to run the hook for schedule and kubernetes events. Tasks are executed if not set.").
+		BoolVar(&dropTasks)
+	app.DefineDebugUnixSocketFlag(hookDisableCmd)
+
+	hookEnableCmd := hookCmd.Command("enable", "Enable the disabled hook.").
+		Action(func(c *kingpin.ParseContext) error {
+			outBytes, err := Hook(DefaultClient()).Name(hookName).Enable()
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(outBytes))
+			return nil
+		})
+	hookEnableCmd.Arg("hook_name", "").Required().StringVar(&hookName)
+	app.DefineDebugUnixSocketFlag(hookEnableCmd)
 }
 
 func AddOutputJsonYamlTextFlag(cmd *kingpin.CmdClause) {
@@ -163,11 +218,35 @@ func (r *HookRequest) List(format string) ([]byte, error) {
 	return r.client.Get(url)
 }
 
+func (r *HookRequest) Status(format string) ([]byte, error) {
+	url := fmt.Sprintf("http://unix/hook/status.%s", format)
+	return r.client.Get(url)
+}
+
 func (r *HookRequest) Snapshots(format string) ([]byte, error) {
 	url := fmt.Sprintf("http://unix/hook/%s/snapshots.%s", r.name, format)
 	return r.client.Get(url)
 }
 
+func (r *HookRequest) LastRun(format string) ([]byte, error) {
+	url := fmt.Sprintf("http://unix/hook/%s/last-run.%s", r.name, format)
+	return r.client.Get(url)
+}
+
+func (r *HookRequest) Disable(dropTasks bool) ([]byte, error) {
+	data := map[string][]string{}
+	if dropTasks {
+		data["dropTasks"] = []string{"true"}
+	}
+	url := fmt.Sprintf("http://unix/hook/%s/disable", r.name)
+	return r.client.Post(url, data)
+}
+
+func (r *HookRequest) Enable() ([]byte, error) {
+	url := fmt.Sprintf("http://unix/hook/%s/enable", r.name)
+	return r.client.Post(url, map[string][]string{})
+}
+
 type ConfigRequest struct {
 	client *Client
 }
@@ -0,0 +1,57 @@
+//go:build linux && go1.20
+// +build linux,go1.20
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var cloneIntoCgroupSupport struct {
+	once      sync.Once
+	supported bool
+}
+
+// cloneIntoCgroup sets the cgroup as a target for CLONE_INTO_CGROUP. It returns
+// the opened cgroup directory that should be closed after start or nil if
+// the kernel does not support the flag (it is available since Linux 5.7).
+func cloneIntoCgroup(cmd *exec.Cmd, cgroup string) (*os.File, error) {
+	cloneIntoCgroupSupport.once.Do(func() {
+		cloneIntoCgroupSupport.supported = kernelVersionAtLeast(5, 7)
+	})
+	if !cloneIntoCgroupSupport.supported {
+		return nil, nil
+	}
+
+	dir, err := os.Open(cgroup)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(dir.Fd())
+	return dir, nil
+}
+
+func kernelVersionAtLeast(major, minor int) bool {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return false
+	}
+	release := strings.SplitN(unix.ByteSliceToString(uts.Release[:]), ".", 3)
+	if len(release) < 2 {
+		return false
+	}
+	maj, _ := strconv.Atoi(release[0])
+	min, _ := strconv.Atoi(release[1])
+	return maj > major || (maj == major && min >= minor)
+}
@@ -0,0 +1,15 @@
+//go:build !linux || !go1.20
+// +build !linux !go1.20
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+)
+
+// cloneIntoCgroup returns nil: CLONE_INTO_CGROUP is not available in
+// os/exec before Go 1.20, so the limits shim joins the cgroup itself.
+func cloneIntoCgroup(_ *exec.Cmd, _ string) (*os.File, error) {
+	return nil, nil
+}
@@ -1,16 +1,19 @@
 package executor
 
 import (
-	"bufio"
 	"encoding/json"
-	"github.com/flant/shell-operator/pkg/app"
+	"errors"
+	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/flant/shell-operator/pkg/app"
+
 	log "github.com/sirupsen/logrus"
 
 	utils "github.com/flant/shell-operator/pkg/utils/labels"
@@ -20,6 +23,47 @@ type CmdUsage struct {
 	Sys    time.Duration
 	User   time.Duration
 	MaxRss int64
+	// ExitCode is -1 if the command is killed by a signal.
+	ExitCode int
+}
+
+// RunOptions are additional settings for RunAndLogLinesWithOptions.
+type RunOptions struct {
+	// Timeout is a maximum duration of the command execution. Zero means no timeout.
+	Timeout time.Duration
+	// GracePeriod is a delay between SIGTERM and SIGKILL sent to the process group on timeout.
+	GracePeriod time.Duration
+	// ResponseOutput receives everything the command writes to the file descriptor 3.
+	// The descriptor is not opened if ResponseOutput is nil.
+	ResponseOutput io.Writer
+	// Limits restrict resources available to the command. No limits if nil.
+	Limits *ResourceLimits
+	// Security defines a user and privileges for the command. The command runs as Shell-operator if nil.
+	Security *SecurityContext
+	// Stdout and Stderr receive copies of the command's output, e.g. to keep its tail in the TailBuffer.
+	Stdout io.Writer
+	Stderr io.Writer
+	// Stdin is written to the command's stdin. The pipe is closed after the command
+	// exits, so background jobs that inherit stdin do not block the execution.
+	Stdin io.Reader
+}
+
+// ResponseFd is a file descriptor number for RunOptions.ResponseOutput in the command.
+const ResponseFd = 3
+
+// TimeoutError is returned when the command is killed because of the execution timeout.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("execution timeout %s exceeded", e.Timeout.String())
+}
+
+// IsTimeout returns true if err is caused by the execution timeout.
+func IsTimeout(err error) bool {
+	var timeoutErr *TimeoutError
+	return errors.As(err, &timeoutErr)
 }
 
 func Run(cmd *exec.Cmd) error {
@@ -27,10 +71,32 @@ func Run(cmd *exec.Cmd) error {
 	// TODO observability
 	log.Debugf("Executing command '%s' in '%s' dir", strings.Join(cmd.Args, " "), cmd.Dir)
 
-	return cmd.Run()
+	childStarted()
+	defer childDone()
+
+	err := cmd.Run()
+	if cmd.Process != nil {
+		killProcessGroup(cmd.Process.Pid)
+	}
+	return err
 }
 
 func RunAndLogLines(cmd *exec.Cmd, logLabels map[string]string) (*CmdUsage, error) {
+	return RunAndLogLinesWithOptions(cmd, logLabels, RunOptions{})
+}
+
+// RunAndLogLinesWithOptions starts the command, sends its stdout and stderr lines
+// to the log and waits for the command to exit.
+//
+// The command is started in a separate process group. Processes left
+// in the group after the command exit are killed and reaped.
+//
+// If opts.Timeout is set, the whole group receives SIGTERM when the timeout
+// expires and SIGKILL after opts.GracePeriod. TimeoutError is returned in this case.
+//
+// If opts.Limits is set, LimitExceededError is returned when the command
+// fails because of the resource limit.
+func RunAndLogLinesWithOptions(cmd *exec.Cmd, logLabels map[string]string, opts RunOptions) (*CmdUsage, error) {
 	// TODO observability
 	logEntry := log.WithFields(utils.LabelsToLogFields(logLabels))
 	stdoutLogEntry := logEntry.WithField("output", "stdout")
@@ -40,55 +106,178 @@ func RunAndLogLines(cmd *exec.Cmd, logLabels map[string]string) (*CmdUsage, erro
 
 	var wg sync.WaitGroup
 
-	stdout, err := cmd.StdoutPipe()
+	// os.Pipe is used instead of cmd.StdoutPipe to be able to wait for the
+	// command exit before all writers (e.g. background jobs) close their ends.
+	stdout, stdoutWriter, err := os.Pipe()
 	if err != nil {
 		return nil, err
 	}
+	defer stdout.Close()
 
-	stderr, err := cmd.StderrPipe()
+	stderr, stderrWriter, err := os.Pipe()
 	if err != nil {
+		stdoutWriter.Close()
 		return nil, err
 	}
+	defer stderr.Close()
+
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	var response, responseWriter *os.File
+	if opts.ResponseOutput != nil {
+		response, responseWriter, err = os.Pipe()
+		if err != nil {
+			stdoutWriter.Close()
+			stderrWriter.Close()
+			return nil, err
+		}
+		defer response.Close()
+		// The first extra file becomes the file descriptor 3.
+		cmd.ExtraFiles = []*os.File{responseWriter}
+	}
+
+	// os.Pipe is used instead of io.Reader to not wait in cmd.Wait for
+	// the copying go-routine that may be blocked by the full pipe.
+	var stdin, stdinWriter *os.File
+	if opts.Stdin != nil {
+		stdin, stdinWriter, err = os.Pipe()
+		if err != nil {
+			stdoutWriter.Close()
+			stderrWriter.Close()
+			if responseWriter != nil {
+				responseWriter.Close()
+			}
+			return nil, err
+		}
+		defer stdinWriter.Close()
+		cmd.Stdin = stdin
+	}
+
+	childStarted()
+	defer childDone()
 
-	err = cmd.Start()
+	limitsGuard, err := StartCommandWithLimits(cmd, opts.Security, opts.Limits, logEntry)
+	// Parent's copies of write ends are not needed after Start.
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	if responseWriter != nil {
+		responseWriter.Close()
+	}
+	if stdin != nil {
+		stdin.Close()
+	}
 	if err != nil {
 		return nil, err
 	}
+	defer limitsGuard.Release()
+
+	if stdinWriter != nil {
+		go func() {
+			// EPIPE is expected if the command does not read the whole input.
+			_, _ = io.Copy(stdinWriter, opts.Stdin)
+			stdinWriter.Close()
+		}()
+	}
+
+	var timedOut bool
+	var timedOutMu sync.Mutex
+	stopTimer := make(chan struct{})
+	if opts.Timeout > 0 {
+		go func() {
+			timer := time.NewTimer(opts.Timeout)
+			defer timer.Stop()
+			select {
+			case <-stopTimer:
+				return
+			case <-timer.C:
+			}
+
+			timedOutMu.Lock()
+			timedOut = true
+			timedOutMu.Unlock()
+
+			logEntry.Warnf("Execution timeout %s exceeded, send SIGTERM to process group %d", opts.Timeout.String(), cmd.Process.Pid)
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+
+			graceTimer := time.NewTimer(opts.GracePeriod)
+			defer graceTimer.Stop()
+			select {
+			case <-stopTimer:
+				return
+			case <-graceTimer.C:
+			}
+
+			logEntry.Warnf("Process group %d is still running after grace period %s, send SIGKILL", cmd.Process.Pid, opts.GracePeriod.String())
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}()
+	}
+
+	// Errors from output readers, e.g. OutputLimitError.
+	var stdoutErr, stderrErr, responseErr error
 
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		if app.LogProxyHookJSON {
-			proxyJSONLogs(stdout, stdoutLogEntry)
-		} else {
-			scanner := bufio.NewScanner(stdout)
-			for scanner.Scan() {
-				stdoutLogEntry.Info(scanner.Text())
-			}
-		}
+		stdoutErr = LogLines(teeReader(stdout, opts.Stdout), stdoutLogEntry)
 	}()
 
 	go func() {
 		defer wg.Done()
-		if app.LogProxyHookJSON {
-			proxyJSONLogs(stderr, stderrLogEntry)
-		} else {
-			scanner := bufio.NewScanner(stderr)
-			for scanner.Scan() {
-				stderrLogEntry.Info(scanner.Text())
-			}
-		}
+		stderrErr = LogLines(teeReader(stderr, opts.Stderr), stderrLogEntry)
 	}()
 
-	wg.Wait()
+	if response != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, responseErr = io.Copy(opts.ResponseOutput, response)
+			if responseErr != nil {
+				logEntry.Errorf("Read response from fd %d: %v", ResponseFd, responseErr)
+				// Close the read end to stop the command's writes with EPIPE.
+				response.Close()
+			}
+		}()
+	}
 
 	err = cmd.Wait()
+	close(stopTimer)
+
+	// Kill background jobs started by the command. They hold the write ends
+	// of the pipes, so log readers are stopped only after this.
+	leftovers := killProcessGroup(cmd.Process.Pid)
+	if leftovers > 0 {
+		logEntry.Warnf("Reaped %d orphaned processes left in process group %d", leftovers, cmd.Process.Pid)
+	}
+	// Stop the stdin writer if the command has not read the whole input.
+	if stdinWriter != nil {
+		stdinWriter.Close()
+	}
+
+	wg.Wait()
+
+	timedOutMu.Lock()
+	if timedOut {
+		err = &TimeoutError{Timeout: opts.Timeout}
+	} else if limit := limitsGuard.Exceeded(cmd.ProcessState); limit != "" {
+		logEntry.Warnf("Command exceeded %s limit", limit)
+		err = &LimitExceededError{Limit: limit, Err: err}
+	} else if responseErr != nil {
+		// The command may fail with EPIPE, the response error is more informative.
+		err = fmt.Errorf("read response from fd %d: %w", ResponseFd, responseErr)
+	} else if err == nil && stdoutErr != nil {
+		err = stdoutErr
+	} else if err == nil && stderrErr != nil {
+		err = stderrErr
+	}
+	timedOutMu.Unlock()
 
 	var usage *CmdUsage = nil
 	if cmd.ProcessState != nil {
 		usage = &CmdUsage{
-			Sys:  cmd.ProcessState.SystemTime(),
-			User: cmd.ProcessState.UserTime(),
+			Sys:      cmd.ProcessState.SystemTime(),
+			User:     cmd.ProcessState.UserTime(),
+			ExitCode: cmd.ProcessState.ExitCode(),
 		}
 		// FIXME Maxrss is Unix specific.
 		sysUsage := cmd.ProcessState.SysUsage()
@@ -101,52 +290,95 @@ func RunAndLogLines(cmd *exec.Cmd, logLabels map[string]string) (*CmdUsage, erro
 	return usage, err
 }
 
-func proxyJSONLogs(r io.ReadCloser, logEntry *log.Entry) {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		var line interface{}
-		if err := json.Unmarshal([]byte(scanner.Text()), &line); err != nil {
-			logEntry.Debugf("unmarshal json log line: %v", err)
-			// fall back to using the logger
-			logEntry.Info(scanner.Text())
-			continue
-		}
-		logMap, ok := line.(map[string]interface{})
-		if !ok {
-			logEntry.Debugf("json log line not map[string]interface{}: %v", line)
-			// fall back to using the logger
-			logEntry.Info(scanner.Text())
-			continue
-		}
+func teeReader(r io.Reader, w io.Writer) io.Reader {
+	if w == nil {
+		return r
+	}
+	return io.TeeReader(r, w)
+}
 
-		for k, v := range logEntry.Data {
-			logMap[k] = v
+// LogLines sends lines from the reader to the log until EOF. Lines longer
+// than app.HookMaxLogLineSize are truncated and OutputLimitError is returned
+// after reading all lines.
+func LogLines(r io.Reader, logEntry *log.Entry) error {
+	var limitErr error
+	err := readLines(r, app.HookMaxLogLineSize, func(line []byte, dropped int) {
+		if dropped > 0 {
+			output, _ := logEntry.Data["output"].(string)
+			if output == "" {
+				output = "output"
+			}
+			limitErr = &OutputLimitError{Output: fmt.Sprintf("%s line", output), Limit: app.HookMaxLogLineSize}
+			logEntry.Warnf("%s: %d bytes are truncated", limitErr, dropped)
+			logEntry.Info(string(line) + fmt.Sprintf("... [truncated %d bytes]", dropped))
+			return
 		}
-		logLine, err := json.Marshal(logMap)
-		if err != nil {
-			logEntry.Debugf("marshal json log line: %v", err)
-			// fall back to using the logger
-			logEntry.Info(scanner.Text())
-			continue
+		if app.LogProxyHookJSON {
+			proxyJSONLog(string(line), logEntry)
+			return
 		}
-		// Mark this log entry as one that is json that needs to be proxied
-		logEntry := logEntry.WithField(app.ProxyJsonLogKey, true)
-		// Log the line via the same centralized logger; the formatter should make sure it's "proxied"
-		logEntry.Log(log.FatalLevel, string(logLine))
+		logEntry.Info(string(line))
+	})
+	if err != nil {
+		return err
 	}
+	return limitErr
+}
+
+func proxyJSONLog(text string, logEntry *log.Entry) {
+	var line interface{}
+	if err := json.Unmarshal([]byte(text), &line); err != nil {
+		logEntry.Debugf("unmarshal json log line: %v", err)
+		// fall back to using the logger
+		logEntry.Info(text)
+		return
+	}
+	logMap, ok := line.(map[string]interface{})
+	if !ok {
+		logEntry.Debugf("json log line not map[string]interface{}: %v", line)
+		// fall back to using the logger
+		logEntry.Info(text)
+		return
+	}
+
+	for k, v := range logEntry.Data {
+		logMap[k] = v
+	}
+	logLine, err := json.Marshal(logMap)
+	if err != nil {
+		logEntry.Debugf("marshal json log line: %v", err)
+		// fall back to using the logger
+		logEntry.Info(text)
+		return
+	}
+	// Mark this log entry as one that is json that needs to be proxied
+	logEntry = logEntry.WithField(app.ProxyJsonLogKey, true)
+	// Log the line via the same centralized logger; the formatter should make sure it's "proxied"
+	logEntry.Log(log.FatalLevel, string(logLine))
 }
 
 func Output(cmd *exec.Cmd) (output []byte, err error) {
 	// TODO context: hook name, hook phase, hook binding
 	// TODO observability
 	log.Debugf("Executing command '%s' in '%s' dir", strings.Join(cmd.Args, " "), cmd.Dir)
+
+	childStarted()
+	defer childDone()
+
 	output, err = cmd.Output()
+	if cmd.Process != nil {
+		killProcessGroup(cmd.Process.Pid)
+	}
 	return
 }
 
+// MakeCommand creates a command that runs in its own process group.
 func MakeCommand(dir string, entrypoint string, args []string, envs []string) *exec.Cmd {
 	cmd := exec.Command(entrypoint, args...)
 	cmd.Env = append(cmd.Env, envs...)
 	cmd.Dir = dir
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
 	return cmd
 }
@@ -0,0 +1,152 @@
+package executor
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_RunAndLogLinesWithOptions_Timeout(t *testing.T) {
+	g := NewWithT(t)
+
+	// The shell ignores SIGTERM, so SIGKILL is required to stop the process group.
+	cmd := MakeCommand("", "sh", []string{"-c", "trap '' TERM; sleep 10 & wait"}, nil)
+
+	start := time.Now()
+	_, err := RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{
+		Timeout:     100 * time.Millisecond,
+		GracePeriod: 100 * time.Millisecond,
+	})
+
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(IsTimeout(err)).To(BeTrue())
+	g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+}
+
+func Test_RunAndLogLinesWithOptions_NoTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	cmd := MakeCommand("", "sh", []string{"-c", "exit 1"}, nil)
+
+	_, err := RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{
+		Timeout: 10 * time.Second,
+	})
+
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(IsTimeout(err)).To(BeFalse())
+}
+
+func Test_RunAndLogLinesWithOptions_KillBackgroundJobs(t *testing.T) {
+	g := NewWithT(t)
+
+	// Background job holds stdout, so the command would not be finished without killing the process group.
+	cmd := MakeCommand("", "sh", []string{"-c", "sleep 30 & echo started"}, nil)
+
+	start := time.Now()
+	_, err := RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{})
+
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+}
+
+func Test_RunAndLogLinesWithOptions_Rlimits(t *testing.T) {
+	g := NewWithT(t)
+
+	// Limits are applied before exec, so the command and its children see them immediately.
+	cmd := MakeCommand("", "sh", []string{"-c", "test $(ulimit -n) -eq 64 && (test $(ulimit -n) -eq 64)"}, nil)
+
+	_, err := RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{
+		Limits: &ResourceLimits{NoFile: 64},
+	})
+
+	g.Expect(err).ShouldNot(HaveOccurred())
+}
+
+func Test_RunAndLogLinesWithOptions_CPUTimeLimit(t *testing.T) {
+	g := NewWithT(t)
+
+	cmd := MakeCommand("", "sh", []string{"-c", "while :; do :; done"}, nil)
+
+	_, err := RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{
+		Timeout: 20 * time.Second,
+		Limits:  &ResourceLimits{CPUTime: time.Second},
+	})
+
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(IsTimeout(err)).To(BeFalse())
+	g.Expect(ExceededLimit(err)).To(Equal(LimitCPUTime))
+}
+
+func Test_RunAndLogLinesWithOptions_Security(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("root is required to change credentials")
+	}
+	g := NewWithT(t)
+
+	script := `test "$(id -u):$(id -g)" = "65534:65534" &&
+grep -q '^NoNewPrivs:[[:space:]]*1' /proc/self/status &&
+grep -q '^CapEff:[[:space:]]*0000000000000000' /proc/self/status &&
+grep -q '^CapBnd:[[:space:]]*0000000000000000' /proc/self/status`
+	cmd := MakeCommand("", "sh", []string{"-c", script}, nil)
+
+	_, err := RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{
+		Security: &SecurityContext{
+			RunAsUser:      true,
+			Uid:            65534,
+			Gid:            65534,
+			DropPrivileges: true,
+		},
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	// Operator's threads keep their privileges.
+	cmd = MakeCommand("", "sh", []string{"-c", "grep -q '^NoNewPrivs:[[:space:]]*0' /proc/self/status"}, nil)
+	for i := 0; i < 10; i++ {
+		_, err = RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{})
+		g.Expect(err).ShouldNot(HaveOccurred())
+		cmd = MakeCommand("", "sh", []string{"-c", "grep -q '^NoNewPrivs:[[:space:]]*0' /proc/self/status"}, nil)
+	}
+}
+
+func Test_RunAndLogLinesWithOptions_SecurityWithLimits(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("root is required to change credentials")
+	}
+	g := NewWithT(t)
+
+	// The limits shim changes credentials after applying limits.
+	script := `test "$(id -u):$(id -g)" = "65534:65534" &&
+test $(ulimit -n) -eq 64 &&
+grep -q '^NoNewPrivs:[[:space:]]*1' /proc/self/status &&
+grep -q '^CapEff:[[:space:]]*0000000000000000' /proc/self/status`
+	cmd := MakeCommand("", "sh", []string{"-c", script}, nil)
+
+	_, err := RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{
+		Limits: &ResourceLimits{NoFile: 64},
+		Security: &SecurityContext{
+			RunAsUser:      true,
+			Uid:            65534,
+			Gid:            65534,
+			DropPrivileges: true,
+		},
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+}
+
+func Test_RunAndLogLinesWithOptions_StdinWithBackgroundJob(t *testing.T) {
+	g := NewWithT(t)
+
+	// The background job inherits stdin and does not read it, the input does not fit into the pipe buffer.
+	cmd := MakeCommand("", "sh", []string{"-c", "sleep 30 & head -c 10 > /dev/null"}, nil)
+
+	start := time.Now()
+	_, err := RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{
+		Stdin: bytes.NewReader(make([]byte, 1024*1024)),
+	})
+
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+}
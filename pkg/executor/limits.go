@@ -0,0 +1,308 @@
+package executor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ResourceLimits restrict resources available to the command.
+type ResourceLimits struct {
+	// Memory is a limit for memory usage in bytes. It is enforced with
+	// a cgroup if cgroup v2 is writable and with RLIMIT_AS otherwise.
+	Memory int64
+	// AddressSpace is a RLIMIT_AS limit in bytes.
+	AddressSpace int64
+	// CPUTime is a RLIMIT_CPU limit.
+	CPUTime time.Duration
+	// NoFile is a RLIMIT_NOFILE limit.
+	NoFile uint64
+	// NProc is a RLIMIT_NPROC limit. It is also a "pids.max" value for the cgroup.
+	NProc uint64
+}
+
+// Names of limits for LimitExceededError.
+const (
+	LimitMemory  = "memory"
+	LimitCPUTime = "cpuTime"
+	LimitNProc   = "nproc"
+)
+
+// LimitExceededError is returned when the command fails because of the resource limit.
+type LimitExceededError struct {
+	Limit string
+	Err   error
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s limit exceeded: %v", e.Limit, e.Err)
+}
+
+func (e *LimitExceededError) Unwrap() error {
+	return e.Err
+}
+
+// ExceededLimit returns a name of the exceeded limit if err is caused by the resource limit.
+func ExceededLimit(err error) string {
+	var limitErr *LimitExceededError
+	if errors.As(err, &limitErr) {
+		return limitErr.Limit
+	}
+	return ""
+}
+
+// LimitsGuard holds resources created to enforce limits for the running command.
+// Methods are safe to call on a nil LimitsGuard.
+type LimitsGuard struct {
+	limits *ResourceLimits
+	cgroup string
+}
+
+// StartCommandWithLimits starts the command with the security context and
+// resource limits. The command is started with the limits shim: the process
+// is placed into the cgroup at clone time if possible, the shim joins the cgroup
+// otherwise, sets rlimits and credentials and executes the command. So the command
+// and all its children are limited from the start. The command is started
+// with StartCommand if limits is nil.
+func StartCommandWithLimits(cmd *exec.Cmd, sc *SecurityContext, limits *ResourceLimits, logEntry *log.Entry) (*LimitsGuard, error) {
+	if limits == nil {
+		return nil, StartCommand(cmd, sc)
+	}
+	g := &LimitsGuard{limits: limits}
+	shim := &limitsShim{
+		AddressSpace: limits.AddressSpace,
+		CPUTime:      limits.CPUTime,
+		NoFile:       limits.NoFile,
+		NProc:        limits.NProc,
+	}
+
+	if limits.Memory > 0 || limits.NProc > 0 {
+		cgroup, err := createRunCgroup(limits)
+		if err != nil {
+			logEntry.Warnf("Cgroup limits are not applied: %v", err)
+		}
+		g.cgroup = cgroup
+	}
+
+	// Use RLIMIT_AS as a fallback for the memory limit.
+	if g.cgroup == "" && shim.AddressSpace == 0 {
+		shim.AddressSpace = limits.Memory
+	}
+
+	if g.cgroup != "" {
+		cgroupDir, err := cloneIntoCgroup(cmd, g.cgroup)
+		if err != nil {
+			g.Release()
+			return nil, err
+		}
+		if cgroupDir != nil {
+			defer cgroupDir.Close()
+		} else {
+			shim.Cgroup = g.cgroup
+		}
+	}
+
+	err := startWithLimitsShim(cmd, sc, shim)
+	if err != nil {
+		g.Release()
+		return nil, err
+	}
+	return g, nil
+}
+
+// Exceeded checks why the command has failed and returns the exceeded limit
+// or an empty string if failure is not caused by limits.
+func (g *LimitsGuard) Exceeded(state *os.ProcessState) string {
+	if g == nil || state == nil || state.Success() {
+		return ""
+	}
+
+	if g.cgroup != "" && readCgroupEvent(g.cgroup, "memory.events", "oom_kill") > 0 {
+		return LimitMemory
+	}
+
+	if g.limits.CPUTime > 0 {
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			cpuTime := state.UserTime() + state.SystemTime()
+			if ws.Signal() == syscall.SIGXCPU || (ws.Signal() == syscall.SIGKILL && cpuTime >= g.limits.CPUTime) {
+				return LimitCPUTime
+			}
+		}
+	}
+
+	if g.cgroup != "" && readCgroupEvent(g.cgroup, "pids.events", "max") > 0 {
+		return LimitNProc
+	}
+
+	return ""
+}
+
+// Release kills processes left in the cgroup and removes it.
+func (g *LimitsGuard) Release() {
+	if g == nil || g.cgroup == "" {
+		return
+	}
+	removeCgroup(g.cgroup)
+	g.cgroup = ""
+}
+
+// cgroupFsRoot is a mount point of cgroup v2. Shell-operator processes are moved
+// to "<operator cgroup>/operator" and each hook execution gets its own
+// "<operator cgroup>/hooks/run-N" cgroup.
+var cgroupFsRoot = "/sys/fs/cgroup"
+
+var cgroups = struct {
+	once    sync.Once
+	hooks   string
+	err     error
+	counter uint64
+}{}
+
+// hooksCgroup returns a parent cgroup for hook executions. Shell-operator moves
+// itself to a leaf cgroup on the first call to be able to enable
+// controllers for sub-cgroups ("no internal processes" rule).
+func hooksCgroup() (string, error) {
+	cgroups.once.Do(func() {
+		cgroups.hooks, cgroups.err = initHooksCgroup()
+		if cgroups.err == nil {
+			log.Infof("Use cgroup '%s' to limit hooks", cgroups.hooks)
+		}
+	})
+	return cgroups.hooks, cgroups.err
+}
+
+func initHooksCgroup() (string, error) {
+	controllers, err := ioutil.ReadFile(filepath.Join(cgroupFsRoot, "cgroup.controllers"))
+	if err != nil {
+		return "", fmt.Errorf("cgroup v2 is not available: %v", err)
+	}
+	enable := []string{}
+	for _, c := range strings.Fields(string(controllers)) {
+		if c == "memory" || c == "pids" {
+			enable = append(enable, "+"+c)
+		}
+	}
+	if len(enable) == 0 {
+		return "", fmt.Errorf("memory and pids controllers are not available")
+	}
+
+	current, err := currentCgroup()
+	if err != nil {
+		return "", err
+	}
+	base := filepath.Join(cgroupFsRoot, current)
+
+	leaf := filepath.Join(base, "operator")
+	if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return "", fmt.Errorf("cgroup is not writable: %v", err)
+	}
+	procs, err := ioutil.ReadFile(filepath.Join(base, "cgroup.procs"))
+	if err != nil {
+		return "", err
+	}
+	for _, pid := range strings.Fields(string(procs)) {
+		// Ignore errors: processes may exit in the meantime.
+		_ = writeCgroupFile(leaf, "cgroup.procs", pid)
+	}
+
+	if err := writeCgroupFile(base, "cgroup.subtree_control", strings.Join(enable, " ")); err != nil {
+		return "", fmt.Errorf("enable controllers: %v", err)
+	}
+	hooks := filepath.Join(base, "hooks")
+	if err := os.Mkdir(hooks, 0755); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	if err := writeCgroupFile(hooks, "cgroup.subtree_control", strings.Join(enable, " ")); err != nil {
+		return "", fmt.Errorf("enable controllers: %v", err)
+	}
+	return hooks, nil
+}
+
+// currentCgroup returns a cgroup v2 path of the current process.
+func currentCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "0::") {
+			return strings.TrimPrefix(scanner.Text(), "0::"), nil
+		}
+	}
+	return "", fmt.Errorf("cgroup v2 path is not found in /proc/self/cgroup")
+}
+
+// createRunCgroup creates a cgroup for one execution. It returns an empty path if cgroups are not available.
+func createRunCgroup(limits *ResourceLimits) (string, error) {
+	hooks, err := hooksCgroup()
+	if err != nil {
+		return "", err
+	}
+	cgroup := filepath.Join(hooks, fmt.Sprintf("run-%d", atomic.AddUint64(&cgroups.counter, 1)))
+	if err := os.Mkdir(cgroup, 0755); err != nil {
+		return "", err
+	}
+	if limits.Memory > 0 {
+		if err := writeCgroupFile(cgroup, "memory.max", strconv.FormatInt(limits.Memory, 10)); err != nil {
+			removeCgroup(cgroup)
+			return "", err
+		}
+		// Swap can be disabled, ignore errors.
+		_ = writeCgroupFile(cgroup, "memory.swap.max", "0")
+	}
+	if limits.NProc > 0 {
+		if err := writeCgroupFile(cgroup, "pids.max", strconv.FormatUint(limits.NProc, 10)); err != nil {
+			removeCgroup(cgroup)
+			return "", err
+		}
+	}
+	return cgroup, nil
+}
+
+// removeCgroup kills remaining processes and removes the cgroup.
+func removeCgroup(cgroup string) {
+	// cgroup.kill is available since Linux 5.14.
+	_ = writeCgroupFile(cgroup, "cgroup.kill", "1")
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = os.Remove(cgroup); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	log.Warnf("Remove cgroup '%s': %v", cgroup, err)
+}
+
+func writeCgroupFile(cgroup, name, value string) error {
+	return ioutil.WriteFile(filepath.Join(cgroup, name), []byte(value), 0644)
+}
+
+// readCgroupEvent returns a counter from the "*.events" file.
+func readCgroupEvent(cgroup, file, event string) int {
+	content, err := ioutil.ReadFile(filepath.Join(cgroup, file))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == event {
+			n, _ := strconv.Atoi(fields[1])
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// The shim is detected before the main function, so flags of the binary are not parsed.
+func init() {
+	if len(os.Args) == 0 || os.Args[0] != limitsShimName {
+		return
+	}
+	// Credentials are changed for the current thread only, exec should be called from the same thread.
+	runtime.LockOSThread()
+	err := runLimitsShim(os.Args[1:])
+	fmt.Fprintf(os.Stderr, "%s: %v\n", limitsShimName, err)
+	os.Exit(127)
+}
+
+// runLimitsShim applies limits and the security context and executes the command. It returns only on error.
+func runLimitsShim(args []string) error {
+	s, path, argv, err := parseLimitsShimArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if s.Cgroup != "" {
+		// "0" moves the writing process.
+		if err := writeCgroupFile(s.Cgroup, "cgroup.procs", "0"); err != nil {
+			return fmt.Errorf("join cgroup: %v", err)
+		}
+	}
+
+	if err := setRlimits(s.AddressSpace, s.CPUTime, s.NoFile, s.NProc); err != nil {
+		return err
+	}
+
+	if s.DropPrivileges {
+		if err := dropPrivileges(); err != nil {
+			return err
+		}
+	}
+
+	if s.Credential != nil {
+		if err := setCredential(s.Credential); err != nil {
+			return err
+		}
+	}
+
+	return syscall.Exec(path, argv, os.Environ())
+}
+
+// setRlimits sets rlimits for the current process. Zero values are ignored.
+func setRlimits(addressSpace int64, cpuTime time.Duration, noFile uint64, nProc uint64) error {
+	if addressSpace > 0 {
+		if err := setrlimit(unix.RLIMIT_AS, uint64(addressSpace), uint64(addressSpace)); err != nil {
+			return fmt.Errorf("set address space limit: %v", err)
+		}
+	}
+	if cpuTime > 0 {
+		seconds := uint64((cpuTime + time.Second - 1) / time.Second)
+		// SIGXCPU on the soft limit, SIGKILL a second later.
+		if err := setrlimit(unix.RLIMIT_CPU, seconds, seconds+1); err != nil {
+			return fmt.Errorf("set cpu time limit: %v", err)
+		}
+	}
+	if noFile > 0 {
+		if err := setrlimit(unix.RLIMIT_NOFILE, noFile, noFile); err != nil {
+			return fmt.Errorf("set open files limit: %v", err)
+		}
+	}
+	if nProc > 0 {
+		if err := setrlimit(unix.RLIMIT_NPROC, nProc, nProc); err != nil {
+			return fmt.Errorf("set processes limit: %v", err)
+		}
+	}
+	return nil
+}
+
+func setrlimit(resource int, cur, max uint64) error {
+	return unix.Setrlimit(resource, &unix.Rlimit{Cur: cur, Max: max})
+}
+
+// setCredential changes groups, gid and uid of the current thread like
+// os/exec does in the child. It is enough because the thread calls exec.
+func setCredential(cred *syscall.Credential) error {
+	groups := make([]int, 0, len(cred.Groups))
+	for _, g := range cred.Groups {
+		groups = append(groups, int(g))
+	}
+	if err := unix.Setgroups(groups); err != nil {
+		return fmt.Errorf("set groups: %v", err)
+	}
+	if err := unix.Setresgid(int(cred.Gid), int(cred.Gid), int(cred.Gid)); err != nil {
+		return fmt.Errorf("set gid: %v", err)
+	}
+	if err := unix.Setresuid(int(cred.Uid), int(cred.Uid), int(cred.Uid)); err != nil {
+		return fmt.Errorf("set uid: %v", err)
+	}
+	return nil
+}
+
+// startWithLimitsShim starts the shim that applies limits and the security
+// context and executes the command. The shim is started with the operator's
+// privileges to be able to join the cgroup.
+func startWithLimitsShim(cmd *exec.Cmd, sc *SecurityContext, s *limitsShim) error {
+	if sc != nil {
+		if sc.RunAsUser {
+			s.Credential = &syscall.Credential{Uid: sc.Uid, Gid: sc.Gid, Groups: sc.Groups}
+		}
+		s.DropPrivileges = sc.DropPrivileges
+	}
+	s.wrap(cmd)
+	return cmd.Start()
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// startWithLimitsShim is not supported on this platform.
+func startWithLimitsShim(_ *exec.Cmd, _ *SecurityContext, _ *limitsShim) error {
+	return fmt.Errorf("resource limits are not supported on this platform")
+}
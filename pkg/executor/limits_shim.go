@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// limitsShimName is argv[0] of the limits shim. The shim is the Shell-operator's
+// own binary started via /proc/self/exe. It applies limits and credentials to
+// itself and then executes the command, so no code of the command runs without limits.
+const limitsShimName = "shell-operator-limits-shim"
+
+// limitsShim is a set of limits and security attributes applied by the shim before exec.
+type limitsShim struct {
+	// Cgroup is joined by the shim if the process is not placed into the cgroup at clone time.
+	Cgroup       string
+	AddressSpace int64
+	CPUTime      time.Duration
+	NoFile       uint64
+	NProc        uint64
+	// Credential is set after joining the cgroup, because non-root users can not move processes between cgroups.
+	Credential     *syscall.Credential
+	DropPrivileges bool
+}
+
+// wrap replaces the command with the shim that executes the original command.
+func (s *limitsShim) wrap(cmd *exec.Cmd) {
+	args := []string{
+		limitsShimName,
+		"-cgroup=" + s.Cgroup,
+		"-as=" + strconv.FormatInt(s.AddressSpace, 10),
+		"-cpu=" + s.CPUTime.String(),
+		"-nofile=" + strconv.FormatUint(s.NoFile, 10),
+		"-nproc=" + strconv.FormatUint(s.NProc, 10),
+		"-drop-privileges=" + strconv.FormatBool(s.DropPrivileges),
+	}
+	if s.Credential != nil {
+		groups := make([]string, 0, len(s.Credential.Groups))
+		for _, g := range s.Credential.Groups {
+			groups = append(groups, strconv.FormatUint(uint64(g), 10))
+		}
+		args = append(args,
+			"-uid="+strconv.FormatUint(uint64(s.Credential.Uid), 10),
+			"-gid="+strconv.FormatUint(uint64(s.Credential.Gid), 10),
+			"-groups="+strings.Join(groups, ","),
+		)
+	}
+	args = append(args, "--", cmd.Path)
+	cmd.Args = append(args, cmd.Args...)
+	cmd.Path = "/proc/self/exe"
+}
+
+// parseLimitsShimArgs returns the shim's attributes, a path and arguments of the command.
+func parseLimitsShimArgs(args []string) (*limitsShim, string, []string, error) {
+	s := &limitsShim{}
+	var uid, gid int64 = -1, -1
+	var groups string
+
+	fs := flag.NewFlagSet(limitsShimName, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&s.Cgroup, "cgroup", "", "")
+	fs.Int64Var(&s.AddressSpace, "as", 0, "")
+	fs.DurationVar(&s.CPUTime, "cpu", 0, "")
+	fs.Uint64Var(&s.NoFile, "nofile", 0, "")
+	fs.Uint64Var(&s.NProc, "nproc", 0, "")
+	fs.BoolVar(&s.DropPrivileges, "drop-privileges", false, "")
+	fs.Int64Var(&uid, "uid", -1, "")
+	fs.Int64Var(&gid, "gid", -1, "")
+	fs.StringVar(&groups, "groups", "", "")
+	if err := fs.Parse(args); err != nil {
+		return nil, "", nil, err
+	}
+	if fs.NArg() < 2 {
+		return nil, "", nil, fmt.Errorf("command is not specified")
+	}
+
+	if uid >= 0 && gid >= 0 {
+		s.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}
+		for _, g := range strings.Split(groups, ",") {
+			if g == "" {
+				continue
+			}
+			n, err := strconv.ParseUint(g, 10, 32)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("parse groups: %v", err)
+			}
+			s.Credential.Groups = append(s.Credential.Groups, uint32(n))
+		}
+	}
+
+	return s, fs.Arg(0), fs.Args()[1:], nil
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// OutputLimitError is returned when the command's output exceeds the size limit.
+type OutputLimitError struct {
+	// Output is a name of the output, e.g. "stdout" or "metrics".
+	Output string
+	Limit  int
+}
+
+func (e *OutputLimitError) Error() string {
+	return fmt.Sprintf("%s exceeds the limit of %d bytes", e.Output, e.Limit)
+}
+
+// IsOutputLimit returns true if err is caused by the output size limit.
+func IsOutputLimit(err error) bool {
+	var limitErr *OutputLimitError
+	return errors.As(err, &limitErr)
+}
+
+// LimitedWriter writes to W until Limit bytes are written.
+// OutputLimitError is returned for writes above the limit.
+type LimitedWriter struct {
+	W      io.Writer
+	Output string
+	Limit  int
+
+	written int
+}
+
+func (w *LimitedWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.Limit {
+		return 0, &OutputLimitError{Output: w.Output, Limit: w.Limit}
+	}
+	n, err := w.W.Write(p)
+	w.written += n
+	return n, err
+}
+
+// readLines calls fn for each line read from r. Lines longer than maxSize
+// bytes are truncated, fn receives a number of dropped bytes in this case.
+// The reader is drained until EOF, so the writer is never blocked.
+func readLines(r io.Reader, maxSize int, fn func(line []byte, dropped int)) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var line []byte
+	dropped := 0
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if err == nil {
+			chunk = bytes.TrimRight(chunk, "\r\n")
+		}
+		if room := maxSize - len(line); maxSize > 0 && len(chunk) > room {
+			line = append(line, chunk[:room]...)
+			dropped += len(chunk) - room
+		} else {
+			line = append(line, chunk...)
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == nil || len(line) > 0 || dropped > 0 {
+			fn(line, dropped)
+		}
+		line = line[:0]
+		dropped = 0
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/shell-operator/pkg/app"
+)
+
+func Test_readLines(t *testing.T) {
+	g := NewWithT(t)
+
+	input := "short\r\n" + strings.Repeat("a", 100*1024) + "\n\nlast"
+
+	type line struct {
+		text    string
+		dropped int
+	}
+	lines := []line{}
+	err := readLines(strings.NewReader(input), 10, func(l []byte, dropped int) {
+		lines = append(lines, line{string(l), dropped})
+	})
+
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(lines).To(Equal([]line{
+		{"short", 0},
+		{"aaaaaaaaaa", 100*1024 - 10},
+		{"", 0},
+		{"last", 0},
+	}))
+}
+
+func Test_RunAndLogLinesWithOptions_LongLine(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(size int) {
+		app.HookMaxLogLineSize = size
+	}(app.HookMaxLogLineSize)
+
+	// Lines longer than 64KB are logged without errors by default.
+	cmd := MakeCommand("", "sh", []string{"-c", "head -c 100000 /dev/zero | tr '\\0' 'a'; echo; echo done"}, nil)
+	stdout := new(bytes.Buffer)
+	_, err := RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{Stdout: stdout})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(stdout.String()).To(HaveSuffix("\ndone\n"))
+
+	app.HookMaxLogLineSize = 10
+	cmd = MakeCommand("", "sh", []string{"-c", "echo 0123456789abcdef; echo done"}, nil)
+	_, err = RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{})
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(IsOutputLimit(err)).To(BeTrue())
+	g.Expect(err.Error()).To(Equal("stdout line exceeds the limit of 10 bytes"))
+}
+
+func Test_RunAndLogLinesWithOptions_ResponseLimit(t *testing.T) {
+	g := NewWithT(t)
+
+	cmd := MakeCommand("", "sh", []string{"-c", "head -c 100000 /dev/zero >&3"}, nil)
+	_, err := RunAndLogLinesWithOptions(cmd, map[string]string{}, RunOptions{
+		ResponseOutput: &LimitedWriter{W: new(bytes.Buffer), Output: "response", Limit: 1000},
+	})
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(IsOutputLimit(err)).To(BeTrue())
+}
@@ -0,0 +1,185 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// children tracks commands started by the executor. The reaper should not
+// wait for any child while commands are running, otherwise it can steal
+// their exit statuses from exec.Cmd.Wait. Long-running commands, e.g. hook
+// workers, are tracked by pids, so orphans are reaped while they are running.
+var children struct {
+	sync.Mutex
+	running     int
+	tracked     map[int]bool
+	reaper      bool
+	reapPending bool
+}
+
+func childStarted() {
+	children.Lock()
+	children.running++
+	children.Unlock()
+}
+
+// childDone runs a postponed reaping when the last running command is done.
+func childDone() {
+	children.Lock()
+	defer children.Unlock()
+	children.running--
+	if children.running == 0 && children.reapPending {
+		children.reapPending = false
+		reapOrphans()
+	}
+}
+
+// TrackChild starts a long-running command with the start function. The started
+// process is not reaped as an orphan until UntrackChild is called after cmd.Wait.
+func TrackChild(cmd *exec.Cmd, start func() error) error {
+	// Postpone reaping until the pid is tracked.
+	childStarted()
+	defer childDone()
+
+	err := start()
+	if err != nil || cmd.Process == nil {
+		return err
+	}
+	children.Lock()
+	if children.tracked == nil {
+		children.tracked = make(map[int]bool)
+	}
+	children.tracked[cmd.Process.Pid] = true
+	children.Unlock()
+	return nil
+}
+
+// UntrackChild should be called after cmd.Wait for the command started with TrackChild.
+func UntrackChild(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	children.Lock()
+	delete(children.tracked, cmd.Process.Pid)
+	children.Unlock()
+}
+
+// KillProcessGroup sends SIGKILL to all processes left in the group of the exited command.
+// It is used for long-running commands that are not started with Run* functions.
+func KillProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		killProcessGroup(cmd.Process.Pid)
+	}
+}
+
+// killProcessGroup sends SIGKILL to all processes left in the group
+// and reaps the ones that were reparented to this process.
+// It returns a number of reaped processes.
+func killProcessGroup(pgid int) int {
+	if pgid <= 0 {
+		return 0
+	}
+	_ = syscall.Kill(-pgid, syscall.SIGKILL)
+
+	// Orphans are reparented to this process only if it is PID 1.
+	reaped := 0
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-pgid, &status, syscall.WNOHANG, nil)
+		if err != nil || pid <= 0 {
+			return reaped
+		}
+		reaped++
+	}
+}
+
+// StartReaper starts a go-routine that reaps orphaned processes
+// reparented to this process. It does nothing if the process is not PID 1.
+func StartReaper() {
+	if os.Getpid() != 1 {
+		return
+	}
+	log.Info("Running as PID 1, start reaping orphaned processes")
+
+	children.Lock()
+	children.reaper = true
+	children.Unlock()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGCHLD)
+
+	go func() {
+		for range sigCh {
+			children.Lock()
+			if children.running > 0 {
+				children.reapPending = true
+			} else {
+				reapOrphans()
+			}
+			children.Unlock()
+		}
+	}()
+}
+
+// reapOrphans waits for all exited children. It should be called with children lock held.
+func reapOrphans() {
+	if !children.reaper {
+		return
+	}
+	if len(children.tracked) > 0 {
+		reapUntracked()
+		return
+	}
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if err != nil || pid <= 0 {
+			return
+		}
+		log.Debugf("Reaped orphaned process %d, exit status %d", pid, status.ExitStatus())
+	}
+}
+
+// reapUntracked waits for exited children except tracked commands. Zombies
+// are found in /proc, because wait4(-1) can reap a tracked command.
+func reapUntracked() {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		log.Errorf("Reap orphaned processes: %v", err)
+		return
+	}
+	self := os.Getpid()
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || children.tracked[pid] {
+			continue
+		}
+		stat, err := ioutil.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// Fields after the command name in parentheses: state, ppid, ...
+		i := strings.LastIndexByte(string(stat), ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[i+1:]))
+		if len(fields) < 2 || fields[0] != "Z" || fields[1] != strconv.Itoa(self) {
+			continue
+		}
+		var status syscall.WaitStatus
+		reaped, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil)
+		if err == nil && reaped == pid {
+			log.Debugf("Reaped orphaned process %d, exit status %d", pid, status.ExitStatus())
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_reapOrphans_skips_tracked_children(t *testing.T) {
+	g := NewWithT(t)
+
+	children.Lock()
+	children.reaper = true
+	children.Unlock()
+	defer func() {
+		children.Lock()
+		children.reaper = false
+		children.Unlock()
+	}()
+
+	tracked := exec.Command("true")
+	g.Expect(TrackChild(tracked, tracked.Start)).To(Succeed())
+	orphan := exec.Command("true")
+	g.Expect(orphan.Start()).To(Succeed())
+
+	// Wait for both processes to become zombies.
+	time.Sleep(200 * time.Millisecond)
+
+	children.Lock()
+	reapOrphans()
+	children.Unlock()
+
+	// The orphan is reaped, the tracked command keeps its exit status.
+	_, err := syscall.Wait4(orphan.Process.Pid, nil, syscall.WNOHANG, nil)
+	g.Expect(err).To(Equal(syscall.ECHILD))
+	g.Expect(tracked.Wait()).To(Succeed())
+	UntrackChild(tracked)
+}
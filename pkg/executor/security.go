@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// SecurityContext defines a user and privileges for the command.
+type SecurityContext struct {
+	// RunAsUser is true if the command should be started with Uid, Gid and Groups.
+	RunAsUser bool
+	Uid       uint32
+	Gid       uint32
+	Groups    []uint32
+	// DropPrivileges starts the command with no capabilities and with the no_new_privs flag.
+	DropPrivileges bool
+}
+
+// StartCommand starts the command with the security context. The command
+// is started as usual if sc is nil.
+func StartCommand(cmd *exec.Cmd, sc *SecurityContext) error {
+	if sc == nil {
+		return cmd.Start()
+	}
+
+	if sc.RunAsUser {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.Credential = &syscall.Credential{
+			Uid:    sc.Uid,
+			Gid:    sc.Gid,
+			Groups: sc.Groups,
+		}
+	}
+
+	if sc.DropPrivileges {
+		return startWithoutPrivileges(cmd)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxCapability is a number of bits in the capability sets.
+const maxCapability = 64
+
+// startWithoutPrivileges starts the command from a dedicated OS thread with
+// dropped privileges. The thread is not unlocked, so it is terminated when
+// the go-routine exits and the operator's threads keep their privileges.
+func startWithoutPrivileges(cmd *exec.Cmd) error {
+	errCh := make(chan error)
+	go func() {
+		runtime.LockOSThread()
+		// No UnlockOSThread: the thread is tainted.
+
+		if err := dropPrivileges(); err != nil {
+			errCh <- err
+			return
+		}
+
+		errCh <- cmd.Start()
+	}()
+	return <-errCh
+}
+
+// dropPrivileges sets the empty capability bounding set, the empty inheritable
+// set and the no_new_privs flag for the current thread. These attributes are
+// inherited by children, so they can not gain capabilities on exec. Effective
+// capabilities are kept to change credentials before exec, they are cleared
+// by the kernel when a non-root uid is set.
+func dropPrivileges() error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %v", err)
+	}
+
+	// The kernel may know more capabilities than x/sys, so drop them until EINVAL.
+	for c := 0; c < maxCapability; c++ {
+		err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0)
+		if err == unix.EINVAL {
+			break
+		}
+		// EPERM if CAP_SETPCAP is not available: the hook should not be started with capabilities.
+		if err != nil {
+			return fmt.Errorf("drop capability %d from bounding set: %v", c, err)
+		}
+	}
+	for c := 0; c < maxCapability; c++ {
+		inSet, err := unix.PrctlRetInt(unix.PR_CAPBSET_READ, uintptr(c), 0, 0, 0)
+		if err == unix.EINVAL {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read capability %d from bounding set: %v", c, err)
+		}
+		if inSet != 0 {
+			return fmt.Errorf("capability %d is still in bounding set", c)
+		}
+	}
+
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	data := [2]unix.CapUserData{}
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("get capabilities: %v", err)
+	}
+	data[0].Inheritable = 0
+	data[1].Inheritable = 0
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("clear inheritable capabilities: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// startWithoutPrivileges is not supported on this platform.
+func startWithoutPrivileges(_ *exec.Cmd) error {
+	return fmt.Errorf("dropping privileges is not supported on this platform")
+}
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"sync"
+)
+
+// TailBuffer is a ring buffer that keeps the last Size bytes written to it.
+type TailBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	size      int
+	truncated bool
+}
+
+func NewTailBuffer(size int) *TailBuffer {
+	return &TailBuffer{
+		buf:  make([]byte, 0, size),
+		size: size,
+	}
+}
+
+// Write appends p to the buffer and drops the oldest bytes if the buffer is full.
+func (b *TailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(p)
+	if b.size <= 0 {
+		b.truncated = b.truncated || n > 0
+		return n, nil
+	}
+	if n >= b.size {
+		b.truncated = b.truncated || n > b.size || len(b.buf) > 0
+		b.buf = append(b.buf[:0], p[n-b.size:]...)
+		return n, nil
+	}
+	if overflow := len(b.buf) + n - b.size; overflow > 0 {
+		b.truncated = true
+		b.buf = append(b.buf[:0], b.buf[overflow:]...)
+	}
+	b.buf = append(b.buf, p...)
+	return n, nil
+}
+
+// String returns buffered bytes as a string.
+func (b *TailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// Truncated returns true if some bytes were dropped.
+func (b *TailBuffer) Truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.truncated
+}
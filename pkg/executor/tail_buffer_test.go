@@ -0,0 +1,27 @@
+package executor
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_TailBuffer(t *testing.T) {
+	g := NewWithT(t)
+
+	b := NewTailBuffer(8)
+	_, _ = b.Write([]byte("abc"))
+	g.Expect(b.String()).To(Equal("abc"))
+	g.Expect(b.Truncated()).To(BeFalse())
+
+	_, _ = b.Write([]byte("defgh"))
+	g.Expect(b.String()).To(Equal("abcdefgh"))
+	g.Expect(b.Truncated()).To(BeFalse())
+
+	_, _ = b.Write([]byte("ij"))
+	g.Expect(b.String()).To(Equal("cdefghij"))
+	g.Expect(b.Truncated()).To(BeTrue())
+
+	_, _ = b.Write([]byte("0123456789"))
+	g.Expect(b.String()).To(Equal("23456789"))
+}
@@ -46,7 +46,8 @@ func (bc BindingContext) Map() map[string]interface{} {
 	switch bc.Metadata.Version {
 	case "v0":
 		return bc.MapV0()
-	case "v1":
+	case "v1", "v2":
+		// v2 has the same binding context format.
 		return bc.MapV1()
 	default:
 		log.Errorf("Possible bug!!! Call Map for BindingContext without version.")
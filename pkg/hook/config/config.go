@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"reflect"
 
 	"sigs.k8s.io/yaml"
 
@@ -16,6 +17,7 @@ type HookConfig struct {
 	// versioned raw config values
 	V0 *HookConfigV0
 	V1 *HookConfigV1
+	V2 *HookConfigV2
 
 	// effective config values
 	OnStartup            *OnStartupConfig
@@ -81,6 +83,17 @@ func (c *HookConfig) ConvertAndCheck(data []byte) error {
 		if err != nil {
 			return err
 		}
+	case "v2":
+		configV2 := &HookConfigV2{}
+		err := yaml.Unmarshal(data, configV2)
+		if err != nil {
+			return fmt.Errorf("unmarshal HookConfig v2: %s", err)
+		}
+		c.V2 = configV2
+		err = configV2.ConvertAndCheck(c)
+		if err != nil {
+			return err
+		}
 	default:
 		// NOTE: this should not happen
 		return fmt.Errorf("version '%s' is unsupported", c.Version)
@@ -118,6 +131,67 @@ func (c *HookConfig) HasBinding(binding BindingType) bool {
 	return false
 }
 
+// ChangedBindings returns binding types with different configurations in c and other.
+// All binding types are considered changed if config versions are different.
+func (c *HookConfig) ChangedBindings(other *HookConfig) []BindingType {
+	res := []BindingType{}
+
+	for _, binding := range []BindingType{OnStartup, Schedule, OnKubernetesEvent, KubernetesValidating, KubernetesConversion} {
+		if c.Version != other.Version || !reflect.DeepEqual(c.versionedBinding(binding), other.versionedBinding(binding)) {
+			res = append(res, binding)
+		}
+	}
+
+	return res
+}
+
+// versionedBinding returns a versioned raw configuration for the binding type.
+func (c *HookConfig) versionedBinding(binding BindingType) interface{} {
+	switch {
+	case c.V0 != nil:
+		switch binding {
+		case OnStartup:
+			return c.V0.OnStartup
+		case Schedule:
+			return c.V0.Schedule
+		case OnKubernetesEvent:
+			return c.V0.OnKubernetesEvent
+		}
+	case c.V1 != nil:
+		switch binding {
+		case OnStartup:
+			return c.V1.OnStartup
+		case Schedule:
+			return c.V1.Schedule
+		case OnKubernetesEvent:
+			return c.V1.OnKubernetesEvent
+		case KubernetesValidating:
+			return c.V1.KubernetesValidating
+		case KubernetesConversion:
+			return c.V1.KubernetesConversion
+		}
+	case c.V2 != nil:
+		// Queue and allowFailure from settings are defaults for schedule and kubernetes bindings.
+		var defaults *BindingSettingsV2
+		if c.V2.Settings != nil {
+			defaults = &c.V2.Settings.BindingSettingsV2
+		}
+		switch binding {
+		case OnStartup:
+			return c.V2.OnStartup
+		case Schedule:
+			return []interface{}{c.V2.Schedule, effectiveBindingSettings(defaults, nil)}
+		case OnKubernetesEvent:
+			return []interface{}{c.V2.OnKubernetesEvent, effectiveBindingSettings(defaults, nil)}
+		case KubernetesValidating:
+			return c.V2.KubernetesValidating
+		case KubernetesConversion:
+			return c.V2.KubernetesConversion
+		}
+	}
+	return nil
+}
+
 func (c *HookConfig) ConvertOnStartup(value interface{}) (*OnStartupConfig, error) {
 	floatValue, err := ConvertFloatForBinding(value, "onStartup")
 	if err != nil || floatValue == nil {
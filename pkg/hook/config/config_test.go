@@ -9,6 +9,9 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 	v1 "k8s.io/api/admissionregistration/v1"
+
+	. "github.com/flant/shell-operator/pkg/hook/types"
+	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
 )
 
 func Test_HookConfig_VersionedConfig_LoadAndValidate(t *testing.T) {
@@ -773,6 +776,271 @@ configVersion: v1
 settings:
   executionMinInterval: 30ks
   executionBurst: 1
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+			},
+		},
+		{
+			"v1 settings with executionTimeout",
+			`
+configVersion: v1
+settings:
+  executionTimeout: 2m
+schedule:
+- name: every-minute
+  crontab: "* * * * *"
+  executionTimeout: 30s
+kubernetes:
+- name: pods
+  kind: Pod
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(hookConfig.Settings).NotTo(BeNil())
+				g.Expect(hookConfig.Settings.ExecutionTimeout).To(Equal(2 * time.Minute))
+				g.Expect(hookConfig.Settings.ExecutionMinInterval).To(Equal(time.Duration(0)))
+				g.Expect(hookConfig.Schedules[0].ExecutionTimeout).To(Equal(30 * time.Second))
+				g.Expect(hookConfig.OnKubernetesEvents[0].ExecutionTimeout).To(Equal(time.Duration(0)))
+			},
+		},
+		{
+			"v1 settings with invalid executionTimeout",
+			`
+configVersion: v1
+settings:
+  executionTimeout: -5s
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+			},
+		},
+		{
+			"v1 kubernetes with invalid executionTimeout",
+			`
+configVersion: v1
+kubernetes:
+- kind: Pod
+  executionTimeout: 10 minutes
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+			},
+		},
+		{
+			"v1 kubernetes with resynchronizationPeriod",
+			`
+configVersion: v1
+kubernetes:
+- name: pods
+  kind: Pod
+  resynchronizationPeriod: 10m
+- name: nodes
+  kind: Node
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(hookConfig.OnKubernetesEvents[0].ResynchronizationPeriod).To(Equal(10 * time.Minute))
+				g.Expect(hookConfig.OnKubernetesEvents[0].Monitor.ResynchronizationPeriod).To(Equal(10 * time.Minute))
+				g.Expect(hookConfig.OnKubernetesEvents[1].ResynchronizationPeriod).To(Equal(time.Duration(0)))
+			},
+		},
+		{
+			"v1 kubernetes with invalid resynchronizationPeriod",
+			`
+configVersion: v1
+kubernetes:
+- kind: Pod
+  resynchronizationPeriod: 0s
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+				g.Expect(err.Error()).Should(ContainSubstring("resynchronizationPeriod should be positive"))
+			},
+		},
+		{
+			"v1 kubernetes with watchMode",
+			`
+configVersion: v1
+kubernetes:
+- name: pods
+  kind: Pod
+  watchMode: metadata
+- name: nodes
+  kind: Node
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(hookConfig.OnKubernetesEvents[0].Monitor.WatchMode).To(Equal(WatchModeMetadata))
+				g.Expect(hookConfig.OnKubernetesEvents[1].Monitor.WatchMode).To(Equal(KubeWatchMode("")))
+			},
+		},
+		{
+			"v1 kubernetes with waitForCRD",
+			`
+configVersion: v1
+kubernetes:
+- name: crontabs
+  apiVersion: stable.example.com/v1
+  kind: CronTab
+  waitForCRD: true
+- name: pods
+  kind: Pod
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(hookConfig.OnKubernetesEvents[0].Monitor.WaitForCRD).To(BeTrue())
+				g.Expect(hookConfig.OnKubernetesEvents[1].Monitor.WaitForCRD).To(BeFalse())
+			},
+		},
+		{
+			"v1 kubernetes with invalid watchMode",
+			`
+configVersion: v1
+kubernetes:
+- kind: Pod
+  watchMode: spec
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+				g.Expect(err.Error()).Should(ContainSubstring("watchMode"))
+			},
+		},
+		{
+			"v1 settings with worker mode",
+			`
+configVersion: v1
+settings:
+  executionMode: worker
+  workerStart: onConfig
+  workerHealthCheckPeriod: 30s
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(hookConfig.Settings.ExecutionMode).To(Equal("worker"))
+				g.Expect(hookConfig.Settings.WorkerStart).To(Equal("onConfig"))
+				g.Expect(hookConfig.Settings.WorkerHealthCheckPeriod).To(Equal(30 * time.Second))
+			},
+		},
+		{
+			"v1 settings with limits",
+			`
+configVersion: v1
+settings:
+  limits:
+    memory: 256Mi
+    cpuTime: 30s
+    nofile: 1024
+    nproc: 64
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(hookConfig.Settings.Limits).NotTo(BeNil())
+				g.Expect(hookConfig.Settings.Limits.Memory).To(Equal(int64(256 * 1024 * 1024)))
+				g.Expect(hookConfig.Settings.Limits.AddressSpace).To(Equal(int64(0)))
+				g.Expect(hookConfig.Settings.Limits.CPUTime).To(Equal(30 * time.Second))
+				g.Expect(hookConfig.Settings.Limits.NoFile).To(Equal(uint64(1024)))
+				g.Expect(hookConfig.Settings.Limits.NProc).To(Equal(uint64(64)))
+			},
+		},
+		{
+			"v1 settings with invalid limits",
+			`
+configVersion: v1
+settings:
+  limits:
+    memory: 256 megabytes
+    nofile: 0
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+			},
+		},
+		{
+			"v1 settings with env, workingDir and args",
+			`
+configVersion: v1
+settings:
+  env:
+    LOG_FORMAT: json
+    RETRIES: 3
+    TOKEN:
+      valueFrom:
+        file: /var/run/secrets/token
+    CLUSTER:
+      valueFrom:
+        env: CLUSTER_NAME
+  workingDir: ../lib
+  args: ["--verbose"]
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(hookConfig.Settings.Env).To(Equal([]EnvVar{
+					{Name: "CLUSTER", ValueFromEnv: "CLUSTER_NAME"},
+					{Name: "LOG_FORMAT", Value: "json"},
+					{Name: "RETRIES", Value: "3"},
+					{Name: "TOKEN", ValueFromFile: "/var/run/secrets/token"},
+				}))
+				g.Expect(hookConfig.Settings.WorkingDir).To(Equal("../lib"))
+				g.Expect(hookConfig.Settings.Args).To(Equal([]string{"--verbose"}))
+			},
+		},
+		{
+			"v1 settings with invalid env",
+			`
+configVersion: v1
+settings:
+  env:
+    TOKEN:
+      value: abc
+      valueFrom:
+        file: /var/run/secrets/token
+    2FA: "yes"
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring("mutually exclusive"))
+				g.Expect(err.Error()).To(ContainSubstring("invalid name"))
+			},
+		},
+		{
+			"v1 settings with runAs",
+			`
+configVersion: v1
+settings:
+  runAs:
+    uid: 1000
+    groups: [2000, 2001]
+  dropPrivileges: true
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(hookConfig.Settings.Security).NotTo(BeNil())
+				g.Expect(hookConfig.Settings.Security.RunAsUser).To(BeTrue())
+				g.Expect(hookConfig.Settings.Security.Uid).To(Equal(uint32(1000)))
+				g.Expect(hookConfig.Settings.Security.Gid).To(Equal(uint32(1000)))
+				g.Expect(hookConfig.Settings.Security.Groups).To(Equal([]uint32{2000, 2001}))
+				g.Expect(hookConfig.Settings.Security.DropPrivileges).To(BeTrue())
+			},
+		},
+		{
+			"v1 settings with runAs without uid",
+			`
+configVersion: v1
+settings:
+  runAs:
+    gid: 1000
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+			},
+		},
+		{
+			"v1 settings with worker options in exec mode",
+			`
+configVersion: v1
+settings:
+  workerStart: onConfig
 `,
 			func() {
 				g.Expect(err).Should(HaveOccurred())
@@ -1095,3 +1363,52 @@ func Test_MergeArrays(t *testing.T) {
 		})
 	}
 }
+
+func Test_HookConfig_ChangedBindings(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected []BindingType
+	}{
+		{
+			"same config",
+			`{"configVersion":"v1", "schedule":[{"crontab":"* * * * *"}], "kubernetes":[{"kind":"Pod"}]}`,
+			`{"configVersion":"v1", "schedule":[{"crontab":"* * * * *"}], "kubernetes":[{"kind":"Pod"}]}`,
+			[]BindingType{},
+		},
+		{
+			"changed schedule and settings",
+			`{"configVersion":"v1", "schedule":[{"crontab":"* * * * *"}], "kubernetes":[{"kind":"Pod"}]}`,
+			`{"configVersion":"v1", "schedule":[{"crontab":"*/5 * * * *"}], "kubernetes":[{"kind":"Pod"}], "settings":{"executionTimeout":"10s"}}`,
+			[]BindingType{Schedule},
+		},
+		{
+			"added onStartup and removed kubernetes",
+			`{"configVersion":"v1", "kubernetes":[{"kind":"Pod"}]}`,
+			`{"configVersion":"v1", "onStartup": 10}`,
+			[]BindingType{OnStartup, OnKubernetesEvent},
+		},
+		{
+			"different versions",
+			`{"onStartup": 10}`,
+			`{"configVersion":"v1", "onStartup": 10}`,
+			[]BindingType{OnStartup, Schedule, OnKubernetesEvent, KubernetesValidating, KubernetesConversion},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oldConfig := &HookConfig{}
+			err := oldConfig.LoadAndValidate([]byte(test.old))
+			g.Expect(err).ShouldNot(HaveOccurred())
+			newConfig := &HookConfig{}
+			err = newConfig.LoadAndValidate([]byte(test.new))
+			g.Expect(err).ShouldNot(HaveOccurred())
+
+			g.Expect(oldConfig.ChangedBindings(newConfig)).To(Equal(test.expected))
+		})
+	}
+}
@@ -1,13 +1,19 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math"
+	"regexp"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"gopkg.in/robfig/cron.v2"
 	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
@@ -39,6 +45,7 @@ type ScheduleConfigV1 struct {
 	IncludeSnapshotsFrom []string `json:"includeSnapshotsFrom"`
 	Queue                string   `json:"queue"`
 	Group                string   `json:"group,omitempty"`
+	ExecutionTimeout     string   `json:"executionTimeout,omitempty"`
 }
 
 // version 1 of kubernetes event configuration
@@ -59,9 +66,12 @@ type OnKubernetesEventConfigV1 struct {
 	JqFilter                     string                   `json:"jqFilter,omitempty"`
 	AllowFailure                 bool                     `json:"allowFailure,omitempty"`
 	ResynchronizationPeriod      string                   `json:"resynchronizationPeriod,omitempty"`
+	WatchMode                    KubeWatchMode            `json:"watchMode,omitempty"`
+	WaitForCRD                   string                   `json:"waitForCRD,omitempty"`
 	IncludeSnapshotsFrom         []string                 `json:"includeSnapshotsFrom,omitempty"`
 	Queue                        string                   `json:"queue,omitempty"`
 	Group                        string                   `json:"group,omitempty"`
+	ExecutionTimeout             string                   `json:"executionTimeout,omitempty"`
 }
 
 type KubeNameSelectorV1 NameSelector
@@ -76,10 +86,10 @@ type KubernetesValidatingConfigV1 struct {
 	IncludeSnapshotsFrom []string                 `json:"includeSnapshotsFrom,omitempty"`
 	Group                string                   `json:"group,omitempty"`
 	Rules                []v1.RuleWithOperations  `json:"rules,omitempty"`
-	FailurePolicy        *v1.FailurePolicyType    `json:"failurePolicy"`
+	FailurePolicy        *v1.FailurePolicyType    `json:"failurePolicy,omitempty"`
 	LabelSelector        *metav1.LabelSelector    `json:"labelSelector,omitempty"`
 	Namespace            *KubeNamespaceSelectorV1 `json:"namespace,omitempty"`
-	SideEffects          *v1.SideEffectClass      `json:"sideEffects"`
+	SideEffects          *v1.SideEffectClass      `json:"sideEffects,omitempty"`
 	TimeoutSeconds       *int32                   `json:"timeoutSeconds,omitempty"`
 }
 
@@ -94,8 +104,78 @@ type KubernetesConversionConfigV1 struct {
 
 // version 1 of hook settings
 type SettingsV1 struct {
-	ExecutionMinInterval string `json:"executionMinInterval,omitempty"`
-	ExecutionBurst       string `json:"executionBurst,omitempty"`
+	ExecutionMinInterval    string              `json:"executionMinInterval,omitempty"`
+	ExecutionBurst          string              `json:"executionBurst,omitempty"`
+	ExecutionTimeout        string              `json:"executionTimeout,omitempty"`
+	BindingContextDelivery  string              `json:"bindingContextDelivery,omitempty"`
+	ExecutionMode           string              `json:"executionMode,omitempty"`
+	WorkerStart             string              `json:"workerStart,omitempty"`
+	WorkerHealthCheckPeriod string              `json:"workerHealthCheckPeriod,omitempty"`
+	Limits                  *LimitsV1           `json:"limits,omitempty"`
+	Env                     map[string]EnvVarV1 `json:"env,omitempty"`
+	WorkingDir              string              `json:"workingDir,omitempty"`
+	Args                    []string            `json:"args,omitempty"`
+	EnvAllowlist            []string            `json:"envAllowlist,omitempty"`
+	EnvDenylist             []string            `json:"envDenylist,omitempty"`
+	RunAs                   *RunAsV1            `json:"runAs,omitempty"`
+	DropPrivileges          bool                `json:"dropPrivileges,omitempty"`
+	DependsOn               []string            `json:"dependsOn,omitempty"`
+}
+
+// RunAsV1 defines a user for the hook process.
+type RunAsV1 struct {
+	Uid    *int64  `json:"uid"`
+	Gid    *int64  `json:"gid,omitempty"`
+	Groups []int64 `json:"groups,omitempty"`
+}
+
+// EnvVarV1 is a value of the environment variable. It is a string or
+// an object with 'value' or 'valueFrom' fields.
+type EnvVarV1 struct {
+	Value     string          `json:"value,omitempty"`
+	ValueFrom *EnvVarSourceV1 `json:"valueFrom,omitempty"`
+}
+
+// EnvVarSourceV1 defines a source for the environment variable value.
+type EnvVarSourceV1 struct {
+	// File is a path to the file with the value. Relative path is relative to the hook's directory.
+	File string `json:"file,omitempty"`
+	// Env is a name of the Shell-operator's environment variable.
+	Env string `json:"env,omitempty"`
+}
+
+// UnmarshalJSON accepts scalar values as well as objects.
+func (e *EnvVarV1) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		var value interface{}
+		if err := json.Unmarshal(trimmed, &value); err != nil {
+			return err
+		}
+		switch v := value.(type) {
+		case string:
+			e.Value = v
+		case nil:
+			e.Value = ""
+		case float64, bool:
+			// Keep numbers and booleans as written.
+			e.Value = string(trimmed)
+		default:
+			return fmt.Errorf("env value should be a string, a number, a boolean or an object")
+		}
+		return nil
+	}
+	type envVar EnvVarV1
+	return json.Unmarshal(trimmed, (*envVar)(e))
+}
+
+// LimitsV1 defines resource limits for the hook process.
+type LimitsV1 struct {
+	Memory       string `json:"memory,omitempty"`
+	AddressSpace string `json:"addressSpace,omitempty"`
+	CpuTime      string `json:"cpuTime,omitempty"`
+	NoFile       int64  `json:"nofile,omitempty"`
+	NProc        int64  `json:"nproc,omitempty"`
 }
 
 // ConvertAndCheck fills non-versioned structures and run inter-field checks not covered by OpenAPI schemas.
@@ -156,6 +236,7 @@ func (cv1 *HookConfigV1) ConvertAndCheck(c *HookConfig) (err error) {
 			kubeConfig.Queue = kubeCfg.Queue
 		}
 		kubeConfig.Group = kubeCfg.Group
+		kubeConfig.ExecutionTimeout, _ = time.ParseDuration(kubeCfg.ExecutionTimeout)
 
 		// ExecuteHookOnSynchronization is enabled by default.
 		kubeConfig.ExecuteHookOnSynchronization = true
@@ -176,6 +257,14 @@ func (cv1 *HookConfigV1) ConvertAndCheck(c *HookConfig) (err error) {
 		}
 		kubeConfig.Monitor.KeepFullObjectsInMemory = kubeConfig.KeepFullObjectsInMemory
 
+		kubeConfig.ResynchronizationPeriod, _ = time.ParseDuration(kubeCfg.ResynchronizationPeriod)
+		kubeConfig.Monitor.ResynchronizationPeriod = kubeConfig.ResynchronizationPeriod
+
+		kubeConfig.Monitor.WatchMode = kubeCfg.WatchMode
+
+		// WaitForCRD is disabled by default.
+		kubeConfig.Monitor.WaitForCRD = kubeCfg.WaitForCRD == "true"
+
 		c.OnKubernetesEvents = append(c.OnKubernetesEvents, kubeConfig)
 	}
 
@@ -206,7 +295,7 @@ func (cv1 *HookConfigV1) ConvertAndCheck(c *HookConfig) (err error) {
 
 	// Validating webhooks
 	c.KubernetesValidating = []ValidatingConfig{}
-	for i, rawValidating := range c.V1.KubernetesValidating {
+	for i, rawValidating := range cv1.KubernetesValidating {
 		err := cv1.CheckValidating(c.OnKubernetesEvents, rawValidating)
 		if err != nil {
 			return fmt.Errorf("invalid kubernetesValidating config [%d]: %v", i, err)
@@ -231,7 +320,7 @@ func (cv1 *HookConfigV1) ConvertAndCheck(c *HookConfig) (err error) {
 
 	// Conversion webhooks.
 	c.KubernetesConversion = []ConversionConfig{}
-	for i, rawConversion := range c.V1.KubernetesConversion {
+	for i, rawConversion := range cv1.KubernetesConversion {
 		err := cv1.CheckConversion(c.OnKubernetesEvents, rawConversion)
 		if err != nil {
 			return fmt.Errorf("invalid kubernetesCustomResourceConversion config [%d]: %v", i, err)
@@ -316,6 +405,7 @@ func (cv1 *HookConfigV1) ConvertSchedule(schV1 ScheduleConfigV1) (ScheduleConfig
 		res.Queue = schV1.Queue
 	}
 	res.Group = schV1.Group
+	res.ExecutionTimeout, _ = time.ParseDuration(schV1.ExecutionTimeout)
 
 	return res, nil
 }
@@ -334,6 +424,11 @@ func (cv1 *HookConfigV1) CheckSchedule(kubeConfigs []OnKubernetesEventConfig, sc
 		}
 	}
 
+	err = CheckExecutionTimeout(schV1.ExecutionTimeout)
+	if err != nil {
+		allErr = multierror.Append(allErr, err)
+	}
+
 	return allErr
 }
 
@@ -369,6 +464,16 @@ func (сv1 *HookConfigV1) CheckOnKubernetesEvent(kubeCfg OnKubernetesEventConfig
 		}
 	}
 
+	err := CheckExecutionTimeout(kubeCfg.ExecutionTimeout)
+	if err != nil {
+		allErr = multierror.Append(allErr, err)
+	}
+
+	err = CheckResynchronizationPeriod(kubeCfg.ResynchronizationPeriod)
+	if err != nil {
+		allErr = multierror.Append(allErr, err)
+	}
+
 	return allErr
 }
 
@@ -482,21 +587,223 @@ func (cv1 *HookConfigV1) CheckAndConvertSettings(settings *SettingsV1) (out *Set
 		return nil, nil
 	}
 
-	interval, err := time.ParseDuration(settings.ExecutionMinInterval)
+	out = &Settings{}
+
+	if settings.ExecutionMinInterval != "" {
+		interval, err := time.ParseDuration(settings.ExecutionMinInterval)
+		if err != nil {
+			allErr = multierror.Append(allErr, fmt.Errorf("executionMinInterval is invalid: %v", err))
+		}
+		out.ExecutionMinInterval = interval
+	}
+
+	if settings.ExecutionBurst != "" {
+		burst, err := strconv.ParseInt(settings.ExecutionBurst, 10, 32)
+		if err != nil {
+			allErr = multierror.Append(allErr, fmt.Errorf("executionBurst is invalid: %v", err))
+		}
+		out.ExecutionBurst = int(burst)
+	}
+
+	err := CheckExecutionTimeout(settings.ExecutionTimeout)
+	if err != nil {
+		allErr = multierror.Append(allErr, err)
+	} else {
+		out.ExecutionTimeout, _ = time.ParseDuration(settings.ExecutionTimeout)
+	}
+
+	out.BindingContextDelivery = settings.BindingContextDelivery
+	out.ExecutionMode = settings.ExecutionMode
+	out.WorkerStart = settings.WorkerStart
+
+	if settings.WorkerHealthCheckPeriod != "" {
+		period, err := time.ParseDuration(settings.WorkerHealthCheckPeriod)
+		if err != nil {
+			allErr = multierror.Append(allErr, fmt.Errorf("workerHealthCheckPeriod is invalid: %v", err))
+		}
+		out.WorkerHealthCheckPeriod = period
+	}
+	if out.ExecutionMode != ExecutionModeWorker && (settings.WorkerStart != "" || settings.WorkerHealthCheckPeriod != "") {
+		allErr = multierror.Append(allErr, fmt.Errorf("workerStart and workerHealthCheckPeriod require executionMode: %s", ExecutionModeWorker))
+	}
+
+	out.Env, err = CheckAndConvertEnv(settings.Env)
 	if err != nil {
-		allErr = multierror.Append(allErr, fmt.Errorf("executionMinInterval is invalid: %v", err))
+		allErr = multierror.Append(allErr, err)
 	}
+	out.WorkingDir = settings.WorkingDir
+	out.Args = settings.Args
+	out.EnvAllowlist = settings.EnvAllowlist
+	out.EnvDenylist = settings.EnvDenylist
+	out.DependsOn = settings.DependsOn
 
-	burst, err := strconv.ParseInt(settings.ExecutionBurst, 10, 32)
+	out.Security, err = CheckAndConvertSecurity(settings.RunAs, settings.DropPrivileges)
 	if err != nil {
-		allErr = multierror.Append(allErr, fmt.Errorf("executionMinInterval is invalid: %v", err))
+		allErr = multierror.Append(allErr, err)
+	}
+
+	if settings.Limits != nil {
+		out.Limits, err = CheckAndConvertLimits(settings.Limits)
+		if err != nil {
+			allErr = multierror.Append(allErr, err)
+		}
+	}
+
+	if allErr != nil {
+		return nil, allErr
+	}
+
+	return out, nil
+}
+
+// envNameRe is a pattern for environment variable names.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CheckAndConvertEnv validates environment variables and returns them sorted by name.
+func CheckAndConvertEnv(env map[string]EnvVarV1) (out []EnvVar, allErr error) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v := env[name]
+		if !envNameRe.MatchString(name) {
+			allErr = multierror.Append(allErr, fmt.Errorf("env '%s': invalid name", name))
+			continue
+		}
+		envVar := EnvVar{Name: name, Value: v.Value}
+		if v.ValueFrom != nil {
+			if v.Value != "" {
+				allErr = multierror.Append(allErr, fmt.Errorf("env '%s': value and valueFrom are mutually exclusive", name))
+				continue
+			}
+			if (v.ValueFrom.File == "") == (v.ValueFrom.Env == "") {
+				allErr = multierror.Append(allErr, fmt.Errorf("env '%s': valueFrom should have either 'file' or 'env'", name))
+				continue
+			}
+			envVar.ValueFromFile = v.ValueFrom.File
+			envVar.ValueFromEnv = v.ValueFrom.Env
+		}
+		out = append(out, envVar)
+	}
+
+	return out, allErr
+}
+
+// CheckAndConvertSecurity returns a security context for the hook process
+// or nil if the hook should run as Shell-operator. Gid defaults to uid.
+func CheckAndConvertSecurity(runAs *RunAsV1, dropPrivileges bool) (*SecurityContext, error) {
+	if runAs == nil && !dropPrivileges {
+		return nil, nil
+	}
+	out := &SecurityContext{
+		DropPrivileges: dropPrivileges,
+	}
+	if runAs == nil {
+		return out, nil
+	}
+
+	var allErr error
+	checkId := func(field string, id int64) uint32 {
+		if id < 0 || id > math.MaxUint32 {
+			allErr = multierror.Append(allErr, fmt.Errorf("runAs.%s %d is invalid", field, id))
+			return 0
+		}
+		return uint32(id)
+	}
+
+	if runAs.Uid == nil {
+		return nil, fmt.Errorf("runAs.uid is required")
+	}
+	out.RunAsUser = true
+	out.Uid = checkId("uid", *runAs.Uid)
+	out.Gid = out.Uid
+	if runAs.Gid != nil {
+		out.Gid = checkId("gid", *runAs.Gid)
+	}
+	out.Groups = make([]uint32, 0, len(runAs.Groups))
+	for _, group := range runAs.Groups {
+		out.Groups = append(out.Groups, checkId("groups", group))
+	}
+
+	if allErr != nil {
+		return nil, allErr
+	}
+	return out, nil
+}
+
+// CheckAndConvertLimits parses quantities and durations of resource limits.
+func CheckAndConvertLimits(limits *LimitsV1) (out *ResourceLimits, allErr error) {
+	out = &ResourceLimits{}
+
+	if limits.Memory != "" {
+		q, err := resource.ParseQuantity(limits.Memory)
+		if err != nil || q.Value() <= 0 {
+			allErr = multierror.Append(allErr, fmt.Errorf("limits.memory '%s' is invalid: should be a positive quantity", limits.Memory))
+		}
+		out.Memory = q.Value()
+	}
+
+	if limits.AddressSpace != "" {
+		q, err := resource.ParseQuantity(limits.AddressSpace)
+		if err != nil || q.Value() <= 0 {
+			allErr = multierror.Append(allErr, fmt.Errorf("limits.addressSpace '%s' is invalid: should be a positive quantity", limits.AddressSpace))
+		}
+		out.AddressSpace = q.Value()
+	}
+
+	if limits.CpuTime != "" {
+		cpuTime, err := time.ParseDuration(limits.CpuTime)
+		if err != nil || cpuTime <= 0 {
+			allErr = multierror.Append(allErr, fmt.Errorf("limits.cpuTime '%s' is invalid: should be a positive duration", limits.CpuTime))
+		}
+		out.CPUTime = cpuTime
+	}
+
+	if limits.NoFile < 0 {
+		allErr = multierror.Append(allErr, fmt.Errorf("limits.nofile should be positive"))
+	}
+	out.NoFile = uint64(limits.NoFile)
+
+	if limits.NProc < 0 {
+		allErr = multierror.Append(allErr, fmt.Errorf("limits.nproc should be positive"))
 	}
+	out.NProc = uint64(limits.NProc)
+
 	if allErr != nil {
 		return nil, allErr
 	}
+	return out, nil
+}
+
+// CheckResynchronizationPeriod validates an optional resynchronizationPeriod value.
+func CheckResynchronizationPeriod(value string) error {
+	if value == "" {
+		return nil
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("resynchronizationPeriod is invalid: %v", err)
+	}
+	if period <= 0 {
+		return fmt.Errorf("resynchronizationPeriod should be positive, got '%s'", value)
+	}
+	return nil
+}
 
-	return &Settings{
-		ExecutionMinInterval: interval,
-		ExecutionBurst:       int(burst),
-	}, nil
+// CheckExecutionTimeout validates an optional executionTimeout value.
+func CheckExecutionTimeout(value string) error {
+	if value == "" {
+		return nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("executionTimeout is invalid: %v", err)
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("executionTimeout should be positive, got '%s'", value)
+	}
+	return nil
 }
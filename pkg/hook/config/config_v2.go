@@ -0,0 +1,427 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/flant/shell-operator/pkg/hook/types"
+	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
+)
+
+// HookConfigV2 is a version 2 of the hook configuration. It has typed booleans
+// and durations. Queue, execution rate, timeout and allowFailure from settings
+// can be overridden for schedule and kubernetes bindings.
+type HookConfigV2 struct {
+	ConfigVersion        string                         `json:"configVersion"`
+	OnStartup            *float64                       `json:"onStartup,omitempty"`
+	Schedule             []ScheduleConfigV2             `json:"schedule,omitempty"`
+	OnKubernetesEvent    []OnKubernetesEventConfigV2    `json:"kubernetes,omitempty"`
+	KubernetesValidating []KubernetesValidatingConfigV1 `json:"kubernetesValidating,omitempty"`
+	KubernetesConversion []KubernetesConversionConfigV1 `json:"kubernetesCustomResourceConversion,omitempty"`
+	Settings             *SettingsV2                    `json:"settings,omitempty"`
+}
+
+// version 2 of schedule configuration
+type ScheduleConfigV2 struct {
+	Name                 string             `json:"name,omitempty"`
+	Crontab              string             `json:"crontab"`
+	IncludeSnapshotsFrom []string           `json:"includeSnapshotsFrom,omitempty"`
+	Group                string             `json:"group,omitempty"`
+	Settings             *BindingSettingsV2 `json:"settings,omitempty"`
+}
+
+// version 2 of kubernetes event configuration
+type OnKubernetesEventConfigV2 struct {
+	Name                         string                   `json:"name,omitempty"`
+	ApiVersion                   string                   `json:"apiVersion,omitempty"`
+	Kind                         string                   `json:"kind,omitempty"`
+	NameSelector                 *KubeNameSelectorV1      `json:"nameSelector,omitempty"`
+	LabelSelector                *metav1.LabelSelector    `json:"labelSelector,omitempty"`
+	FieldSelector                *KubeFieldSelectorV1     `json:"fieldSelector,omitempty"`
+	Namespace                    *KubeNamespaceSelectorV1 `json:"namespace,omitempty"`
+	JqFilter                     string                   `json:"jqFilter,omitempty"`
+	ExecuteHookOnEvents          []WatchEventType         `json:"executeHookOnEvent,omitempty"`
+	ExecuteHookOnSynchronization *bool                    `json:"executeHookOnSynchronization,omitempty"`
+	WaitForSynchronization       *bool                    `json:"waitForSynchronization,omitempty"`
+	KeepFullObjectsInMemory      *bool                    `json:"keepFullObjectsInMemory,omitempty"`
+	ResynchronizationPeriod      *Duration                `json:"resynchronizationPeriod,omitempty"`
+	WatchMode                    KubeWatchMode            `json:"watchMode,omitempty"`
+	WaitForCRD                   *bool                    `json:"waitForCRD,omitempty"`
+	IncludeSnapshotsFrom         []string                 `json:"includeSnapshotsFrom,omitempty"`
+	Group                        string                   `json:"group,omitempty"`
+	Settings                     *BindingSettingsV2       `json:"settings,omitempty"`
+}
+
+// BindingSettingsV2 are settings that can be overridden by a binding.
+type BindingSettingsV2 struct {
+	Queue                string    `json:"queue,omitempty"`
+	AllowFailure         *bool     `json:"allowFailure,omitempty"`
+	ExecutionTimeout     *Duration `json:"executionTimeout,omitempty"`
+	ExecutionMinInterval *Duration `json:"executionMinInterval,omitempty"`
+	ExecutionBurst       *int      `json:"executionBurst,omitempty"`
+}
+
+// version 2 of hook settings
+type SettingsV2 struct {
+	BindingSettingsV2
+	BindingContextDelivery  string              `json:"bindingContextDelivery,omitempty"`
+	ExecutionMode           string              `json:"executionMode,omitempty"`
+	WorkerStart             string              `json:"workerStart,omitempty"`
+	WorkerHealthCheckPeriod *Duration           `json:"workerHealthCheckPeriod,omitempty"`
+	Limits                  *LimitsV1           `json:"limits,omitempty"`
+	Env                     map[string]EnvVarV1 `json:"env,omitempty"`
+	WorkingDir              string              `json:"workingDir,omitempty"`
+	Args                    []string            `json:"args,omitempty"`
+	EnvAllowlist            []string            `json:"envAllowlist,omitempty"`
+	EnvDenylist             []string            `json:"envDenylist,omitempty"`
+	RunAs                   *RunAsV1            `json:"runAs,omitempty"`
+	DropPrivileges          bool                `json:"dropPrivileges,omitempty"`
+	DependsOn               []string            `json:"dependsOn,omitempty"`
+}
+
+// Duration is a duration in Go notation: "30s", "1m30s", etc.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration should be a string like '30s'")
+	}
+	value, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(value)
+	return nil
+}
+
+// ConvertAndCheck fills non-versioned structures and run inter-field checks not covered by OpenAPI schemas.
+//
+// Sections common with v1 are checked and converted by the v1 code, then
+// per-binding execution rate settings are added.
+func (cv2 *HookConfigV2) ConvertAndCheck(c *HookConfig) error {
+	err := cv2.toV1().ConvertAndCheck(c)
+	if err != nil {
+		return err
+	}
+
+	for i, cfg := range cv2.Schedule {
+		applyBindingRateSettings(&c.Schedules[i].CommonBindingConfig, cfg.Settings)
+	}
+	for i, cfg := range cv2.OnKubernetesEvent {
+		applyBindingRateSettings(&c.OnKubernetesEvents[i].CommonBindingConfig, cfg.Settings)
+	}
+
+	return nil
+}
+
+func applyBindingRateSettings(cfg *CommonBindingConfig, settings *BindingSettingsV2) {
+	if settings == nil {
+		return
+	}
+	if settings.ExecutionMinInterval != nil {
+		cfg.ExecutionMinInterval = time.Duration(*settings.ExecutionMinInterval)
+	}
+	if settings.ExecutionBurst != nil {
+		cfg.ExecutionBurst = *settings.ExecutionBurst
+	}
+}
+
+// toV1 returns a v1 configuration with effective values of queue, allowFailure
+// and executionTimeout for bindings. Per-binding execution rate is not a part of v1.
+func (cv2 *HookConfigV2) toV1() *HookConfigV1 {
+	cv1 := &HookConfigV1{
+		ConfigVersion:        "v1",
+		KubernetesValidating: cv2.KubernetesValidating,
+		KubernetesConversion: cv2.KubernetesConversion,
+	}
+	if cv2.OnStartup != nil {
+		cv1.OnStartup = *cv2.OnStartup
+	}
+
+	var defaults *BindingSettingsV2
+	if cv2.Settings != nil {
+		defaults = &cv2.Settings.BindingSettingsV2
+		cv1.Settings = cv2.Settings.toV1()
+	}
+
+	for _, cfg := range cv2.Schedule {
+		settings := effectiveBindingSettings(defaults, cfg.Settings)
+		cv1.Schedule = append(cv1.Schedule, ScheduleConfigV1{
+			Name:                 cfg.Name,
+			Crontab:              cfg.Crontab,
+			AllowFailure:         settings.AllowFailure != nil && *settings.AllowFailure,
+			IncludeSnapshotsFrom: cfg.IncludeSnapshotsFrom,
+			Queue:                settings.Queue,
+			Group:                cfg.Group,
+			ExecutionTimeout:     bindingExecutionTimeoutV1(cfg.Settings),
+		})
+	}
+
+	for _, cfg := range cv2.OnKubernetesEvent {
+		settings := effectiveBindingSettings(defaults, cfg.Settings)
+		cv1.OnKubernetesEvent = append(cv1.OnKubernetesEvent, OnKubernetesEventConfigV1{
+			Name:                         cfg.Name,
+			ExecuteHookOnEvents:          cfg.ExecuteHookOnEvents,
+			ExecuteHookOnSynchronization: boolToV1(cfg.ExecuteHookOnSynchronization),
+			WaitForSynchronization:       boolToV1(cfg.WaitForSynchronization),
+			KeepFullObjectsInMemory:      boolToV1(cfg.KeepFullObjectsInMemory),
+			ApiVersion:                   cfg.ApiVersion,
+			Kind:                         cfg.Kind,
+			NameSelector:                 cfg.NameSelector,
+			LabelSelector:                cfg.LabelSelector,
+			FieldSelector:                cfg.FieldSelector,
+			Namespace:                    cfg.Namespace,
+			JqFilter:                     cfg.JqFilter,
+			AllowFailure:                 settings.AllowFailure != nil && *settings.AllowFailure,
+			ResynchronizationPeriod:      durationToV1(cfg.ResynchronizationPeriod),
+			WatchMode:                    cfg.WatchMode,
+			WaitForCRD:                   boolToV1(cfg.WaitForCRD),
+			IncludeSnapshotsFrom:         cfg.IncludeSnapshotsFrom,
+			Queue:                        settings.Queue,
+			Group:                        cfg.Group,
+			ExecutionTimeout:             bindingExecutionTimeoutV1(cfg.Settings),
+		})
+	}
+
+	return cv1
+}
+
+func (s *SettingsV2) toV1() *SettingsV1 {
+	out := &SettingsV1{
+		ExecutionMinInterval:    durationToV1(s.ExecutionMinInterval),
+		ExecutionTimeout:        durationToV1(s.ExecutionTimeout),
+		BindingContextDelivery:  s.BindingContextDelivery,
+		ExecutionMode:           s.ExecutionMode,
+		WorkerStart:             s.WorkerStart,
+		WorkerHealthCheckPeriod: durationToV1(s.WorkerHealthCheckPeriod),
+		Limits:                  s.Limits,
+		Env:                     s.Env,
+		WorkingDir:              s.WorkingDir,
+		Args:                    s.Args,
+		EnvAllowlist:            s.EnvAllowlist,
+		EnvDenylist:             s.EnvDenylist,
+		RunAs:                   s.RunAs,
+		DropPrivileges:          s.DropPrivileges,
+		DependsOn:               s.DependsOn,
+	}
+	if s.ExecutionBurst != nil {
+		out.ExecutionBurst = strconv.Itoa(*s.ExecutionBurst)
+	}
+	return out
+}
+
+// effectiveBindingSettings returns queue and allowFailure from binding settings
+// or from hook settings. executionTimeout and execution rate of hook settings
+// are applied at runtime and are not inherited here.
+func effectiveBindingSettings(defaults *BindingSettingsV2, settings *BindingSettingsV2) BindingSettingsV2 {
+	res := BindingSettingsV2{}
+	if defaults != nil {
+		res.Queue = defaults.Queue
+		res.AllowFailure = defaults.AllowFailure
+	}
+	if settings != nil {
+		if settings.Queue != "" {
+			res.Queue = settings.Queue
+		}
+		if settings.AllowFailure != nil {
+			res.AllowFailure = settings.AllowFailure
+		}
+	}
+	return res
+}
+
+func bindingExecutionTimeoutV1(settings *BindingSettingsV2) string {
+	if settings == nil {
+		return ""
+	}
+	return durationToV1(settings.ExecutionTimeout)
+}
+
+func durationToV1(d *Duration) string {
+	if d == nil {
+		return ""
+	}
+	return d.String()
+}
+
+func boolToV1(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
+
+// ConvertV1ToV2 converts a v1 configuration into the v2 format. The effective
+// configuration is not changed. Deprecated 'watchEvent' field becomes 'executeHookOnEvent'.
+func ConvertV1ToV2(cv1 *HookConfigV1) (*HookConfigV2, error) {
+	cv2 := &HookConfigV2{
+		ConfigVersion:        "v2",
+		KubernetesValidating: cv1.KubernetesValidating,
+		KubernetesConversion: cv1.KubernetesConversion,
+	}
+
+	if cv1.OnStartup != nil {
+		order, err := ConvertFloatForBinding(cv1.OnStartup, "onStartup")
+		if err != nil {
+			return nil, err
+		}
+		cv2.OnStartup = order
+	}
+
+	if cv1.Settings != nil {
+		settings, err := convertSettingsV1ToV2(cv1.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("settings: %v", err)
+		}
+		cv2.Settings = settings
+	}
+
+	for i, cfg := range cv1.Schedule {
+		settings, err := bindingSettingsV1ToV2(cfg.Queue, cfg.AllowFailure, cfg.ExecutionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("schedule[%d]: %v", i, err)
+		}
+		cv2.Schedule = append(cv2.Schedule, ScheduleConfigV2{
+			Name:                 cfg.Name,
+			Crontab:              cfg.Crontab,
+			IncludeSnapshotsFrom: cfg.IncludeSnapshotsFrom,
+			Group:                cfg.Group,
+			Settings:             settings,
+		})
+	}
+
+	for i, cfg := range cv1.OnKubernetesEvent {
+		// There is no mode in v2, monitors are always in the default mode.
+		if cfg.Mode != "" {
+			return nil, fmt.Errorf("kubernetes[%d]: mode '%s' is not supported in v2", i, cfg.Mode)
+		}
+		settings, err := bindingSettingsV1ToV2(cfg.Queue, cfg.AllowFailure, cfg.ExecutionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes[%d]: %v", i, err)
+		}
+		kubeCfg := OnKubernetesEventConfigV2{
+			Name:                 cfg.Name,
+			ApiVersion:           cfg.ApiVersion,
+			Kind:                 cfg.Kind,
+			NameSelector:         cfg.NameSelector,
+			LabelSelector:        cfg.LabelSelector,
+			FieldSelector:        cfg.FieldSelector,
+			Namespace:            cfg.Namespace,
+			JqFilter:             cfg.JqFilter,
+			ExecuteHookOnEvents:  cfg.ExecuteHookOnEvents,
+			WatchMode:            cfg.WatchMode,
+			IncludeSnapshotsFrom: cfg.IncludeSnapshotsFrom,
+			Group:                cfg.Group,
+			Settings:             settings,
+		}
+		if kubeCfg.ExecuteHookOnEvents == nil {
+			kubeCfg.ExecuteHookOnEvents = cfg.WatchEventTypes
+		}
+		if kubeCfg.ExecuteHookOnSynchronization, err = boolFromV1(cfg.ExecuteHookOnSynchronization); err != nil {
+			return nil, fmt.Errorf("kubernetes[%d]: executeHookOnSynchronization: %v", i, err)
+		}
+		if kubeCfg.WaitForSynchronization, err = boolFromV1(cfg.WaitForSynchronization); err != nil {
+			return nil, fmt.Errorf("kubernetes[%d]: waitForSynchronization: %v", i, err)
+		}
+		if kubeCfg.KeepFullObjectsInMemory, err = boolFromV1(cfg.KeepFullObjectsInMemory); err != nil {
+			return nil, fmt.Errorf("kubernetes[%d]: keepFullObjectsInMemory: %v", i, err)
+		}
+		if kubeCfg.ResynchronizationPeriod, err = durationFromV1(cfg.ResynchronizationPeriod); err != nil {
+			return nil, fmt.Errorf("kubernetes[%d]: resynchronizationPeriod: %v", i, err)
+		}
+		if kubeCfg.WaitForCRD, err = boolFromV1(cfg.WaitForCRD); err != nil {
+			return nil, fmt.Errorf("kubernetes[%d]: waitForCRD: %v", i, err)
+		}
+		cv2.OnKubernetesEvent = append(cv2.OnKubernetesEvent, kubeCfg)
+	}
+
+	return cv2, nil
+}
+
+func convertSettingsV1ToV2(s *SettingsV1) (out *SettingsV2, err error) {
+	out = &SettingsV2{
+		BindingContextDelivery: s.BindingContextDelivery,
+		ExecutionMode:          s.ExecutionMode,
+		WorkerStart:            s.WorkerStart,
+		Limits:                 s.Limits,
+		Env:                    s.Env,
+		WorkingDir:             s.WorkingDir,
+		Args:                   s.Args,
+		EnvAllowlist:           s.EnvAllowlist,
+		EnvDenylist:            s.EnvDenylist,
+		RunAs:                  s.RunAs,
+		DropPrivileges:         s.DropPrivileges,
+		DependsOn:              s.DependsOn,
+	}
+	if out.ExecutionMinInterval, err = durationFromV1(s.ExecutionMinInterval); err != nil {
+		return nil, fmt.Errorf("executionMinInterval: %v", err)
+	}
+	if out.ExecutionTimeout, err = durationFromV1(s.ExecutionTimeout); err != nil {
+		return nil, fmt.Errorf("executionTimeout: %v", err)
+	}
+	if out.WorkerHealthCheckPeriod, err = durationFromV1(s.WorkerHealthCheckPeriod); err != nil {
+		return nil, fmt.Errorf("workerHealthCheckPeriod: %v", err)
+	}
+	if s.ExecutionBurst != "" {
+		burst, err := strconv.Atoi(s.ExecutionBurst)
+		if err != nil {
+			return nil, fmt.Errorf("executionBurst: %v", err)
+		}
+		out.ExecutionBurst = &burst
+	}
+	return out, nil
+}
+
+func bindingSettingsV1ToV2(queue string, allowFailure bool, executionTimeout string) (*BindingSettingsV2, error) {
+	timeout, err := durationFromV1(executionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("executionTimeout: %v", err)
+	}
+	if queue == "" && !allowFailure && timeout == nil {
+		return nil, nil
+	}
+	settings := &BindingSettingsV2{
+		Queue:            queue,
+		ExecutionTimeout: timeout,
+	}
+	if allowFailure {
+		settings.AllowFailure = &allowFailure
+	}
+	return settings, nil
+}
+
+func durationFromV1(value string) (*Duration, error) {
+	if value == "" {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, err
+	}
+	res := Duration(d)
+	return &res, nil
+}
+
+func boolFromV1(value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
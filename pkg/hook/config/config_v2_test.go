@@ -0,0 +1,289 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+
+	. "github.com/flant/shell-operator/pkg/hook/types"
+	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
+)
+
+func Test_HookConfig_V2_LoadAndValidate(t *testing.T) {
+	g := NewWithT(t)
+	var hookConfig *HookConfig
+	var err error
+
+	tests := []struct {
+		name       string
+		jsonConfig string
+		testFn     func()
+	}{
+		{
+			"v2 typed values",
+			`
+configVersion: v2
+onStartup: 10
+kubernetes:
+- name: pods
+  kind: Pod
+  executeHookOnEvent: ["Added"]
+  executeHookOnSynchronization: false
+  keepFullObjectsInMemory: false
+  resynchronizationPeriod: 10m
+settings:
+  executionMinInterval: 30s
+  executionBurst: 2
+  executionTimeout: 1m
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(hookConfig.Version).To(Equal("v2"))
+				g.Expect(hookConfig.V1).To(BeNil())
+				g.Expect(hookConfig.V2).NotTo(BeNil())
+
+				g.Expect(hookConfig.OnStartup).NotTo(BeNil())
+				g.Expect(hookConfig.OnStartup.Order).To(Equal(10.0))
+
+				g.Expect(hookConfig.OnKubernetesEvents).To(HaveLen(1))
+				pods := hookConfig.OnKubernetesEvents[0]
+				g.Expect(pods.BindingName).To(Equal("pods"))
+				g.Expect(pods.Queue).To(Equal("main"))
+				g.Expect(pods.ExecuteHookOnSynchronization).To(BeFalse())
+				g.Expect(pods.WaitForSynchronization).To(BeTrue())
+				g.Expect(pods.KeepFullObjectsInMemory).To(BeFalse())
+				g.Expect(hookConfig.V2.OnKubernetesEvent[0].ResynchronizationPeriod.String()).To(Equal("10m0s"))
+
+				g.Expect(hookConfig.Settings.ExecutionMinInterval).To(Equal(30 * time.Second))
+				g.Expect(hookConfig.Settings.ExecutionBurst).To(Equal(2))
+				g.Expect(hookConfig.Settings.ExecutionTimeout).To(Equal(time.Minute))
+			},
+		},
+		{
+			"v2 binding settings override hook settings",
+			`
+configVersion: v2
+settings:
+  queue: hook-queue
+  allowFailure: true
+schedule:
+- name: default
+  crontab: "* * * * *"
+- name: override
+  crontab: "* * * * *"
+  settings:
+    queue: binding-queue
+    allowFailure: false
+    executionTimeout: 30s
+    executionMinInterval: 1m
+    executionBurst: 3
+kubernetes:
+- name: pods
+  kind: Pod
+  waitForSynchronization: false
+`,
+			func() {
+				g.Expect(err).ShouldNot(HaveOccurred())
+
+				g.Expect(hookConfig.Schedules).To(HaveLen(2))
+				def := hookConfig.Schedules[0]
+				g.Expect(def.Queue).To(Equal("hook-queue"))
+				g.Expect(def.AllowFailure).To(BeTrue())
+				g.Expect(def.ExecutionTimeout).To(BeZero())
+				g.Expect(def.ExecutionMinInterval).To(BeZero())
+
+				override := hookConfig.Schedules[1]
+				g.Expect(override.Queue).To(Equal("binding-queue"))
+				g.Expect(override.AllowFailure).To(BeFalse())
+				g.Expect(override.ExecutionTimeout).To(Equal(30 * time.Second))
+				g.Expect(override.ExecutionMinInterval).To(Equal(time.Minute))
+				g.Expect(override.ExecutionBurst).To(Equal(3))
+
+				// waitForSynchronization can be disabled, the queue is not "main".
+				pods := hookConfig.OnKubernetesEvents[0]
+				g.Expect(pods.Queue).To(Equal("hook-queue"))
+				g.Expect(pods.WaitForSynchronization).To(BeFalse())
+			},
+		},
+		{
+			"v2 string boolean",
+			`
+configVersion: v2
+kubernetes:
+- kind: Pod
+  executeHookOnSynchronization: "false"
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+				g.Expect(err.Error()).Should(ContainSubstring("executeHookOnSynchronization"))
+			},
+		},
+		{
+			"v2 bad duration",
+			`
+configVersion: v2
+schedule:
+- crontab: "* * * * *"
+  settings:
+    executionTimeout: 10 minutes
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+				g.Expect(err.Error()).Should(ContainSubstring("executionTimeout"))
+			},
+		},
+		{
+			"v2 watchEvent is not supported",
+			`
+configVersion: v2
+kubernetes:
+- kind: Pod
+  watchEvent: ["Added"]
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+			},
+		},
+		{
+			"v2 queue at binding level",
+			`
+configVersion: v2
+schedule:
+- crontab: "* * * * *"
+  queue: q
+`,
+			func() {
+				g.Expect(err).Should(HaveOccurred())
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hookConfig = &HookConfig{}
+			err = hookConfig.LoadAndValidate([]byte(test.jsonConfig))
+			test.testFn()
+		})
+	}
+}
+
+func Test_ConvertV1ToV2(t *testing.T) {
+	g := NewWithT(t)
+
+	configsV1 := []string{
+		`{"configVersion":"v1", "onStartup": 10}`,
+		`
+configVersion: v1
+schedule:
+- name: every-minute
+  crontab: "* * * * *"
+  allowFailure: true
+  queue: schedules
+  executionTimeout: 30s
+kubernetes:
+- name: pods
+  kind: Pod
+  watchEvent: ["Added", "Deleted"]
+  executeHookOnSynchronization: false
+  waitForSynchronization: false
+  keepFullObjectsInMemory: false
+  queue: pods
+  group: main
+  jqFilter: .metadata.labels
+  labelSelector:
+    matchLabels:
+      app: test
+  namespace:
+    nameSelector:
+      matchNames: ["default"]
+- name: secrets
+  apiVersion: v1
+  kind: Secret
+  executeHookOnEvent: ["Modified"]
+  resynchronizationPeriod: 1h
+  includeSnapshotsFrom: ["pods"]
+kubernetesValidating:
+- name: private-repo-policy.example.com
+  rules:
+  - apiGroups:   ["stable.example.com"]
+    apiVersions: ["v1"]
+    operations:  ["CREATE"]
+    resources:   ["crontabs"]
+    scope:       "Namespaced"
+settings:
+  executionMinInterval: 30s
+  executionBurst: 2
+  executionTimeout: 1m
+  bindingContextDelivery: stdin
+  env:
+    MODE: debug
+  limits:
+    memory: 128Mi
+`,
+	}
+
+	for _, configV1 := range configsV1 {
+		hookConfigV1 := &HookConfig{}
+		err := hookConfigV1.LoadAndValidate([]byte(configV1))
+		g.Expect(err).ShouldNot(HaveOccurred())
+
+		cv2, err := ConvertV1ToV2(hookConfigV1.V1)
+		g.Expect(err).ShouldNot(HaveOccurred())
+		configV2, err := yaml.Marshal(cv2)
+		g.Expect(err).ShouldNot(HaveOccurred())
+
+		hookConfigV2 := &HookConfig{}
+		err = hookConfigV2.LoadAndValidate(configV2)
+		g.Expect(err).ShouldNot(HaveOccurred(), "converted config:\n%s", configV2)
+		g.Expect(hookConfigV2.Version).To(Equal("v2"))
+
+		// Ids are random, reset them before comparison.
+		for _, c := range []*HookConfig{hookConfigV1, hookConfigV2} {
+			for i := range c.Schedules {
+				c.Schedules[i].ScheduleEntry.Id = ""
+			}
+			for i := range c.OnKubernetesEvents {
+				c.OnKubernetesEvents[i].Monitor.Metadata.MonitorId = ""
+			}
+		}
+
+		g.Expect(hookConfigV2.OnStartup).To(Equal(hookConfigV1.OnStartup))
+		g.Expect(hookConfigV2.Schedules).To(Equal(hookConfigV1.Schedules))
+		g.Expect(hookConfigV2.OnKubernetesEvents).To(Equal(hookConfigV1.OnKubernetesEvents))
+		g.Expect(hookConfigV2.KubernetesValidating).To(Equal(hookConfigV1.KubernetesValidating))
+		g.Expect(hookConfigV2.KubernetesConversion).To(Equal(hookConfigV1.KubernetesConversion))
+		g.Expect(hookConfigV2.Settings).To(Equal(hookConfigV1.Settings))
+		g.Expect(hookConfigV2.Bindings()).To(Equal(hookConfigV1.Bindings()))
+	}
+
+	// Mode is not a part of v2 and can not be converted.
+	_, err := ConvertV1ToV2(&HookConfigV1{
+		ConfigVersion: "v1",
+		OnKubernetesEvent: []OnKubernetesEventConfigV1{
+			{ApiVersion: "v1", Kind: "Pod", Mode: ModeV0},
+		},
+	})
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("kubernetes[0]: mode 'v0' is not supported in v2"))
+}
+
+func Test_HookConfig_V2_ChangedBindings(t *testing.T) {
+	g := NewWithT(t)
+
+	load := func(data string) *HookConfig {
+		c := &HookConfig{}
+		err := c.LoadAndValidate([]byte(data))
+		g.Expect(err).ShouldNot(HaveOccurred())
+		return c
+	}
+
+	oldConfig := load(`{"configVersion":"v2", "schedule":[{"crontab":"* * * * *"}], "kubernetesValidating":[{"name":"a.example.com","rules":[{"apiGroups":[""],"apiVersions":["v1"],"operations":["CREATE"],"resources":["pods"]}]}]}`)
+	newConfig := load(`{"configVersion":"v2", "schedule":[{"crontab":"* * * * *"}], "kubernetesValidating":[{"name":"a.example.com","rules":[{"apiGroups":[""],"apiVersions":["v1"],"operations":["CREATE"],"resources":["pods"]}]}], "settings":{"executionTimeout":"10s"}}`)
+	g.Expect(oldConfig.ChangedBindings(newConfig)).To(BeEmpty())
+
+	// A default queue is a part of schedule and kubernetes bindings.
+	newConfig = load(`{"configVersion":"v2", "schedule":[{"crontab":"* * * * *"}], "kubernetesValidating":[{"name":"a.example.com","rules":[{"apiGroups":[""],"apiVersions":["v1"],"operations":["CREATE"],"resources":["pods"]}]}], "settings":{"queue":"q"}}`)
+	g.Expect(oldConfig.ChangedBindings(newConfig)).To(Equal([]BindingType{Schedule, OnKubernetesEvent}))
+}
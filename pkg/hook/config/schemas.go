@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	"github.com/go-openapi/spec"
 	"github.com/go-openapi/swag"
@@ -73,6 +74,98 @@ properties:
         type: string
       executionBurst:
         type: integer
+      executionTimeout:
+        type: string
+      bindingContextDelivery:
+        type: string
+        enum:
+        - file
+        - stdin
+      executionMode:
+        type: string
+        enum:
+        - exec
+        - worker
+      workerStart:
+        type: string
+        enum:
+        - lazy
+        - onConfig
+      workerHealthCheckPeriod:
+        type: string
+      limits:
+        type: object
+        additionalProperties: false
+        properties:
+          memory:
+            type: string
+          addressSpace:
+            type: string
+          cpuTime:
+            type: string
+          nofile:
+            type: integer
+            minimum: 1
+          nproc:
+            type: integer
+            minimum: 1
+      env:
+        type: object
+        additionalProperties:
+          type:
+          - string
+          - number
+          - boolean
+          - object
+          properties:
+            value:
+              type: string
+            valueFrom:
+              type: object
+              additionalProperties: false
+              properties:
+                file:
+                  type: string
+                env:
+                  type: string
+      workingDir:
+        type: string
+      args:
+        type: array
+        items:
+          type: string
+      envAllowlist:
+        type: array
+        items:
+          type: string
+      envDenylist:
+        type: array
+        items:
+          type: string
+      runAs:
+        type: object
+        additionalProperties: false
+        required:
+        - uid
+        properties:
+          uid:
+            type: integer
+            minimum: 0
+          gid:
+            type: integer
+            minimum: 0
+          groups:
+            type: array
+            items:
+              type: integer
+              minimum: 0
+      dropPrivileges:
+        type: boolean
+      dependsOn:
+        type: array
+        items:
+          type: string
+          minLength: 1
   onStartup:
     title: onStartup binding
     description: |
@@ -109,6 +202,8 @@ properties:
           type: string
         group:
           type: string
+        executionTimeout:
+          type: string
   kubernetes:
     title: kubernetes event bindings
     type: array
@@ -158,6 +253,15 @@ properties:
           type: boolean
         resynchronizationPeriod:
           type: string
+        watchMode:
+          type: string
+          enum:
+          - full
+          - metadata
+        waitForCRD:
+          type: boolean
+        executionTimeout:
+          type: string
         nameSelector:
           "$ref": "#/definitions/nameSelector"
         labelSelector:
@@ -322,6 +426,437 @@ properties:
                 type: string
               toVersion:
                 type: string
+`,
+	"v2": `
+definitions:
+  duration:
+    type: string
+    pattern: "^[0-9]+(\\.[0-9]+)?(ns|us|\u00b5s|ms|s|m|h)([0-9]+(\\.[0-9]+)?(ns|us|\u00b5s|ms|s|m|h))*$"
+    example: 1m30s
+  bindingSettings:
+    type: object
+    additionalProperties: false
+    properties:
+      queue:
+        type: string
+      allowFailure:
+        type: boolean
+      executionTimeout:
+        "$ref": "#/definitions/duration"
+      executionMinInterval:
+        "$ref": "#/definitions/duration"
+      executionBurst:
+        type: integer
+        minimum: 1
+  nameSelector:
+    type: object
+    additionalProperties: false
+    required:
+    - matchNames
+    properties:
+      matchNames:
+        type: array
+        additionalItems: false
+        items:
+          type: string
+  labelSelector:
+    type: object
+    additionalProperties: false
+    minProperties: 1
+    maxProperties: 2
+    properties:
+      matchLabels:
+        type: object
+        additionalProperties:
+          type: string
+      matchExpressions:
+        type: array
+        items:
+          type: object
+          additionalProperties: false
+          required:
+          - key
+          - operator
+          properties:
+            key:
+              type: string
+            operator:
+              type: string
+              enum:
+              - In
+              - NotIn
+              - Exists
+              - DoesNotExist
+            values:
+              type: array
+              items:
+                type: string
+
+type: object
+additionalProperties: false
+required:
+- configVersion
+minProperties: 2
+properties:
+  configVersion:
+    type: string
+    enum:
+    - v2
+  settings:
+    type: object
+    additionalProperties: false
+    properties:
+      queue:
+        type: string
+      allowFailure:
+        type: boolean
+      executionTimeout:
+        "$ref": "#/definitions/duration"
+      executionMinInterval:
+        "$ref": "#/definitions/duration"
+      executionBurst:
+        type: integer
+        minimum: 1
+      bindingContextDelivery:
+        type: string
+        enum:
+        - file
+        - stdin
+      executionMode:
+        type: string
+        enum:
+        - exec
+        - worker
+      workerStart:
+        type: string
+        enum:
+        - lazy
+        - onConfig
+      workerHealthCheckPeriod:
+        "$ref": "#/definitions/duration"
+      limits:
+        type: object
+        additionalProperties: false
+        properties:
+          memory:
+            type: string
+          addressSpace:
+            type: string
+          cpuTime:
+            "$ref": "#/definitions/duration"
+          nofile:
+            type: integer
+            minimum: 1
+          nproc:
+            type: integer
+            minimum: 1
+      env:
+        type: object
+        additionalProperties:
+          type:
+          - string
+          - number
+          - boolean
+          - object
+          properties:
+            value:
+              type: string
+            valueFrom:
+              type: object
+              additionalProperties: false
+              properties:
+                file:
+                  type: string
+                env:
+                  type: string
+      workingDir:
+        type: string
+      args:
+        type: array
+        items:
+          type: string
+      envAllowlist:
+        type: array
+        items:
+          type: string
+      envDenylist:
+        type: array
+        items:
+          type: string
+      runAs:
+        type: object
+        additionalProperties: false
+        required:
+        - uid
+        properties:
+          uid:
+            type: integer
+            minimum: 0
+          gid:
+            type: integer
+            minimum: 0
+          groups:
+            type: array
+            items:
+              type: integer
+              minimum: 0
+      dropPrivileges:
+        type: boolean
+      dependsOn:
+        type: array
+        items:
+          type: string
+          minLength: 1
+  onStartup:
+    title: onStartup binding
+    description: |
+      the value is the order to sort onStartup hooks
+    type: integer
+    example: 10
+  schedule:
+    title: schedule bindings
+    description: |
+      configuration of hooks that should run on schedule
+    type: array
+    additionalItems: false
+    minItems: 1
+    items:
+      type: object
+      additionalProperties: false
+      required:
+      - crontab
+      properties:
+        name:
+          type: string
+        crontab:
+          type: string
+        includeSnapshotsFrom:
+          type: array
+          additionalItems: false
+          minItems: 1
+          items:
+            type: string
+        group:
+          type: string
+        settings:
+          "$ref": "#/definitions/bindingSettings"
+  kubernetes:
+    title: kubernetes event bindings
+    type: array
+    additionalItems: false
+    minItems: 1
+    items:
+      type: object
+      additionalProperties: false
+      required:
+      - kind
+      properties:
+        name:
+          type: string
+        apiVersion:
+          type: string
+        kind:
+          type: string
+        executeHookOnEvent:
+          type: array
+          additionalItems: false
+          minItems: 0
+          items:
+            type: string
+            enum:
+            - Added
+            - Modified
+            - Deleted
+        includeSnapshotsFrom:
+          type: array
+          additionalItems: false
+          minItems: 1
+          items:
+            type: string
+        jqFilter:
+          type: string
+          example: ".metadata.labels"
+        keepFullObjectsInMemory:
+          type: boolean
+        executeHookOnSynchronization:
+          type: boolean
+        waitForSynchronization:
+          type: boolean
+        resynchronizationPeriod:
+          "$ref": "#/definitions/duration"
+        watchMode:
+          type: string
+          enum:
+          - full
+          - metadata
+        waitForCRD:
+          type: boolean
+        nameSelector:
+          "$ref": "#/definitions/nameSelector"
+        labelSelector:
+          "$ref": "#/definitions/labelSelector"
+        fieldSelector:
+          type: object
+          additionalProperties: false
+          required:
+          - matchExpressions
+          properties:
+            matchExpressions:
+              type: array
+              items:
+                type: object
+                additionalProperties: false
+                minProperties: 3
+                maxProperties: 3
+                properties:
+                  field:
+                    type: string
+                  operator:
+                    type: string
+                    enum: ["=", "==", "Equals", "!=", "NotEquals"]
+                  value:
+                    type: string
+        group:
+          type: string
+        namespace:
+          type: object
+          additionalProperties: false
+          minProperties: 1
+          maxProperties: 2
+          properties:
+            nameSelector:
+              "$ref": "#/definitions/nameSelector"
+            labelSelector:
+              "$ref": "#/definitions/labelSelector"
+        settings:
+          "$ref": "#/definitions/bindingSettings"
+  kubernetesValidating:
+    title: ValidatingWebhookConfiguration handlers
+    type: array
+    additionalItems: false
+    minItems: 1
+    items:
+      type: object
+      additionalProperties: false
+      required:
+      - name
+      properties:
+        name:
+          type: string
+        group:
+          type: string
+        includeSnapshotsFrom:
+          type: array
+          additionalItems: false
+          minItems: 1
+          items:
+            type: string
+        failurePolicy:
+          type: string
+          enum:
+          - Ignore
+          - Fail
+        sideEffects:
+          type: string
+          enum:
+          - None
+          - NoneOnDryRun
+        timeoutSeconds:
+          type: integer
+          example: 10
+        labelSelector:
+          "$ref": "#/definitions/labelSelector"
+        namespace:
+          type: object
+          additionalProperties: false
+          required:
+          - labelSelector
+          properties:
+            labelSelector:
+              "$ref": "#/definitions/labelSelector"
+        rules:
+          type: array
+          additionalItems: false
+          minItems: 1
+          items:
+            type: object
+            additionalProperties: false
+            required:
+              - apiVersions
+              - apiGroups
+              - resources
+              - operations
+            properties:
+              apiVersions:
+                type: array
+                minItems: 1
+                items:
+                  type: string
+              apiGroups:
+                type: array
+                minItems: 1
+                items:
+                  type: string
+              resources:
+                type: array
+                minItems: 1
+                items:
+                  type: string
+              operations:
+                type: array
+                minItems: 1
+                items:
+                  type: string
+                  enum:
+                  - "CREATE"
+                  - "UPDATE"
+                  - "*"
+              scope:
+                type: string
+                enum:
+                - "Cluster"
+                - "Namespaced"
+                - "*"
+  kubernetesCustomResourceConversion:
+    title: Conversion handlers for CustomResourceDefinition versions
+    type: array
+    additionalItems: false
+    minItems: 1
+    items:
+      type: object
+      additionalProperties: false
+      required:
+      - name
+      - crdName
+      - conversions
+      properties:
+        name:
+          type: string
+        group:
+          type: string
+        includeSnapshotsFrom:
+          type: array
+          additionalItems: false
+          minItems: 1
+          items:
+            type: string
+        crdName:
+          type: string
+        conversions:
+          type: array
+          additionalItems: false
+          minItems: 1
+          items:
+            type: object
+            additionalProperties: false
+            required:
+            - fromVersion
+            - toVersion
+            properties:
+              fromVersion:
+                type: string
+              toVersion:
+                type: string
 `,
 	"v0": `
 type: object
@@ -346,8 +881,14 @@ properties:
 
 var SchemasCache = map[string]*spec.Schema{}
 
+// schemasCacheMu protects SchemasCache as hook configs are loaded concurrently.
+var schemasCacheMu sync.Mutex
+
 // GetSchema returns loaded schema.
 func GetSchema(name string) *spec.Schema {
+	schemasCacheMu.Lock()
+	defer schemasCacheMu.Unlock()
+
 	if s, ok := SchemasCache[name]; ok {
 		return s
 	}
@@ -0,0 +1,181 @@
+package context_generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/flant/shell-operator/pkg/hook"
+	utils_file "github.com/flant/shell-operator/pkg/utils/file"
+)
+
+// GeneratedStep is a result of one step of the generator: an initial state,
+// a state change or a schedule run.
+type GeneratedStep struct {
+	Step            string          `json:"step"`
+	BindingContexts json.RawMessage `json:"bindingContexts"`
+}
+
+// GenerateOptions describes steps for the generator.
+type GenerateOptions struct {
+	// States are names and contents of cluster states. The first state is
+	// an initial state, other states are applied one by one.
+	States []NamedState
+	// Schedules are names of schedule bindings to run after all states are applied.
+	Schedules []string
+	// CRDs are custom resources in format "group/version/Kind".
+	CRDs []string
+}
+
+type NamedState struct {
+	Name  string
+	State string
+}
+
+// Generate returns binding contexts for each step. States are applied first,
+// then schedule bindings are run.
+func Generate(config string, opts GenerateOptions) ([]GeneratedStep, error) {
+	c := NewBindingContextController(config)
+	defer c.Stop()
+
+	for _, crd := range opts.CRDs {
+		parts := strings.Split(crd, "/")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("crd '%s' should be in format 'group/version/Kind'", crd)
+		}
+		c.RegisterCRD(parts[0], parts[1], parts[2], true)
+	}
+
+	steps := make([]GeneratedStep, 0)
+	addStep := func(name string, contexts GeneratedBindingContexts) {
+		steps = append(steps, GeneratedStep{
+			Step:            name,
+			BindingContexts: json.RawMessage(contexts.Rendered),
+		})
+	}
+
+	initialState := ""
+	if len(opts.States) > 0 {
+		initialState = opts.States[0].State
+	}
+	contexts, err := c.Run(initialState)
+	if err != nil {
+		return nil, err
+	}
+	initialName := "synchronization"
+	if len(opts.States) > 0 {
+		initialName = fmt.Sprintf("state %s", opts.States[0].Name)
+	}
+	addStep(initialName, contexts)
+
+	for i := 1; i < len(opts.States); i++ {
+		contexts, err = c.ChangeState(opts.States[i].State)
+		if err != nil {
+			return nil, fmt.Errorf("state %s: %v", opts.States[i].Name, err)
+		}
+		addStep(fmt.Sprintf("state %s", opts.States[i].Name), contexts)
+	}
+
+	for _, name := range opts.Schedules {
+		crontab := ""
+		for _, cfg := range c.Hook.GetConfig().Schedules {
+			if cfg.BindingName == name {
+				crontab = cfg.ScheduleEntry.Crontab
+				break
+			}
+		}
+		if crontab == "" {
+			return nil, fmt.Errorf("hook has no schedule binding '%s'", name)
+		}
+		contexts, err = c.RunSchedule(crontab)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %v", name, err)
+		}
+		addStep(fmt.Sprintf("schedule %s", name), contexts)
+	}
+
+	return steps, nil
+}
+
+// readHookConfig returns a config from the file. If the file is an executable hook,
+// a static config is used or the hook is executed with --config flag.
+func readHookConfig(path string) ([]byte, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !utils_file.IsFileExecutable(stat) {
+		return os.ReadFile(path)
+	}
+
+	data, _, err := hook.LoadStaticConfig(path)
+	if err != nil || data != nil {
+		return data, err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return hook.RunConfigCommand(filepath.Dir(absPath), filepath.Base(absPath), absPath)
+}
+
+// DefineGenerateContextCommand adds "generate-context" subcommand to print
+// binding contexts generated with the fake cluster.
+func DefineGenerateContextCommand(hookCmd *kingpin.CmdClause) {
+	var configPath string
+	var statePaths []string
+	var schedules []string
+	var crds []string
+	var outputFormat string
+
+	generateCmd := hookCmd.Command("generate-context", "Generate binding contexts for the hook config and a sequence of cluster states using a fake cluster.").
+		Action(func(c *kingpin.ParseContext) error {
+			config, err := readHookConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("read hook config: %v", err)
+			}
+
+			opts := GenerateOptions{Schedules: schedules, CRDs: crds}
+			for _, path := range statePaths {
+				state, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("read state: %v", err)
+				}
+				opts.States = append(opts.States, NamedState{Name: path, State: string(state)})
+			}
+
+			steps, err := Generate(string(config), opts)
+			if err != nil {
+				return err
+			}
+
+			if outputFormat == "text" {
+				for _, step := range steps {
+					fmt.Printf("# %s\n%s\n", step.Step, string(step.BindingContexts))
+				}
+				return nil
+			}
+			out, err := json.MarshalIndent(steps, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		})
+	generateCmd.Flag("config", "A path to the hook config in YAML or JSON or to the hook executable.").
+		Required().
+		StringVar(&configPath)
+	generateCmd.Flag("state", "A path to the YAML file with cluster objects. The first state is an initial state. Can be repeated.").
+		StringsVar(&statePaths)
+	generateCmd.Flag("schedule", "A name of the schedule binding to run after all states. Can be repeated.").
+		StringsVar(&schedules)
+	generateCmd.Flag("crd", "A custom resource to register in the fake cluster in format 'group/version/Kind'. Can be repeated.").
+		StringsVar(&crds)
+	generateCmd.Flag("output", "Output format: json|text.").Short('o').
+		Default("json").
+		EnumVar(&outputFormat, "json", "text")
+}
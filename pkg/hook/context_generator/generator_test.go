@@ -1,4 +1,4 @@
-package context
+package context_generator
 
 import (
 	"encoding/json"
@@ -463,3 +463,60 @@ metadata:
 	parsedBindingContexts = parseContexts(contexts.Rendered)
 	g.Expect(parsedBindingContexts[0].Snapshots["selected_pods"]).To(HaveLen(2))
 }
+
+func Test_Generate(t *testing.T) {
+	g := NewWithT(t)
+
+	steps, err := Generate(`
+configVersion: v1
+kubernetes:
+- name: selected_pods
+  apiVersion: v1
+  kind: Pod
+schedule:
+- name: every_minute
+  crontab: "* * * * *"
+  includeSnapshotsFrom:
+  - selected_pods
+`, GenerateOptions{
+		States: []NamedState{
+			{Name: "initial", State: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod1
+`},
+			{Name: "added", State: `
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod1
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod2
+`},
+		},
+		Schedules: []string{"every_minute"},
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(steps).To(HaveLen(3))
+
+	g.Expect(steps[0].Step).To(Equal("state initial"))
+	contexts := parseContexts(string(steps[0].BindingContexts))
+	g.Expect(string(contexts[0].Type)).To(Equal("Synchronization"))
+
+	g.Expect(steps[1].Step).To(Equal("state added"))
+	contexts = parseContexts(string(steps[1].BindingContexts))
+	g.Expect(string(contexts[0].WatchEvent)).To(Equal("Added"))
+
+	g.Expect(steps[2].Step).To(Equal("schedule every_minute"))
+	contexts = parseContexts(string(steps[2].BindingContexts))
+	g.Expect(contexts[0].Binding).To(Equal("every_minute"))
+	g.Expect(contexts[0].Snapshots["selected_pods"]).To(HaveLen(2))
+
+	_, err = Generate(`{"configVersion":"v1", "onStartup": 1}`, GenerateOptions{Schedules: []string{"unknown"}})
+	g.Expect(err).Should(HaveOccurred())
+}
@@ -76,8 +76,11 @@ func (c *conversionBindingsController) EnableConversionBindings() {
 	}
 }
 
+// DisableConversionBindings stops handling conversion requests. Conversion
+// settings in CRDs are not changed as Kubernetes cannot serve stored versions
+// without the webhook.
 func (c *conversionBindingsController) DisableConversionBindings() {
-	// TODO dynamic enable/disable conversion webhooks.
+	c.Links = make(map[string]map[conversion.Rule]*ConversionBindingToWebhookLink)
 }
 
 func (c *conversionBindingsController) CanHandleEvent(event conversion.Event, rule conversion.Rule) bool {
@@ -62,8 +62,12 @@ type HookController interface {
 	DisableScheduleBindings()
 
 	EnableValidatingBindings()
+	DisableValidatingBindings()
 
 	EnableConversionBindings()
+	DisableConversionBindings()
+
+	InheritBindings(from HookController, bindingTypes []BindingType)
 
 	KubernetesSnapshots() map[string][]ObjectAndFilterResult
 	UpdateSnapshots([]BindingContext) []BindingContext
@@ -266,12 +270,51 @@ func (hc *hookController) EnableValidatingBindings() {
 	}
 }
 
+func (hc *hookController) DisableValidatingBindings() {
+	if hc.ValidatingController != nil {
+		hc.ValidatingController.DisableValidatingBindings()
+	}
+}
+
 func (hc *hookController) EnableConversionBindings() {
 	if hc.ConversionController != nil {
 		hc.ConversionController.EnableConversionBindings()
 	}
 }
 
+func (hc *hookController) DisableConversionBindings() {
+	if hc.ConversionController != nil {
+		hc.ConversionController.DisableConversionBindings()
+	}
+}
+
+// InheritBindings takes binding controllers for bindingTypes from the controller
+// of the previous hook instance. It is used on hooks reload to keep running
+// monitors, schedules and webhooks for unchanged bindings.
+func (hc *hookController) InheritBindings(from HookController, bindingTypes []BindingType) {
+	prev, ok := from.(*hookController)
+	if !ok {
+		return
+	}
+
+	for _, bindingType := range bindingTypes {
+		switch bindingType {
+		case OnKubernetesEvent:
+			hc.KubernetesController = prev.KubernetesController
+			hc.kubernetesBindings = prev.kubernetesBindings
+		case Schedule:
+			hc.ScheduleController = prev.ScheduleController
+			hc.scheduleBindings = prev.scheduleBindings
+		case KubernetesValidating:
+			hc.ValidatingController = prev.ValidatingController
+			hc.validatingBindings = prev.validatingBindings
+		case KubernetesConversion:
+			hc.ConversionController = prev.ConversionController
+			hc.conversionBindings = prev.conversionBindings
+		}
+	}
+}
+
 // KubernetesSnapshots returns a 'full snapshot': all snapshots for all registered kubernetes bindings.
 // Note: no caching as in UpdateSnapshots because KubernetesSnapshots used for non-combined binding contexts.
 func (hc *hookController) KubernetesSnapshots() map[string][]ObjectAndFilterResult {
@@ -86,6 +86,12 @@ func (c *kubernetesBindingsController) EnableKubernetesBindings() ([]BindingExec
 		// Start monitor's informers to fill the cache.
 		c.kubeEventsManager.StartMonitor(config.Monitor.Metadata.MonitorId)
 
+		// Pending monitor emits Synchronization event when its CRD is created.
+		if c.kubeEventsManager.IsMonitorPending(config.Monitor.Metadata.MonitorId) {
+			log.Infof("Binding '%s' waits for CRD for apiVersion '%s' kind '%s'", config.BindingName, config.Monitor.ApiVersion, config.Monitor.Kind)
+			continue
+		}
+
 		synchronizationInfo := c.HandleEvent(KubeEvent{
 			MonitorId: config.Monitor.Metadata.MonitorId,
 			Type:      TypeSynchronization,
@@ -86,8 +86,13 @@ func (c *validatingBindingsController) EnableValidatingBindings() {
 	}
 }
 
+// DisableValidatingBindings removes webhooks from the WebhookManager.
+// The WebhookManager should update configurations to apply changes.
 func (c *validatingBindingsController) DisableValidatingBindings() {
-	// TODO dynamic enable/disable validating webhooks.
+	for _, config := range c.ValidatingBindings {
+		c.webhookManager.RemoveWebhook(config.Webhook)
+	}
+	c.ValidatingLinks = make(map[string]*ValidatingBindingToWebhookLink)
 }
 
 func (c *validatingBindingsController) CanHandleEvent(event ValidatingEvent) bool {
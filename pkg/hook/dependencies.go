@@ -0,0 +1,170 @@
+package hook
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DependsOn returns names of hooks from settings.dependsOn.
+func (h *Hook) DependsOn() []string {
+	if h.Config.Settings == nil {
+		return nil
+	}
+	return h.Config.Settings.DependsOn
+}
+
+// quarantineDependents quarantines hooks that depend on quarantined hooks, directly
+// or through other hooks. A dependency is satisfied if a previous instance of the
+// quarantined hook continues to run, running contains names of such hooks.
+// Workers of quarantined hooks are stopped.
+func quarantineDependents(hooks []*Hook, invalidHooks []InvalidHook, running map[string]bool) ([]*Hook, []InvalidHook) {
+	missing := make(map[string]bool, len(invalidHooks))
+	for _, invalid := range invalidHooks {
+		if !running[invalid.Name] {
+			missing[invalid.Name] = true
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		valid := make([]*Hook, 0, len(hooks))
+		for _, hook := range hooks {
+			dep := ""
+			for _, name := range hook.DependsOn() {
+				if missing[name] {
+					dep = name
+					break
+				}
+			}
+			if dep == "" {
+				valid = append(valid, hook)
+				continue
+			}
+			err := fmt.Errorf("hook '%s' depends on quarantined hook '%s'", hook.Name, dep)
+			log.WithField("hook", hook.Name).
+				WithField("phase", "config").
+				Errorf("Hook is quarantined: %v", err)
+			hook.StopWorker()
+			invalidHooks = append(invalidHooks, InvalidHook{Name: hook.Name, Error: err.Error()})
+			if !running[hook.Name] {
+				missing[hook.Name] = true
+			}
+			changed = true
+		}
+		hooks = valid
+	}
+	return hooks, invalidHooks
+}
+
+// checkDependencies returns an error if some hook depends on an unknown hook or
+// if dependencies have a cycle. Dependents of quarantined hooks should be
+// quarantined with quarantineDependents.
+func checkDependencies(hooksByName map[string]*Hook, hookNames []string) error {
+	for _, name := range hookNames {
+		for _, dep := range hooksByName[name].DependsOn() {
+			if _, has := hooksByName[dep]; !has {
+				return fmt.Errorf("hook '%s' depends on unknown hook '%s'", name, dep)
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("hooks have a dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range hooksByName[name].DependsOn() {
+			if _, has := hooksByName[dep]; !has {
+				continue
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range hookNames {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderByDependencies returns hooks in topological order: each hook follows
+// hooks it depends on, directly or through other hooks not present in the list.
+// Otherwise, the input order is kept. Dependencies should be checked with
+// checkDependencies.
+func orderByDependencies(hooks []*Hook, hooksByName map[string]*Hook) []*Hook {
+	inList := make(map[string]bool, len(hooks))
+	for _, hook := range hooks {
+		inList[hook.Name] = true
+	}
+
+	// Dependencies of each hook that are present in the list.
+	deps := make(map[string][]string, len(hooks))
+	for _, hook := range hooks {
+		seen := map[string]bool{}
+		var collect func(h *Hook)
+		collect = func(h *Hook) {
+			for _, dep := range h.DependsOn() {
+				depHook, has := hooksByName[dep]
+				if !has || seen[dep] {
+					continue
+				}
+				seen[dep] = true
+				if inList[dep] {
+					deps[hook.Name] = append(deps[hook.Name], dep)
+				}
+				collect(depHook)
+			}
+		}
+		collect(hook)
+	}
+
+	// Take the first hook with all dependencies done to keep the input order
+	// as much as possible.
+	res := make([]*Hook, 0, len(hooks))
+	done := make(map[string]bool, len(hooks))
+	pending := make([]*Hook, len(hooks))
+	copy(pending, hooks)
+	for len(pending) > 0 {
+		readyIdx := -1
+		for i, hook := range pending {
+			ready := true
+			for _, dep := range deps[hook.Name] {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				readyIdx = i
+				break
+			}
+		}
+		if readyIdx < 0 {
+			// Should not happen: cycles are rejected by checkDependencies.
+			return append(res, pending...)
+		}
+		res = append(res, pending[readyIdx])
+		done[pending[readyIdx].Name] = true
+		pending = append(pending[:readyIdx], pending[readyIdx+1:]...)
+	}
+	return res
+}
@@ -0,0 +1,210 @@
+package hook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/flant/shell-operator/pkg/utils/checksum"
+)
+
+// DirWatcherDebounce is a delay to accumulate file events before the scan,
+// e.g. a ConfigMap volume update produces several events.
+var DirWatcherDebounce = 2 * time.Second
+
+// DirWatcher notifies about changes in the hooks directory. Changes are detected
+// by comparing checksums of all files. The scan runs after file events and
+// periodically as a fallback for filesystems without notifications.
+type DirWatcher struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	dir        string
+	scanPeriod time.Duration
+
+	watcher  *fsnotify.Watcher
+	watched  map[string]bool
+	checksum string
+
+	ch chan struct{}
+}
+
+func NewDirWatcher(dir string, scanPeriod time.Duration) *DirWatcher {
+	return &DirWatcher{
+		dir:        dir,
+		scanPeriod: scanPeriod,
+		watched:    make(map[string]bool),
+		ch:         make(chan struct{}, 1),
+	}
+}
+
+func (w *DirWatcher) WithContext(ctx context.Context) {
+	w.ctx, w.cancel = context.WithCancel(ctx)
+}
+
+func (w *DirWatcher) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+// Ch returns a channel to receive notifications about changes in the directory.
+func (w *DirWatcher) Ch() chan struct{} {
+	return w.ch
+}
+
+// Start remembers the current state of the directory and starts watching for changes.
+func (w *DirWatcher) Start() {
+	logEntry := log.WithField("operator.component", "hooksDirWatcher")
+
+	var err error
+	w.checksum, err = dirChecksum(w.dir)
+	if err != nil {
+		logEntry.Errorf("Calculate checksum of '%s': %v", w.dir, err)
+	}
+
+	w.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		logEntry.Warnf("File events are not available, changes are detected with periodic scan: %v", err)
+		w.watcher = nil
+	} else {
+		w.watchDirs()
+	}
+
+	go w.run()
+}
+
+func (w *DirWatcher) run() {
+	logEntry := log.WithField("operator.component", "hooksDirWatcher")
+
+	var events chan fsnotify.Event
+	var watchErrors chan error
+	if w.watcher != nil {
+		events = w.watcher.Events
+		watchErrors = w.watcher.Errors
+		defer w.watcher.Close()
+	}
+
+	var scanC <-chan time.Time
+	if w.scanPeriod > 0 {
+		ticker := time.NewTicker(w.scanPeriod)
+		defer ticker.Stop()
+		scanC = ticker.C
+	}
+
+	var debounceC <-chan time.Time
+	for {
+		select {
+		case event := <-events:
+			logEntry.Debugf("File event: %s", event.String())
+			if debounceC == nil {
+				debounceC = time.After(DirWatcherDebounce)
+			}
+		case err := <-watchErrors:
+			logEntry.Errorf("Watch '%s': %v", w.dir, err)
+		case <-debounceC:
+			debounceC = nil
+			w.scan()
+		case <-scanC:
+			w.scan()
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// scan sends a notification if the checksum of the directory is changed.
+func (w *DirWatcher) scan() {
+	sum, err := dirChecksum(w.dir)
+	if err != nil {
+		log.WithField("operator.component", "hooksDirWatcher").
+			Errorf("Calculate checksum of '%s': %v", w.dir, err)
+		return
+	}
+	if sum == w.checksum {
+		return
+	}
+	w.checksum = sum
+
+	if w.watcher != nil {
+		w.watchDirs()
+	}
+
+	select {
+	case w.ch <- struct{}{}:
+	default:
+		// A notification is already pending.
+	}
+}
+
+// watchDirs adds new subdirectories to the watcher.
+func (w *DirWatcher) watchDirs() {
+	_ = filepath.Walk(w.dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil || !f.IsDir() {
+			return nil
+		}
+		if path != w.dir && strings.HasPrefix(f.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if w.watched[path] {
+			return nil
+		}
+		if err := w.watcher.Add(path); err != nil {
+			log.WithField("operator.component", "hooksDirWatcher").
+				Warnf("Watch '%s': %v", path, err)
+			return nil
+		}
+		w.watched[path] = true
+		return nil
+	})
+}
+
+// dirChecksum returns a checksum of names, permissions and contents of files in dir.
+// Hidden files and directories are ignored as RecursiveGetExecutablePaths does.
+// Symlinks are followed to detect updates of ConfigMap volumes.
+func dirChecksum(dir string) (string, error) {
+	entries := make([]string, 0)
+
+	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(f.Name(), ".") && path != dir {
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if f.IsDir() {
+			return nil
+		}
+
+		stat, err := os.Stat(path)
+		if err != nil {
+			// A broken symlink.
+			entries = append(entries, path)
+			return nil
+		}
+		if stat.IsDir() {
+			return nil
+		}
+
+		sum, err := checksum.CalculateChecksumOfFile(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fmt.Sprintf("%s:%o:%s", path, stat.Mode().Perm(), sum))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return checksum.CalculateChecksum(entries...), nil
+}
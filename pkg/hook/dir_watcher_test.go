@@ -0,0 +1,42 @@
+package hook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_DirWatcher(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "hook.sh"), []byte("#!/bin/bash\n"), 0755)).To(Succeed())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	DirWatcherDebounce = 50 * time.Millisecond
+	w := NewDirWatcher(dir, 100*time.Millisecond)
+	w.WithContext(ctx)
+	w.Start()
+
+	// No notifications without changes.
+	g.Consistently(w.Ch(), "300ms").ShouldNot(Receive())
+
+	// Changed permissions are detected.
+	g.Expect(os.Chmod(filepath.Join(dir, "hook.sh"), 0644)).To(Succeed())
+	g.Eventually(w.Ch(), "2s").Should(Receive())
+
+	// A new file in a new subdirectory is detected.
+	g.Expect(os.Mkdir(filepath.Join(dir, "sub"), 0755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "sub", "hook.sh"), []byte("#!/bin/bash\n"), 0755)).To(Succeed())
+	g.Eventually(w.Ch(), "2s").Should(Receive())
+
+	// Hidden files are ignored.
+	g.Expect(os.WriteFile(filepath.Join(dir, ".hidden"), []byte("data"), 0644)).To(Succeed())
+	g.Consistently(w.Ch(), "300ms").ShouldNot(Receive())
+}
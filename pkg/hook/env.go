@@ -0,0 +1,268 @@
+package hook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	. "github.com/flant/shell-operator/pkg/hook/binding_context"
+	. "github.com/flant/shell-operator/pkg/hook/types"
+
+	"github.com/flant/shell-operator/pkg/app"
+)
+
+// TaskInfo describes a task that runs the hook. It is passed to the hook in environment variables.
+type TaskInfo struct {
+	Queue        string
+	TaskId       string
+	FailureCount int
+}
+
+// Standard environment variables for the hook process.
+const (
+	EnvHookName         = "HOOK_NAME"
+	EnvHookBinding      = "HOOK_BINDING"
+	EnvHookBindingType  = "HOOK_BINDING_TYPE"
+	EnvHookQueue        = "HOOK_QUEUE"
+	EnvHookTaskId       = "HOOK_TASK_ID"
+	EnvHookFailureCount = "HOOK_FAILURE_COUNT"
+)
+
+// standardEnv returns variables that describe the current execution.
+// Names of bindings are joined with a comma if binding contexts are combined.
+func (h *Hook) standardEnv(bindingType BindingType, context []BindingContext, taskInfo TaskInfo) map[string]string {
+	bindings := make([]string, 0, len(context))
+	seen := map[string]bool{}
+	for _, bc := range context {
+		if !seen[bc.Binding] {
+			seen[bc.Binding] = true
+			bindings = append(bindings, bc.Binding)
+		}
+	}
+
+	return map[string]string{
+		EnvHookName:         h.Name,
+		EnvHookBinding:      strings.Join(bindings, ","),
+		EnvHookBindingType:  string(bindingType),
+		EnvHookQueue:        taskInfo.Queue,
+		EnvHookTaskId:       taskInfo.TaskId,
+		EnvHookFailureCount: strconv.Itoa(taskInfo.FailureCount),
+	}
+}
+
+// makeEnv returns environment for the hook process: filtered Shell-operator's
+// environment, variables from settings and standard variables.
+func (h *Hook) makeEnv(standard map[string]string) ([]string, error) {
+	envs := []string{}
+	envs = append(envs, h.filterEnv(os.Environ())...)
+
+	settingsEnv, err := h.settingsEnv()
+	if err != nil {
+		return nil, err
+	}
+	envs = append(envs, settingsEnv...)
+
+	for _, name := range []string{EnvHookName, EnvHookBinding, EnvHookBindingType, EnvHookQueue, EnvHookTaskId, EnvHookFailureCount} {
+		if value, has := standard[name]; has {
+			envs = append(envs, fmt.Sprintf("%s=%s", name, value))
+		}
+	}
+
+	return envs, nil
+}
+
+// filterEnv filters variables with --hook-env-allowlist and --hook-env-denylist
+// flags and then with lists from settings. Lists from settings can only narrow
+// the operator's lists: allowlists are intersected and denylists are combined.
+func (h *Hook) filterEnv(environ []string) []string {
+	res := FilterEnv(environ, SplitEnvList(app.HookEnvAllowlist), SplitEnvList(app.HookEnvDenylist))
+	if h.Config.Settings != nil {
+		res = FilterEnv(res, h.Config.Settings.EnvAllowlist, h.Config.Settings.EnvDenylist)
+	}
+	return res
+}
+
+// checkEnvSources returns an error if valueFrom.env refers to a variable
+// that is not passed to hooks because of --hook-env-allowlist or --hook-env-denylist flags.
+func (h *Hook) checkEnvSources() error {
+	if h.Config.Settings == nil {
+		return nil
+	}
+	allowlist := SplitEnvList(app.HookEnvAllowlist)
+	denylist := SplitEnvList(app.HookEnvDenylist)
+	for _, envVar := range h.Config.Settings.Env {
+		if envVar.ValueFromEnv == "" {
+			continue
+		}
+		if !envNameAllowed(envVar.ValueFromEnv, allowlist, denylist) {
+			return fmt.Errorf("env '%s': variable '%s' is denied by --hook-env-allowlist or --hook-env-denylist", envVar.Name, envVar.ValueFromEnv)
+		}
+	}
+	return nil
+}
+
+// SplitEnvList parses a comma-separated list of variable names and patterns.
+func SplitEnvList(list string) []string {
+	res := []string{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// FilterEnv returns variables that match the allowlist and do not match
+// the denylist. The empty allowlist allows all variables. Patterns
+// are matched with path.Match, so "KUBERNETES_*" matches all variables
+// with the "KUBERNETES_" prefix.
+func FilterEnv(environ []string, allowlist []string, denylist []string) []string {
+	if len(allowlist) == 0 && len(denylist) == 0 {
+		return environ
+	}
+	res := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		name := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			name = kv[:i]
+		}
+		if envNameAllowed(name, allowlist, denylist) {
+			res = append(res, kv)
+		}
+	}
+	return res
+}
+
+func envNameAllowed(name string, allowlist []string, denylist []string) bool {
+	if len(allowlist) > 0 && !matchEnvName(name, allowlist) {
+		return false
+	}
+	return !matchEnvName(name, denylist)
+}
+
+func matchEnvName(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// settingsEnv resolves variables from settings.env. Files are read on each call
+// to get fresh values of mounted Secrets and ConfigMaps.
+func (h *Hook) settingsEnv() ([]string, error) {
+	if h.Config.Settings == nil {
+		return nil, nil
+	}
+	envs := make([]string, 0, len(h.Config.Settings.Env))
+	for _, envVar := range h.Config.Settings.Env {
+		value := envVar.Value
+		switch {
+		case envVar.ValueFromFile != "":
+			filePath := envVar.ValueFromFile
+			if !filepath.IsAbs(filePath) {
+				filePath = filepath.Join(path.Dir(h.Path), filePath)
+			}
+			// The file is read by Shell-operator, so check that the hook's user can read it.
+			if sc := h.SecurityContext(); sc != nil && sc.RunAsUser {
+				if err := checkReadableBy(filePath, sc.Uid, sc.Gid, sc.Groups); err != nil {
+					return nil, fmt.Errorf("env '%s': %v", envVar.Name, err)
+				}
+			}
+			content, err := ioutil.ReadFile(filePath)
+			if err != nil {
+				return nil, fmt.Errorf("env '%s': %v", envVar.Name, err)
+			}
+			value = strings.TrimRight(string(content), "\n")
+		case envVar.ValueFromEnv != "":
+			value = os.Getenv(envVar.ValueFromEnv)
+		}
+		envs = append(envs, fmt.Sprintf("%s=%s", envVar.Name, value))
+	}
+	return envs, nil
+}
+
+// checkReadableBy returns an error if the file can not be read by the user
+// according to permissions of the file and its parent directories.
+func checkReadableBy(filePath string, uid uint32, gid uint32, groups []uint32) error {
+	realPath, err := filepath.EvalSymlinks(filePath)
+	if err != nil {
+		return err
+	}
+	realPath, err = filepath.Abs(realPath)
+	if err != nil {
+		return err
+	}
+
+	allowed := func(p string, perm os.FileMode) (bool, error) {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return false, err
+		}
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok || uid == 0 {
+			return true, nil
+		}
+		mode := fi.Mode().Perm()
+		if st.Uid == uid {
+			return mode&(perm<<6) != 0, nil
+		}
+		inGroup := st.Gid == gid
+		for _, g := range groups {
+			inGroup = inGroup || st.Gid == g
+		}
+		if inGroup {
+			return mode&(perm<<3) != 0, nil
+		}
+		return mode&perm != 0, nil
+	}
+
+	for dir := filepath.Dir(realPath); ; dir = filepath.Dir(dir) {
+		ok, err := allowed(dir, 01)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("directory '%s' is not accessible by uid %d", dir, uid)
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	ok, err := allowed(realPath, 04)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("file '%s' is not readable by uid %d", filePath, uid)
+	}
+	return nil
+}
+
+// WorkingDir returns a working directory for the hook process.
+// Relative path in settings is relative to the hook's directory.
+func (h *Hook) WorkingDir() string {
+	hookDir := path.Dir(h.Path)
+	if h.Config.Settings == nil || h.Config.Settings.WorkingDir == "" {
+		return hookDir
+	}
+	if filepath.IsAbs(h.Config.Settings.WorkingDir) {
+		return h.Config.Settings.WorkingDir
+	}
+	return filepath.Join(hookDir, h.Config.Settings.WorkingDir)
+}
+
+// Args returns extra arguments for the hook process.
+func (h *Hook) Args() []string {
+	if h.Config.Settings == nil {
+		return []string{}
+	}
+	return append([]string{}, h.Config.Settings.Args...)
+}
@@ -0,0 +1,123 @@
+package hook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/flant/shell-operator/pkg/hook/types"
+
+	"github.com/flant/shell-operator/pkg/app"
+)
+
+func Test_FilterEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/bin",
+		"HOME=/root",
+		"KUBERNETES_SERVICE_HOST=10.0.0.1",
+		"KUBERNETES_SERVICE_PORT=443",
+		"HTTPS_PROXY=http://proxy",
+		"EMPTY=",
+	}
+
+	tests := []struct {
+		name      string
+		allowlist []string
+		denylist  []string
+		expect    []string
+	}{
+		{
+			"no lists",
+			nil,
+			nil,
+			environ,
+		},
+		{
+			"allowlist with pattern",
+			[]string{"PATH", "KUBERNETES_*"},
+			nil,
+			[]string{"PATH=/bin", "KUBERNETES_SERVICE_HOST=10.0.0.1", "KUBERNETES_SERVICE_PORT=443"},
+		},
+		{
+			"denylist",
+			nil,
+			[]string{"*_PROXY", "EMPTY"},
+			[]string{"PATH=/bin", "HOME=/root", "KUBERNETES_SERVICE_HOST=10.0.0.1", "KUBERNETES_SERVICE_PORT=443"},
+		},
+		{
+			"denylist wins",
+			[]string{"KUBERNETES_*"},
+			[]string{"KUBERNETES_SERVICE_PORT"},
+			[]string{"KUBERNETES_SERVICE_HOST=10.0.0.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(FilterEnv(environ, tt.allowlist, tt.denylist)).To(Equal(tt.expect))
+		})
+	}
+}
+
+func Test_Hook_EnvLists_Narrow_Flags(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(allow, deny string) {
+		app.HookEnvAllowlist = allow
+		app.HookEnvDenylist = deny
+	}(app.HookEnvAllowlist, app.HookEnvDenylist)
+	app.HookEnvAllowlist = "PATH, HOME, KUBERNETES_*"
+	app.HookEnvDenylist = "HOME"
+
+	environ := []string{"PATH=/bin", "HOME=/root", "KUBERNETES_SERVICE_HOST=10.0.0.1", "AWS_SECRET=secret"}
+
+	h := NewHook("hook.sh", "/hooks/hook.sh")
+	g.Expect(h.filterEnv(environ)).To(Equal([]string{"PATH=/bin", "KUBERNETES_SERVICE_HOST=10.0.0.1"}))
+
+	// Lists from settings can not allow variables denied by flags.
+	h.Config.Settings = &Settings{
+		EnvAllowlist: []string{"PATH", "HOME", "AWS_*"},
+		EnvDenylist:  []string{},
+	}
+	g.Expect(h.filterEnv(environ)).To(Equal([]string{"PATH=/bin"}))
+
+	h.Config.Settings = &Settings{
+		EnvDenylist: []string{"PATH"},
+	}
+	g.Expect(h.filterEnv(environ)).To(Equal([]string{"KUBERNETES_SERVICE_HOST=10.0.0.1"}))
+
+	// valueFrom.env can not read denied variables.
+	h.Config.Settings = &Settings{
+		Env: []EnvVar{{Name: "HOST", ValueFromEnv: "KUBERNETES_SERVICE_HOST"}},
+	}
+	g.Expect(h.checkEnvSources()).To(Succeed())
+	h.Config.Settings = &Settings{
+		Env: []EnvVar{{Name: "SECRET", ValueFromEnv: "AWS_SECRET"}},
+	}
+	g.Expect(h.checkEnvSources()).NotTo(Succeed())
+}
+
+func Test_checkReadableBy(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	g.Expect(os.Chmod(filepath.Dir(tmpDir), 0755)).Should(Succeed())
+	g.Expect(os.Chmod(tmpDir, 0755)).Should(Succeed())
+	public := filepath.Join(tmpDir, "public")
+	private := filepath.Join(tmpDir, "private")
+	g.Expect(ioutil.WriteFile(public, []byte("public"), 0644)).Should(Succeed())
+	g.Expect(ioutil.WriteFile(private, []byte("private"), 0600)).Should(Succeed())
+
+	uid := uint32(os.Getuid()) + 1
+	g.Expect(checkReadableBy(public, uid, uid, nil)).To(Succeed())
+	g.Expect(checkReadableBy(private, uid, uid, nil)).NotTo(Succeed())
+	g.Expect(checkReadableBy(private, uint32(os.Getuid()), uid, nil)).To(Succeed())
+
+	// Files in a private directory are not readable.
+	g.Expect(os.Chmod(tmpDir, 0700)).Should(Succeed())
+	g.Expect(checkReadableBy(public, uid, uid, nil)).NotTo(Succeed())
+}
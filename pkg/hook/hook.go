@@ -1,13 +1,14 @@
 package hook
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/kennygrant/sanitize"
 	"golang.org/x/time/rate"
@@ -44,8 +45,15 @@ type Hook struct {
 
 	HookController controller.HookController
 	RateLimiter    *rate.Limiter
+	// bindingRateLimiters are rate limiters for bindings with own execution rate settings.
+	bindingRateLimiters map[string]*rate.Limiter
 
 	TmpDir string
+
+	// worker is a long-running process for the "worker" execution mode.
+	worker *hookWorker
+
+	lastRun lastRunStore
 }
 
 func NewHook(name, path string) *Hook {
@@ -66,7 +74,21 @@ func (h *Hook) LoadConfig(configOutput []byte) (hook *Hook, err error) {
 		return h, fmt.Errorf("load hook '%s' config: %s\nhook --config output: %s", h.Name, err.Error(), configOutput)
 	}
 
+	err = h.checkEnvSources()
+	if err != nil {
+		return h, fmt.Errorf("load hook '%s' config: %s", h.Name, err.Error())
+	}
+
 	h.RateLimiter = CreateRateLimiter(h.Config)
+	h.bindingRateLimiters = createBindingRateLimiters(h.Config)
+
+	if h.ExecutionMode() == ExecutionModeWorker {
+		h.worker = newHookWorker(h.Name, h.Path, h.Config.Settings.WorkerHealthCheckPeriod, h.Limits())
+		h.worker.dir = h.WorkingDir()
+		h.worker.args = h.Args()
+		h.worker.makeEnv = h.makeEnv
+		h.worker.security = h.SecurityContext()
+	}
 
 	return h, nil
 }
@@ -75,7 +97,12 @@ func (h *Hook) GetConfig() *config.HookConfig {
 	return h.Config
 }
 
-func (h *Hook) RateLimitWait(ctx context.Context) error {
+// RateLimitWait waits for the rate limiter of the binding if the binding has
+// own execution rate settings, otherwise for the rate limiter of the hook.
+func (h *Hook) RateLimitWait(ctx context.Context, bindingType BindingType, bindingName string) error {
+	if l, has := h.bindingRateLimiters[bindingRateLimiterKey(bindingType, bindingName)]; has {
+		return l.Wait(ctx)
+	}
 	return h.RateLimiter.Wait(ctx)
 }
 
@@ -83,12 +110,98 @@ func (h *Hook) WithHookController(hookController controller.HookController) {
 	h.HookController = hookController
 }
 
-func (h *Hook) Run(bindingType BindingType, context []BindingContext, logLabels map[string]string) (*HookResult, error) {
+func (h *Hook) Run(bindingType BindingType, context []BindingContext, logLabels map[string]string, taskInfo TaskInfo) (*HookResult, error) {
+	startedAt := time.Now()
+	output := newRunOutput()
+
+	result, err := h.run(bindingType, context, logLabels, taskInfo, output)
+
+	h.lastRun.set(newLastRun(startedAt, bindingType, context, result, output, err))
+	return result, err
+}
+
+// LastRun returns information about the last execution or nil if the hook is not executed yet.
+func (h *Hook) LastRun() *LastRun {
+	return h.lastRun.get()
+}
+
+func (h *Hook) run(bindingType BindingType, context []BindingContext, logLabels map[string]string, taskInfo TaskInfo, output *runOutput) (*HookResult, error) {
 	// Refresh snapshots
 	freshBindingContext := h.HookController.UpdateSnapshots(context)
 
 	versionedContextList := ConvertBindingContextList(h.Config.Version, freshBindingContext)
 
+	return h.runVersioned(bindingType, versionedContextList, context, logLabels, taskInfo, output)
+}
+
+// RunWithBindingContextList executes the hook with binding contexts in the format
+// of the hook's config version, e.g. saved from the BINDING_CONTEXT_PATH file.
+// Snapshots are passed as is. Binding types are detected by binding names.
+func (h *Hook) RunWithBindingContextList(contextList BindingContextList, logLabels map[string]string, taskInfo TaskInfo) (*HookResult, error) {
+	if len(contextList) == 0 {
+		return nil, fmt.Errorf("binding context is empty")
+	}
+
+	context := make([]BindingContext, 0, len(contextList))
+	for i, item := range contextList {
+		bindingName, _ := item["binding"].(string)
+		bindingType, err := h.bindingTypeByName(bindingName)
+		if err != nil {
+			return nil, fmt.Errorf("binding context [%d]: %v", i, err)
+		}
+		bc := BindingContext{Binding: bindingName}
+		bc.Metadata.Version = h.Config.Version
+		bc.Metadata.BindingType = bindingType
+		context = append(context, bc)
+	}
+
+	return h.runVersioned(context[0].Metadata.BindingType, contextList, context, logLabels, taskInfo, newRunOutput())
+}
+
+// bindingTypeByName returns a type of the binding or the group with the name.
+func (h *Hook) bindingTypeByName(name string) (BindingType, error) {
+	if name == string(OnStartup) && h.Config.OnStartup != nil {
+		return OnStartup, nil
+	}
+	for _, cfg := range h.Config.Schedules {
+		if cfg.BindingName == name || (cfg.Group != "" && cfg.Group == name) {
+			return Schedule, nil
+		}
+	}
+	for _, cfg := range h.Config.OnKubernetesEvents {
+		if cfg.BindingName == name || (cfg.Group != "" && cfg.Group == name) {
+			return OnKubernetesEvent, nil
+		}
+	}
+	for _, cfg := range h.Config.KubernetesValidating {
+		if cfg.BindingName == name {
+			return KubernetesValidating, nil
+		}
+	}
+	for _, cfg := range h.Config.KubernetesConversion {
+		if cfg.BindingName == name {
+			return KubernetesConversion, nil
+		}
+	}
+	return "", fmt.Errorf("hook has no binding '%s'", name)
+}
+
+// runVersioned executes the hook with the binding contexts converted to the
+// format of the hook's config version.
+func (h *Hook) runVersioned(bindingType BindingType, versionedContextList BindingContextList, context []BindingContext, logLabels map[string]string, taskInfo TaskInfo, output *runOutput) (*HookResult, error) {
+	if h.ExecutionMode() == ExecutionModeWorker {
+		return h.runInWorker(versionedContextList, context, h.standardEnv(bindingType, context, taskInfo))
+	}
+
+	envs, err := h.makeEnv(h.standardEnv(bindingType, context, taskInfo))
+	if err != nil {
+		return nil, err
+	}
+
+	if h.BindingContextDelivery() == BindingContextDeliveryStdin {
+		return h.runWithStdin(versionedContextList, context, envs, logLabels, output)
+	}
+
 	contextPath, err := h.prepareBindingContextJsonFile(versionedContextList)
 	if err != nil {
 		return nil, err
@@ -125,8 +238,6 @@ func (h *Hook) Run(bindingType BindingType, context []BindingContext, logLabels
 		}
 	}()
 
-	envs := []string{}
-	envs = append(envs, os.Environ()...)
 	if contextPath != "" {
 		envs = append(envs, fmt.Sprintf("BINDING_CONTEXT_PATH=%s", contextPath))
 		envs = append(envs, fmt.Sprintf("METRICS_PATH=%s", metricsPath))
@@ -135,38 +246,261 @@ func (h *Hook) Run(bindingType BindingType, context []BindingContext, logLabels
 		envs = append(envs, fmt.Sprintf("KUBERNETES_PATCH_PATH=%s", kubernetesPatchPath))
 	}
 
-	hookCmd := executor.MakeCommand(path.Dir(h.Path), h.Path, []string{}, envs)
+	hookCmd := executor.MakeCommand(h.WorkingDir(), h.Path, h.Args(), envs)
+
+	result := &HookResult{}
+
+	result.Usage, err = executor.RunAndLogLinesWithOptions(hookCmd, logLabels, executor.RunOptions{
+		Timeout:     h.ExecutionTimeout(context),
+		GracePeriod: app.HookTerminationGracePeriod,
+		Limits:      h.Limits(),
+		Security:    h.SecurityContext(),
+		Stdout:      output.Stdout,
+		Stderr:      output.Stderr,
+	})
+	if err != nil {
+		return result, h.runError(err, output)
+	}
+
+	response := &hookResponse{}
+
+	response.Metrics, err = readResponseFile(metricsPath, ResponseChannelMetrics, app.HookMaxMetricsSize)
+	if err != nil {
+		return result, fmt.Errorf("can't read metrics file: %w", err)
+	}
+
+	response.Validating, err = readResponseFile(validatingPath, ResponseChannelValidating, app.HookMaxValidatingResponseSize)
+	if err != nil {
+		return result, fmt.Errorf("can't read validating response file: %w", err)
+	}
+
+	response.Conversion, err = readResponseFile(conversionPath, ResponseChannelConversion, app.HookMaxConversionResponseSize)
+	if err != nil {
+		return result, fmt.Errorf("can't read conversion response file: %w", err)
+	}
+
+	response.KubernetesPatch, err = readResponseFile(kubernetesPatchPath, ResponseChannelKubernetesPatch, app.HookMaxKubernetesPatchSize)
+	if err != nil {
+		return result, fmt.Errorf("can't read object patch file: %w", err)
+	}
+
+	err = response.fillResult(result)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// runWithStdin streams binding context to the hook's stdin and reads
+// tagged JSON lines with responses from the file descriptor 3.
+func (h *Hook) runWithStdin(versionedContextList BindingContextList, context []BindingContext, envs []string, logLabels map[string]string, output *runOutput) (*HookResult, error) {
+	data, err := versionedContextList.Json()
+	if err != nil {
+		return nil, err
+	}
+
+	envs = append(envs, fmt.Sprintf("BINDING_CONTEXT_DELIVERY=%s", BindingContextDeliveryStdin))
+	envs = append(envs, fmt.Sprintf("HOOK_RESPONSE_FD=%d", executor.ResponseFd))
+
+	hookCmd := executor.MakeCommand(h.WorkingDir(), h.Path, h.Args(), envs)
 
 	result := &HookResult{}
 
-	result.Usage, err = executor.RunAndLogLines(hookCmd, logLabels)
+	responseBuf := new(bytes.Buffer)
+	// Sizes of channels are checked after parsing, this limit protects from unbounded writes.
+	responseOutput := &executor.LimitedWriter{
+		W:      responseBuf,
+		Output: "response",
+		Limit:  maxResponseSize(),
+	}
+	result.Usage, err = executor.RunAndLogLinesWithOptions(hookCmd, logLabels, executor.RunOptions{
+		Timeout:        h.ExecutionTimeout(context),
+		GracePeriod:    app.HookTerminationGracePeriod,
+		ResponseOutput: responseOutput,
+		Limits:         h.Limits(),
+		Security:       h.SecurityContext(),
+		Stdout:         output.Stdout,
+		Stderr:         output.Stderr,
+		Stdin:          bytes.NewReader(data),
+	})
 	if err != nil {
-		return result, fmt.Errorf("%s FAILED: %s", h.Name, err)
+		return result, h.runError(err, output)
 	}
 
-	result.Metrics, err = operation.MetricOperationsFromFile(metricsPath)
+	response, err := hookResponseFromLines(responseBuf.Bytes())
 	if err != nil {
-		return result, fmt.Errorf("got bad metrics: %s", err)
+		return result, fmt.Errorf("got bad response from fd %d: %s", executor.ResponseFd, err)
+	}
+
+	err = response.fillResult(result)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// runError wraps the error of the hook process with tails of its output.
+func (h *Hook) runError(err error, output *runOutput) error {
+	return &RunError{
+		Err:    fmt.Errorf("%s FAILED: %w", h.Name, err),
+		Stdout: tailString(output.Stdout),
+		Stderr: tailString(output.Stderr),
 	}
+}
 
-	result.ValidatingResponse, err = ValidatingResponseFromFile(validatingPath)
+// runInWorker sends binding context to the long-running worker process.
+func (h *Hook) runInWorker(versionedContextList BindingContextList, context []BindingContext, standardEnv map[string]string) (*HookResult, error) {
+	data, err := versionedContextList.Json()
 	if err != nil {
-		return result, fmt.Errorf("got bad validating response: %s", err)
+		return nil, err
 	}
 
-	result.ConversionResponse, err = conversion.ResponseFromFile(conversionPath)
+	result := &HookResult{}
+
+	response, err := h.worker.Run(data, standardEnv, h.ExecutionTimeout(context))
 	if err != nil {
-		return result, fmt.Errorf("got bad conversion response: %s", err)
+		return result, fmt.Errorf("%s FAILED: %w", h.Name, err)
 	}
 
-	result.KubernetesPatchBytes, err = ioutil.ReadFile(kubernetesPatchPath)
+	err = response.fillResult(result)
 	if err != nil {
-		return result, fmt.Errorf("can't read object patch file: %s", err)
+		return result, err
 	}
 
 	return result, nil
 }
 
+// Limits returns resource limits for the hook process or nil if limits are not set.
+func (h *Hook) Limits() *executor.ResourceLimits {
+	if h.Config.Settings == nil || h.Config.Settings.Limits == nil {
+		return nil
+	}
+	limits := h.Config.Settings.Limits
+	return &executor.ResourceLimits{
+		Memory:       limits.Memory,
+		AddressSpace: limits.AddressSpace,
+		CPUTime:      limits.CPUTime,
+		NoFile:       limits.NoFile,
+		NProc:        limits.NProc,
+	}
+}
+
+// SecurityContext returns a user and privileges for the hook process or nil if the hook runs as Shell-operator.
+func (h *Hook) SecurityContext() *executor.SecurityContext {
+	if h.Config.Settings == nil || h.Config.Settings.Security == nil {
+		return nil
+	}
+	sc := h.Config.Settings.Security
+	return &executor.SecurityContext{
+		RunAsUser:      sc.RunAsUser,
+		Uid:            sc.Uid,
+		Gid:            sc.Gid,
+		Groups:         sc.Groups,
+		DropPrivileges: sc.DropPrivileges,
+	}
+}
+
+// writeTmpFile writes a file into TmpDir. The file is owned by the hook's user
+// if runAs is set, so the hook can read the binding context and write responses.
+func (h *Hook) writeTmpFile(filePath string, data []byte) error {
+	err := ioutil.WriteFile(filePath, data, 0644)
+	if err != nil {
+		return err
+	}
+	sc := h.SecurityContext()
+	if sc == nil || !sc.RunAsUser {
+		return nil
+	}
+	err = os.Chown(filePath, int(sc.Uid), int(sc.Gid))
+	if err != nil {
+		return err
+	}
+	// Binding context can contain Secrets, so make files private to the hook's user.
+	return os.Chmod(filePath, 0600)
+}
+
+// ExecutionMode returns "worker" if the hook is a long-running worker or "exec" otherwise.
+func (h *Hook) ExecutionMode() string {
+	if h.Config.Settings != nil && h.Config.Settings.ExecutionMode != "" {
+		return h.Config.Settings.ExecutionMode
+	}
+	return ExecutionModeExec
+}
+
+// StartWorker starts the worker process if the hook is in the "worker" mode
+// and the worker should be started right after loading the config.
+func (h *Hook) StartWorker() error {
+	if h.worker == nil || h.Config.Settings.WorkerStart != WorkerStartOnConfig {
+		return nil
+	}
+	return h.worker.Start()
+}
+
+// StopWorker stops the worker process if the hook is in the "worker" mode.
+func (h *Hook) StopWorker() {
+	if h.worker != nil {
+		h.worker.Stop()
+	}
+}
+
+// BindingContextDelivery returns a method to pass binding context to the hook.
+func (h *Hook) BindingContextDelivery() string {
+	if h.Config.Settings != nil && h.Config.Settings.BindingContextDelivery != "" {
+		return h.Config.Settings.BindingContextDelivery
+	}
+	return BindingContextDeliveryFile
+}
+
+// ExecutionTimeout returns a timeout for the hook execution with binding contexts.
+// executionTimeout of the binding overrides the value from settings. The largest
+// timeout is used if binding contexts for several bindings are combined.
+func (h *Hook) ExecutionTimeout(bindingContexts []BindingContext) time.Duration {
+	var defaultTimeout time.Duration
+	if h.Config.Settings != nil {
+		defaultTimeout = h.Config.Settings.ExecutionTimeout
+	}
+
+	var timeout time.Duration
+	for _, bc := range bindingContexts {
+		bindingTimeout := h.bindingExecutionTimeout(bc.Metadata.BindingType, bc.Binding)
+		if bindingTimeout == 0 {
+			bindingTimeout = defaultTimeout
+		}
+		// No timeout for one of the bindings means no timeout for the whole run.
+		if bindingTimeout == 0 {
+			return 0
+		}
+		if bindingTimeout > timeout {
+			timeout = bindingTimeout
+		}
+	}
+
+	if timeout == 0 {
+		return defaultTimeout
+	}
+	return timeout
+}
+
+func (h *Hook) bindingExecutionTimeout(bindingType BindingType, bindingName string) time.Duration {
+	switch bindingType {
+	case Schedule:
+		for _, cfg := range h.Config.Schedules {
+			if cfg.BindingName == bindingName {
+				return cfg.ExecutionTimeout
+			}
+		}
+	case OnKubernetesEvent:
+		for _, cfg := range h.Config.OnKubernetesEvents {
+			if cfg.BindingName == bindingName {
+				return cfg.ExecutionTimeout
+			}
+		}
+	}
+	return 0
+}
+
 func (h *Hook) SafeName() string {
 	return sanitize.BaseName(h.Name)
 }
@@ -233,6 +567,18 @@ func (h *Hook) GetConfigDescription() string {
 	}
 	if h.Config.Settings != nil {
 		msgs = append(msgs, fmt.Sprintf("Rate: %s/%d", h.Config.Settings.ExecutionMinInterval.String(), h.Config.Settings.ExecutionBurst))
+		if h.Config.Settings.ExecutionTimeout != 0 {
+			msgs = append(msgs, fmt.Sprintf("Timeout: %s", h.Config.Settings.ExecutionTimeout.String()))
+		}
+		if h.Config.Settings.ExecutionMode == ExecutionModeWorker {
+			msgs = append(msgs, "Worker mode")
+		}
+		if h.Config.Settings.BindingContextDelivery != "" {
+			msgs = append(msgs, fmt.Sprintf("Binding context delivery: %s", h.Config.Settings.BindingContextDelivery))
+		}
+		if h.Config.Settings.Limits != nil {
+			msgs = append(msgs, "Resource limits")
+		}
 	}
 	return strings.Join(msgs, ", ")
 }
@@ -246,7 +592,7 @@ func (h *Hook) prepareBindingContextJsonFile(context BindingContextList) (string
 
 	bindingContextPath := filepath.Join(h.TmpDir, fmt.Sprintf("hook-%s-binding-context-%s.json", h.SafeName(), uuid.NewV4().String()))
 
-	err = ioutil.WriteFile(bindingContextPath, data, 0644)
+	err = h.writeTmpFile(bindingContextPath, data)
 	if err != nil {
 		return "", err
 	}
@@ -257,7 +603,7 @@ func (h *Hook) prepareBindingContextJsonFile(context BindingContextList) (string
 func (h *Hook) prepareMetricsFile() (string, error) {
 	metricsPath := filepath.Join(h.TmpDir, fmt.Sprintf("hook-%s-metrics-%s.json", h.SafeName(), uuid.NewV4().String()))
 
-	err := ioutil.WriteFile(metricsPath, []byte{}, 0644)
+	err := h.writeTmpFile(metricsPath, []byte{})
 	if err != nil {
 		return "", err
 	}
@@ -268,7 +614,7 @@ func (h *Hook) prepareMetricsFile() (string, error) {
 func (h *Hook) prepareValidatingResponseFile() (string, error) {
 	validatingPath := filepath.Join(h.TmpDir, fmt.Sprintf("hook-%s-validating-response-%s.json", h.SafeName(), uuid.NewV4().String()))
 
-	err := ioutil.WriteFile(validatingPath, []byte{}, 0644)
+	err := h.writeTmpFile(validatingPath, []byte{})
 	if err != nil {
 		return "", err
 	}
@@ -279,7 +625,7 @@ func (h *Hook) prepareValidatingResponseFile() (string, error) {
 func (h *Hook) prepareConversionResponseFile() (string, error) {
 	conversionPath := filepath.Join(h.TmpDir, fmt.Sprintf("hook-%s-conversion-response-%s.json", h.SafeName(), uuid.NewV4().String()))
 
-	err := ioutil.WriteFile(conversionPath, []byte{}, 0644)
+	err := h.writeTmpFile(conversionPath, []byte{})
 	if err != nil {
 		return "", err
 	}
@@ -288,16 +634,59 @@ func (h *Hook) prepareConversionResponseFile() (string, error) {
 }
 
 func CreateRateLimiter(cfg *config.HookConfig) *rate.Limiter {
-	// Create rate limiter
-	limit := rate.Inf // no rate limit by default
-	burst := 1        // no more then 1 event at time
 	if cfg.Settings != nil {
-		if cfg.Settings.ExecutionMinInterval != 0 {
-			limit = rate.Every(cfg.Settings.ExecutionMinInterval)
+		return newRateLimiter(cfg.Settings.ExecutionMinInterval, cfg.Settings.ExecutionBurst)
+	}
+	return newRateLimiter(0, 0)
+}
+
+// createBindingRateLimiters returns rate limiters for bindings with executionMinInterval
+// or executionBurst settings. Unset values are taken from the hook settings.
+func createBindingRateLimiters(cfg *config.HookConfig) map[string]*rate.Limiter {
+	res := make(map[string]*rate.Limiter)
+
+	add := func(bindingType BindingType, bindingCfg CommonBindingConfig) {
+		if bindingCfg.ExecutionMinInterval == 0 && bindingCfg.ExecutionBurst == 0 {
+			return
 		}
-		if cfg.Settings.ExecutionBurst != 0 {
-			burst = cfg.Settings.ExecutionBurst
+		key := bindingRateLimiterKey(bindingType, bindingCfg.BindingName)
+		if _, has := res[key]; has {
+			return
 		}
+		interval := bindingCfg.ExecutionMinInterval
+		burst := bindingCfg.ExecutionBurst
+		if cfg.Settings != nil {
+			if interval == 0 {
+				interval = cfg.Settings.ExecutionMinInterval
+			}
+			if burst == 0 {
+				burst = cfg.Settings.ExecutionBurst
+			}
+		}
+		res[key] = newRateLimiter(interval, burst)
+	}
+
+	for _, schCfg := range cfg.Schedules {
+		add(Schedule, schCfg.CommonBindingConfig)
+	}
+	for _, kubeCfg := range cfg.OnKubernetesEvents {
+		add(OnKubernetesEvent, kubeCfg.CommonBindingConfig)
+	}
+
+	return res
+}
+
+func bindingRateLimiterKey(bindingType BindingType, bindingName string) string {
+	return string(bindingType) + "/" + bindingName
+}
+
+func newRateLimiter(minInterval time.Duration, burst int) *rate.Limiter {
+	limit := rate.Inf // no rate limit by default
+	if minInterval != 0 {
+		limit = rate.Every(minInterval)
+	}
+	if burst == 0 {
+		burst = 1 // no more then 1 event at time
 	}
 	return rate.NewLimiter(limit, burst)
 }
@@ -305,7 +694,7 @@ func CreateRateLimiter(cfg *config.HookConfig) *rate.Limiter {
 func (h *Hook) prepareObjectPatchFile() (string, error) {
 	objectPatchPath := filepath.Join(h.TmpDir, fmt.Sprintf("%s-object-patch-%s", h.SafeName(), uuid.NewV4().String()))
 
-	err := ioutil.WriteFile(objectPatchPath, []byte{}, 0644)
+	err := h.writeTmpFile(objectPatchPath, []byte{})
 	if err != nil {
 		return "", err
 	}
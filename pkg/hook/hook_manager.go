@@ -7,13 +7,16 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
+	"github.com/hashicorp/go-multierror"
 	log "github.com/sirupsen/logrus"
 
 	. "github.com/flant/shell-operator/pkg/hook/types"
 	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
 	. "github.com/flant/shell-operator/pkg/webhook/validating/types"
 
+	"github.com/flant/shell-operator/pkg/app"
 	"github.com/flant/shell-operator/pkg/executor"
 	"github.com/flant/shell-operator/pkg/hook/controller"
 	"github.com/flant/shell-operator/pkg/kube_events_manager"
@@ -25,6 +28,7 @@ import (
 
 type HookManager interface {
 	Init() error
+	Reload() (*HooksReload, error)
 	Run()
 	WithDirectories(workingDir string, tempDir string)
 	WithKubeEventManager(kube_events_manager.KubeEventsManager)
@@ -34,7 +38,12 @@ type HookManager interface {
 	WorkingDir() string
 	TempDir() string
 	GetHook(name string) *Hook
+	LookupHook(name string) *Hook
 	GetHookNames() []string
+	GetInvalidHooks() []InvalidHook
+	DisableHook(name string) error
+	EnableHook(name string) error
+	IsHookDisabled(name string) bool
 	GetHooksInOrder(bindingType BindingType) ([]string, error)
 	HandleKubeEvent(kubeEvent KubeEvent, createTaskFn func(*Hook, controller.BindingExecutionInfo))
 	HandleScheduleEvent(crontab string, createTaskFn func(*Hook, controller.BindingExecutionInfo))
@@ -62,6 +71,18 @@ type hookManager struct {
 
 	// Index crdName -> fromVersion -> conversionLink
 	conversionChains *conversion.ChainStorage
+
+	// checksums of hook executables to detect changes on reload
+	checksums map[string]string
+
+	// hooks with invalid configurations quarantined by the "skip-invalid" load policy
+	invalidHooks []InvalidHook
+
+	// hooks disabled at runtime, events for these hooks are ignored
+	disabledHooks map[string]bool
+
+	// m protects indices: hooks are reloaded while queues are running.
+	m sync.RWMutex
 }
 
 // hookManager should implement HookManager
@@ -73,6 +94,8 @@ func NewHookManager() *hookManager {
 		hookNamesInOrder: make([]string, 0),
 		hooksInOrder:     make(map[BindingType][]*Hook),
 		conversionChains: conversion.NewChainStorage(),
+		checksums:        make(map[string]string),
+		disabledHooks:    make(map[string]bool),
 	}
 }
 
@@ -109,34 +132,161 @@ func (hm *hookManager) TempDir() string {
 func (hm *hookManager) Init() error {
 	log.Info("Initialize hooks manager. Search for and load all hooks.")
 
-	hm.hooksInOrder = make(map[BindingType][]*Hook)
-	hm.hooksByName = make(map[string]*Hook)
+	hooksRelativePaths, err := hm.searchHooks()
+	if err != nil {
+		return err
+	}
 
-	hooksRelativePaths, err := utils_file.RecursiveGetExecutablePaths(hm.workingDir)
+	checksums := make(map[string]string)
+	for _, hookPath := range hooksRelativePaths {
+		sum, err := hookChecksum(hookPath)
+		if err != nil {
+			return err
+		}
+		hookName, err := filepath.Rel(hm.workingDir, hookPath)
+		if err != nil {
+			return err
+		}
+		checksums[hookName] = sum
+	}
+
+	hooks, invalidHooks, err := hm.loadHooks(hooksRelativePaths)
 	if err != nil {
 		return err
 	}
+	hooks, invalidHooks = quarantineDependents(hooks, invalidHooks, nil)
+
+	err = hm.updateIndices(hooks, checksums)
+	if err != nil {
+		stopWorkers(hooks)
+		return err
+	}
+	hm.setInvalidHooks(invalidHooks)
+	return nil
+}
+
+// loadHooks loads configs of hooks concurrently. The number of concurrently
+// loaded hooks is limited by the --hooks-config-load-parallelism flag.
+// Hooks are returned in order of paths.
+//
+// Errors for all broken hooks are combined into one multierror. If the load
+// policy is "skip-invalid", broken hooks are returned as invalid hooks instead.
+func (hm *hookManager) loadHooks(hookPaths []string) ([]*Hook, []InvalidHook, error) {
+	hooks := make([]*Hook, len(hookPaths))
+	errs := make([]error, len(hookPaths))
+
+	parallelism := app.HooksConfigLoadParallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	sem := make(chan struct{}, parallelism)
+
+	var wg sync.WaitGroup
+	for i, hookPath := range hookPaths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, hookPath string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			hooks[i], errs[i] = hm.loadHook(hookPath)
+		}(i, hookPath)
+	}
+	wg.Wait()
+
+	if app.HooksLoadPolicy == app.HooksLoadPolicySkipInvalid {
+		validHooks := make([]*Hook, 0, len(hooks))
+		invalidHooks := make([]InvalidHook, 0)
+		for i, err := range errs {
+			if err == nil {
+				validHooks = append(validHooks, hooks[i])
+				continue
+			}
+			hookName, _ := filepath.Rel(hm.workingDir, hookPaths[i])
+			log.WithField("hook", hookName).
+				WithField("phase", "config").
+				Errorf("Hook is quarantined: %v", err)
+			invalidHooks = append(invalidHooks, InvalidHook{Name: hookName, Error: err.Error()})
+		}
+		return validHooks, invalidHooks, nil
+	}
+
+	var allErr *multierror.Error
+	for _, err := range errs {
+		if err != nil {
+			allErr = multierror.Append(allErr, err)
+		}
+	}
+	if allErr != nil {
+		for _, hook := range hooks {
+			if hook != nil {
+				hook.StopWorker()
+			}
+		}
+		return nil, nil, allErr
+	}
+
+	return hooks, nil, nil
+}
+
+// searchHooks returns sorted paths of executable files in WorkingDir.
+func (hm *hookManager) searchHooks() ([]string, error) {
+	hooksRelativePaths, err := utils_file.RecursiveGetExecutablePaths(hm.workingDir)
+	if err != nil {
+		return nil, err
+	}
 
 	// sort hooks by path
 	sort.Strings(hooksRelativePaths)
 	log.Debugf("  Search hooks in this paths: %+v", hooksRelativePaths)
 
-	for _, hookPath := range hooksRelativePaths {
-		hook, err := hm.loadHook(hookPath)
-		if err != nil {
-			return err
-		}
+	return hooksRelativePaths, nil
+}
+
+// updateIndices replaces all indices with the new list of hooks sorted by path.
+// Hooks are reordered to follow hooks from their settings.dependsOn.
+func (hm *hookManager) updateIndices(hooks []*Hook, checksums map[string]string) error {
+	hooksInOrder := make(map[BindingType][]*Hook)
+	hooksByName := make(map[string]*Hook)
+	hookNamesInOrder := make([]string, 0, len(hooks))
+
+	for _, hook := range hooks {
+		hooksByName[hook.Name] = hook
+		hookNamesInOrder = append(hookNamesInOrder, hook.Name)
+	}
 
+	// Check settings.dependsOn and sort hooks to follow their dependencies.
+	err := checkDependencies(hooksByName, hookNamesInOrder)
+	if err != nil {
+		return err
+	}
+	hooks = orderByDependencies(hooks, hooksByName)
+
+	hookNamesInOrder = hookNamesInOrder[:0]
+	for _, hook := range hooks {
 		// register hook in indices
 		for _, binding := range hook.Config.Bindings() {
-			hm.hooksInOrder[binding] = append(hm.hooksInOrder[binding], hook)
+			hooksInOrder[binding] = append(hooksInOrder[binding], hook)
+		}
+		hookNamesInOrder = append(hookNamesInOrder, hook.Name)
+	}
+
+	hm.m.Lock()
+	defer hm.m.Unlock()
+	hm.hooksInOrder = hooksInOrder
+	hm.hooksByName = hooksByName
+	hm.hookNamesInOrder = hookNamesInOrder
+	hm.checksums = checksums
+
+	// A removed hook is enabled if it is added again.
+	for name := range hm.disabledHooks {
+		if _, has := hooksByName[name]; !has {
+			delete(hm.disabledHooks, name)
 		}
-		hm.hooksByName[hook.Name] = hook
-		hm.hookNamesInOrder = append(hm.hookNamesInOrder, hook.Name)
 	}
 
 	// Validate conversion chains and create index with conversion paths.
-	err = hm.UpdateConversionChains()
+	hm.conversionChains = conversion.NewChainStorage()
+	err = hm.updateConversionChains()
 	if err != nil {
 		return fmt.Errorf("check conversion configs: %v", err)
 	}
@@ -144,6 +294,52 @@ func (hm *hookManager) Init() error {
 	return nil
 }
 
+// setInvalidHooks replaces the list of quarantined hooks.
+func (hm *hookManager) setInvalidHooks(invalidHooks []InvalidHook) {
+	hm.m.Lock()
+	defer hm.m.Unlock()
+	hm.invalidHooks = invalidHooks
+}
+
+// GetInvalidHooks returns hooks quarantined because of invalid configurations.
+func (hm *hookManager) GetInvalidHooks() []InvalidHook {
+	hm.m.RLock()
+	defer hm.m.RUnlock()
+	res := make([]InvalidHook, len(hm.invalidHooks))
+	copy(res, hm.invalidHooks)
+	return res
+}
+
+// DisableHook pauses the hook: schedule and kubernetes events are ignored
+// until the hook is enabled. Monitors continue to update snapshots.
+// The hook stays disabled if it is changed on reload.
+func (hm *hookManager) DisableHook(name string) error {
+	hm.m.Lock()
+	defer hm.m.Unlock()
+	if _, has := hm.hooksByName[name]; !has {
+		return fmt.Errorf("hook '%s' is not found", name)
+	}
+	hm.disabledHooks[name] = true
+	return nil
+}
+
+// EnableHook resumes handling of events for the disabled hook.
+func (hm *hookManager) EnableHook(name string) error {
+	hm.m.Lock()
+	defer hm.m.Unlock()
+	if _, has := hm.hooksByName[name]; !has {
+		return fmt.Errorf("hook '%s' is not found", name)
+	}
+	delete(hm.disabledHooks, name)
+	return nil
+}
+
+func (hm *hookManager) IsHookDisabled(name string) bool {
+	hm.m.RLock()
+	defer hm.m.RUnlock()
+	return hm.disabledHooks[name]
+}
+
 // TODO move --config execution to a Hook method
 func (hm *hookManager) loadHook(hookPath string) (hook *Hook, err error) {
 	hookName, err := filepath.Rel(hm.workingDir, hookPath)
@@ -155,23 +351,37 @@ func (hm *hookManager) loadHook(hookPath string) (hook *Hook, err error) {
 	hookEntry := log.WithField("hook", hook.Name).
 		WithField("phase", "config")
 
-	hookEntry.Infof("Load config from '%s'", hookPath)
-
-	envs := []string{}
-	configOutput, err := hm.execCommandOutput(hook.Name, hm.workingDir, hookPath, envs, []string{"--config"})
+	// Use a static config if present to not execute the hook.
+	configOutput, configSource, err := LoadStaticConfig(hookPath)
 	if err != nil {
-		hookEntry.Errorf("Hook config output:\n%s", string(configOutput))
-		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
-			hookEntry.Errorf("Hook config stderr:\n%s", string(ee.Stderr))
-		}
 		return nil, fmt.Errorf("cannot get config for hook '%s': %s", hookPath, err)
 	}
 
+	if configOutput != nil {
+		hookEntry.Infof("Load config from %s", configSource)
+	} else {
+		hookEntry.Infof("Load config from '%s'", hookPath)
+
+		configOutput, err = RunConfigCommand(hm.workingDir, hook.Name, hookPath)
+		if err != nil {
+			hookEntry.Errorf("Hook config output:\n%s", string(configOutput))
+			if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+				hookEntry.Errorf("Hook config stderr:\n%s", string(ee.Stderr))
+			}
+			return nil, fmt.Errorf("cannot get config for hook '%s': %s", hookPath, err)
+		}
+	}
+
 	_, err = hook.LoadConfig(configOutput)
 	if err != nil {
 		return nil, fmt.Errorf("creating hook '%s': %s", hookName, err.Error())
 	}
 
+	// The --config run is executed with Shell-operator's privileges, so it can not be isolated.
+	if configSource == "" && hook.SecurityContext() != nil {
+		return nil, fmt.Errorf("creating hook '%s': runAs and dropPrivileges require a static config, the hook should not be executed with --config", hookName)
+	}
+
 	// Add hook info as log labels, update MetricLabels
 	for _, kubeCfg := range hook.GetConfig().OnKubernetesEvents {
 		kubeCfg.Monitor.Metadata.LogLabels["hook"] = hook.Name
@@ -206,13 +416,32 @@ func (hm *hookManager) loadHook(hookPath string) (hook *Hook, err error) {
 	hook.WithHookController(hookCtrl)
 	hook.WithTmpDir(hm.TempDir())
 
+	err = hook.StartWorker()
+	if err != nil {
+		return nil, fmt.Errorf("start worker for hook '%s': %v", hookName, err)
+	}
+
 	hookEntry.Infof("Loaded config: %s", hook.GetConfigDescription())
 
 	return hook, nil
 }
 
-func (hm *hookManager) execCommandOutput(hookName string, dir string, entrypoint string, envs []string, args []string) ([]byte, error) {
-	envs = append(os.Environ(), envs...)
+// LoadHook loads the hook from hookPath without managers for bindings. It is used
+// to execute the hook outside of the running operator.
+func LoadHook(workingDir string, tempDir string, hookPath string) (*Hook, error) {
+	hm := NewHookManager()
+	hm.WithDirectories(workingDir, tempDir)
+	return hm.loadHook(hookPath)
+}
+
+// RunConfigCommand executes the hook with --config flag and returns its output.
+func RunConfigCommand(workingDir string, hookName string, hookPath string) ([]byte, error) {
+	return execCommandOutput(hookName, workingDir, hookPath, []string{}, []string{"--config"})
+}
+
+func execCommandOutput(hookName string, dir string, entrypoint string, envs []string, args []string) ([]byte, error) {
+	operatorEnvs := FilterEnv(os.Environ(), SplitEnvList(app.HookEnvAllowlist), SplitEnvList(app.HookEnvDenylist))
+	envs = append(operatorEnvs, envs...)
 	cmd := executor.MakeCommand(dir, entrypoint, args, envs)
 	cmd.Stdout = nil
 	cmd.Stderr = nil
@@ -238,7 +467,9 @@ func (hm *hookManager) Run() {
 }
 
 func (hm *hookManager) GetHook(name string) *Hook {
+	hm.m.RLock()
 	hook, exists := hm.hooksByName[name]
+	hm.m.RUnlock()
 	if exists {
 		return hook
 	} else {
@@ -247,16 +478,31 @@ func (hm *hookManager) GetHook(name string) *Hook {
 	}
 }
 
+// LookupHook returns the hook or nil if the hook is not loaded. It is used to skip tasks for removed hooks.
+func (hm *hookManager) LookupHook(name string) *Hook {
+	hm.m.RLock()
+	defer hm.m.RUnlock()
+	return hm.hooksByName[name]
+}
+
 func (hm *hookManager) GetHookNames() []string {
+	hm.m.RLock()
+	defer hm.m.RUnlock()
 	return hm.hookNamesInOrder
 }
 
 func (hm *hookManager) GetHooksInOrder(bindingType BindingType) ([]string, error) {
-	hooks, ok := hm.hooksInOrder[bindingType]
+	hm.m.RLock()
+	indexed, ok := hm.hooksInOrder[bindingType]
+	hm.m.RUnlock()
 	if !ok {
 		return []string{}, nil
 	}
 
+	// Sort a copy as the index can be used concurrently.
+	hooks := make([]*Hook, len(indexed))
+	copy(hooks, indexed)
+
 	// OnStartup hooks are sorted by onStartup config value
 	// FIXME: onStartup value is now a config validating error, no need to check it here again.
 	if bindingType == OnStartup {
@@ -266,9 +512,14 @@ func (hm *hookManager) GetHooksInOrder(bindingType BindingType) ([]string, error
 			}
 		}
 
-		sort.Slice(hooks[:], func(i, j int) bool {
+		sort.SliceStable(hooks[:], func(i, j int) bool {
 			return hooks[i].Config.OnStartup.Order < hooks[j].Config.OnStartup.Order
 		})
+
+		// settings.dependsOn takes precedence over the onStartup order.
+		hm.m.RLock()
+		hooks = orderByDependencies(hooks, hm.hooksByName)
+		hm.m.RUnlock()
 	}
 
 	var hooksNames []string
@@ -279,12 +530,18 @@ func (hm *hookManager) GetHooksInOrder(bindingType BindingType) ([]string, error
 	return hooksNames, nil
 }
 
-func (hm *hookManager) HandleKubeEvent(kubeEvent KubeEvent, createTaskFn func(*Hook, controller.BindingExecutionInfo)) {
-	kubeHooks, _ := hm.GetHooksInOrder(OnKubernetesEvent)
-
-	for _, hookName := range kubeHooks {
-		h := hm.GetHook(hookName)
+// indexedHooks returns hooks with the binding type sorted by path.
+func (hm *hookManager) indexedHooks(bindingType BindingType) []*Hook {
+	hm.m.RLock()
+	defer hm.m.RUnlock()
+	return hm.hooksInOrder[bindingType]
+}
 
+func (hm *hookManager) HandleKubeEvent(kubeEvent KubeEvent, createTaskFn func(*Hook, controller.BindingExecutionInfo)) {
+	for _, h := range hm.indexedHooks(OnKubernetesEvent) {
+		if hm.IsHookDisabled(h.Name) {
+			continue
+		}
 		if h.HookController.CanHandleKubeEvent(kubeEvent) {
 			h.HookController.HandleKubeEvent(kubeEvent, func(info controller.BindingExecutionInfo) {
 				if createTaskFn != nil {
@@ -296,9 +553,10 @@ func (hm *hookManager) HandleKubeEvent(kubeEvent KubeEvent, createTaskFn func(*H
 }
 
 func (hm *hookManager) HandleScheduleEvent(crontab string, createTaskFn func(*Hook, controller.BindingExecutionInfo)) {
-	schHooks, _ := hm.GetHooksInOrder(Schedule)
-	for _, hookName := range schHooks {
-		h := hm.GetHook(hookName)
+	for _, h := range hm.indexedHooks(Schedule) {
+		if hm.IsHookDisabled(h.Name) {
+			continue
+		}
 		if h.HookController.CanHandleScheduleEvent(crontab) {
 			h.HookController.HandleScheduleEvent(crontab, func(info controller.BindingExecutionInfo) {
 				if createTaskFn != nil {
@@ -310,9 +568,7 @@ func (hm *hookManager) HandleScheduleEvent(crontab string, createTaskFn func(*Ho
 }
 
 func (hm *hookManager) HandleValidatingEvent(event ValidatingEvent, createTaskFn func(*Hook, controller.BindingExecutionInfo)) {
-	vHooks, _ := hm.GetHooksInOrder(KubernetesValidating)
-	for _, hookName := range vHooks {
-		h := hm.GetHook(hookName)
+	for _, h := range hm.indexedHooks(KubernetesValidating) {
 		if h.HookController.CanHandleValidatingEvent(event) {
 			h.HookController.HandleValidatingEvent(event, func(info controller.BindingExecutionInfo) {
 				if createTaskFn != nil {
@@ -325,10 +581,7 @@ func (hm *hookManager) HandleValidatingEvent(event ValidatingEvent, createTaskFn
 
 // HandleConversionEvent receives a crdName and calculates a sequence of hooks to run.
 func (hm *hookManager) HandleConversionEvent(event conversion.Event, rule conversion.Rule, createTaskFn func(*Hook, controller.BindingExecutionInfo)) {
-	vHooks, _ := hm.GetHooksInOrder(KubernetesConversion)
-
-	for _, hookName := range vHooks {
-		h := hm.GetHook(hookName)
+	for _, h := range hm.indexedHooks(KubernetesConversion) {
 		if h.HookController.CanHandleConversionEvent(event, rule) {
 			h.HookController.HandleConversionEvent(event, rule, func(info controller.BindingExecutionInfo) {
 				if createTaskFn != nil {
@@ -340,12 +593,14 @@ func (hm *hookManager) HandleConversionEvent(event conversion.Event, rule conver
 }
 
 func (hm *hookManager) UpdateConversionChains() error {
-	vHooks, _ := hm.GetHooksInOrder(KubernetesConversion)
+	hm.m.Lock()
+	defer hm.m.Unlock()
+	return hm.updateConversionChains()
+}
 
+func (hm *hookManager) updateConversionChains() error {
 	// Update conversionChains.
-	for _, hookName := range vHooks {
-		h := hm.GetHook(hookName)
-
+	for _, h := range hm.hooksInOrder[KubernetesConversion] {
 		for _, cfg := range h.Config.KubernetesConversion {
 			crdName := cfg.Webhook.CrdName
 			chain := hm.conversionChains.Get(crdName)
@@ -359,5 +614,7 @@ func (hm *hookManager) UpdateConversionChains() error {
 }
 
 func (hm *hookManager) FindConversionChain(crdName string, rule conversion.Rule) []conversion.Rule {
+	hm.m.RLock()
+	defer hm.m.RUnlock()
 	return hm.conversionChains.FindConversionChain(crdName, rule)
 }
@@ -1,15 +1,18 @@
 package hook
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/hashicorp/go-multierror"
 	. "github.com/onsi/gomega"
 
 	"github.com/flant/shell-operator/pkg/app"
 	"github.com/flant/shell-operator/pkg/hook/controller"
 	"github.com/flant/shell-operator/pkg/hook/types"
+	"github.com/flant/shell-operator/pkg/schedule_manager"
 	"github.com/flant/shell-operator/pkg/webhook/conversion"
 	"github.com/flant/shell-operator/pkg/webhook/validating"
 	. "github.com/flant/shell-operator/pkg/webhook/validating/types"
@@ -380,3 +383,153 @@ func Test_HookManager_onstartup_order(t *testing.T) {
 		g.Expect(hookName).To(Equal(expectNames[i]))
 	}
 }
+
+func Test_HookManager_Init_Parallel(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(parallelism int) {
+		app.HooksConfigLoadParallelism = parallelism
+	}(app.HooksConfigLoadParallelism)
+	app.HooksConfigLoadParallelism = 3
+
+	hooksDir := t.TempDir()
+	expectNames := []string{}
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("hook%02d.sh", i)
+		writeHook(t, hooksDir, name, `{"configVersion":"v1", "onStartup": 1}`)
+		expectNames = append(expectNames, name)
+	}
+
+	hm := newHookManager(t, hooksDir)
+	err := hm.Init()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(hm.GetHookNames()).To(Equal(expectNames))
+
+	// Errors for all broken hooks are reported.
+	writeHook(t, hooksDir, "hook03.sh", `{"configVersion":"v1", "schedule":[{"crontab":"bad crontab"}]}`)
+	writeHook(t, hooksDir, "hook07.sh", `{"configVersion":"v1", "onStartup": "bad"}`)
+
+	hm = newHookManager(t, hooksDir)
+	err = hm.Init()
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.(*multierror.Error).Errors).To(HaveLen(2))
+	g.Expect(err.Error()).To(ContainSubstring("hook03.sh"))
+	g.Expect(err.Error()).To(ContainSubstring("hook07.sh"))
+}
+
+func Test_HookManager_DependsOn(t *testing.T) {
+	g := NewWithT(t)
+
+	hooksDir := t.TempDir()
+	writeHook(t, hooksDir, "a.sh", `{"configVersion":"v1", "onStartup": 1, "settings":{"dependsOn":["c.sh"]}}`)
+	writeHook(t, hooksDir, "b.sh", `{"configVersion":"v1", "onStartup": 5, "kubernetes":[{"kind":"Pod"}]}`)
+	writeHook(t, hooksDir, "c.sh", `{"configVersion":"v1", "onStartup": 10, "kubernetes":[{"kind":"Pod"}], "settings":{"dependsOn":["d.sh"]}}`)
+	writeHook(t, hooksDir, "d.sh", `{"configVersion":"v1", "kubernetes":[{"kind":"Pod"}]}`)
+
+	hm := newHookManager(t, hooksDir)
+	err := hm.Init()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(hm.GetHookNames()).To(Equal([]string{"b.sh", "d.sh", "c.sh", "a.sh"}))
+
+	// dependsOn takes precedence over the onStartup order, the dependency on c.sh is kept through d.sh.
+	names, err := hm.GetHooksInOrder(types.OnStartup)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(names).To(Equal([]string{"b.sh", "c.sh", "a.sh"}))
+
+	names, err = hm.GetHooksInOrder(types.OnKubernetesEvent)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(names).To(Equal([]string{"b.sh", "d.sh", "c.sh"}))
+
+	// Unknown hook.
+	writeHook(t, hooksDir, "d.sh", `{"configVersion":"v1", "kubernetes":[{"kind":"Pod"}], "settings":{"dependsOn":["e.sh"]}}`)
+	hm = newHookManager(t, hooksDir)
+	err = hm.Init()
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).Should(ContainSubstring("hook 'd.sh' depends on unknown hook 'e.sh'"))
+
+	// Cycle.
+	writeHook(t, hooksDir, "d.sh", `{"configVersion":"v1", "kubernetes":[{"kind":"Pod"}], "settings":{"dependsOn":["a.sh"]}}`)
+	hm = newHookManager(t, hooksDir)
+	err = hm.Init()
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).Should(ContainSubstring("hooks have a dependency cycle: a.sh -> c.sh -> d.sh -> a.sh"))
+}
+
+func Test_HookManager_DependsOn_SkipInvalid(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(policy string) {
+		app.HooksLoadPolicy = policy
+	}(app.HooksLoadPolicy)
+	app.HooksLoadPolicy = app.HooksLoadPolicySkipInvalid
+
+	hooksDir := t.TempDir()
+	writeHook(t, hooksDir, "a.sh", `{"configVersion":"v1", "onStartup": 1, "settings":{"dependsOn":["b.sh"]}}`)
+	writeHook(t, hooksDir, "b.sh", `{"configVersion":"v1", "onStartup": "bad"}`)
+	writeHook(t, hooksDir, "c.sh", `{"configVersion":"v1", "onStartup": 1, "settings":{"dependsOn":["a.sh"]}}`)
+	writeHook(t, hooksDir, "d.sh", `{"configVersion":"v1", "onStartup": 1}`)
+
+	// Dependents of the quarantined hook are quarantined, directly or through other hooks.
+	hm := newHookManager(t, hooksDir)
+	err := hm.Init()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(hm.GetHookNames()).To(Equal([]string{"d.sh"}))
+	invalid := hm.GetInvalidHooks()
+	g.Expect(invalid).To(HaveLen(3))
+	g.Expect(invalid[0].Name).To(Equal("b.sh"))
+	g.Expect(invalid[1]).To(Equal(InvalidHook{Name: "a.sh", Error: "hook 'a.sh' depends on quarantined hook 'b.sh'"}))
+	g.Expect(invalid[2]).To(Equal(InvalidHook{Name: "c.sh", Error: "hook 'c.sh' depends on quarantined hook 'a.sh'"}))
+
+	// Dependents are loaded again when the hook is fixed.
+	writeHook(t, hooksDir, "b.sh", `{"configVersion":"v1", "onStartup": 1}`)
+	res, err := hm.Reload()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(res.Added).To(HaveLen(3))
+	g.Expect(hm.GetInvalidHooks()).To(BeEmpty())
+	g.Expect(hm.GetHookNames()).To(Equal([]string{"b.sh", "a.sh", "c.sh", "d.sh"}))
+
+	// A previous instance of the changed hook continues to run, so dependents are not quarantined.
+	writeHook(t, hooksDir, "b.sh", `{"configVersion":"v1", "onStartup": "bad"}`)
+	res, err = hm.Reload()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(res.Invalid).To(HaveLen(1))
+	g.Expect(hm.GetHookNames()).To(Equal([]string{"b.sh", "a.sh", "c.sh", "d.sh"}))
+}
+
+func Test_HookManager_DisableHook(t *testing.T) {
+	g := NewWithT(t)
+
+	hooksDir := t.TempDir()
+	writeHook(t, hooksDir, "a.sh", `{"configVersion":"v1", "schedule":[{"crontab":"* * * * *"}]}`)
+	writeHook(t, hooksDir, "b.sh", `{"configVersion":"v1", "schedule":[{"crontab":"* * * * *"}]}`)
+
+	hm := newHookManager(t, hooksDir)
+	hm.WithScheduleManager(schedule_manager.NewScheduleManager())
+	err := hm.Init()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	for _, name := range hm.GetHookNames() {
+		hm.GetHook(name).HookController.EnableScheduleBindings()
+	}
+
+	handled := func() []string {
+		res := make([]string, 0)
+		hm.HandleScheduleEvent("* * * * *", func(h *Hook, _ controller.BindingExecutionInfo) {
+			res = append(res, h.Name)
+		})
+		return res
+	}
+	g.Expect(handled()).To(Equal([]string{"a.sh", "b.sh"}))
+
+	err = hm.DisableHook("a.sh")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(hm.IsHookDisabled("a.sh")).To(BeTrue())
+	g.Expect(handled()).To(Equal([]string{"b.sh"}))
+
+	err = hm.DisableHook("unknown.sh")
+	g.Expect(err).Should(HaveOccurred())
+
+	err = hm.EnableHook("a.sh")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(hm.IsHookDisabled("a.sh")).To(BeFalse())
+	g.Expect(handled()).To(Equal([]string{"a.sh", "b.sh"}))
+}
@@ -1,14 +1,20 @@
 package hook
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/flant/shell-operator/pkg/app"
+	"github.com/flant/shell-operator/pkg/executor"
 	"github.com/flant/shell-operator/pkg/hook/config"
+	"github.com/flant/shell-operator/pkg/hook/controller"
 	. "github.com/onsi/gomega"
 	"golang.org/x/time/rate"
 
+	. "github.com/flant/shell-operator/pkg/hook/binding_context"
 	. "github.com/flant/shell-operator/pkg/hook/types"
 )
 
@@ -99,6 +105,33 @@ func Test_CreateLimiter(t *testing.T) {
 	}
 }
 
+func Test_Hook_BindingRateLimiters(t *testing.T) {
+	g := NewWithT(t)
+
+	h := NewHook("hook.sh", "/hooks/hook.sh")
+	_, err := h.LoadConfig([]byte(`
+configVersion: v2
+settings:
+  executionMinInterval: 30s
+  executionBurst: 2
+schedule:
+- name: default
+  crontab: "* * * * *"
+- name: fast
+  crontab: "* * * * *"
+  settings:
+    executionMinInterval: 1s
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	g.Expect(h.bindingRateLimiters).To(HaveLen(1))
+	l := h.bindingRateLimiters[bindingRateLimiterKey(Schedule, "fast")]
+	g.Expect(l.Limit()).To(Equal(rate.Limit(1)))
+	// Burst is inherited from the hook settings.
+	g.Expect(l.Burst()).To(Equal(2))
+	g.Expect(h.RateLimiter.Limit()).To(Equal(rate.Limit(1.0 / 30)))
+}
+
 func Test_Hook_WithConfig(t *testing.T) {
 	g := NewWithT(t)
 
@@ -141,3 +174,280 @@ func Test_Hook_WithConfig(t *testing.T) {
 		})
 	}
 }
+
+func Test_Hook_ExecutionTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	h := NewHook("hook.sh", "/hooks/hook.sh")
+	_, err := h.LoadConfig([]byte(`
+configVersion: v1
+settings:
+  executionTimeout: 1m
+schedule:
+- name: fast
+  crontab: "* * * * *"
+  executionTimeout: 10s
+- name: slow
+  crontab: "* * * * *"
+  executionTimeout: 5m
+- name: default
+  crontab: "* * * * *"
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	bc := func(name string) BindingContext {
+		res := BindingContext{Binding: name}
+		res.Metadata.BindingType = Schedule
+		return res
+	}
+
+	g.Expect(h.ExecutionTimeout([]BindingContext{bc("fast")})).To(Equal(10 * time.Second))
+	g.Expect(h.ExecutionTimeout([]BindingContext{bc("default")})).To(Equal(time.Minute))
+	g.Expect(h.ExecutionTimeout([]BindingContext{bc("fast"), bc("slow")})).To(Equal(5 * time.Minute))
+	g.Expect(h.ExecutionTimeout([]BindingContext{bc("fast"), bc("default")})).To(Equal(time.Minute))
+}
+
+func Test_Hook_Run_StdinDelivery(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	hookPath := filepath.Join(tmpDir, "hook.sh")
+	err := ioutil.WriteFile(hookPath, []byte(`#!/bin/sh
+binding=$(grep -o '"binding": *"[^"]*"' | sed -e 's/.*"\([^"]*\)"$/\1/')
+echo '{"channel":"metrics","data":{"name":"hook_binding","set":1,"labels":{"binding":"'$binding'"}}}' >&3
+echo '{"channel":"validatingResponse","data":{"allowed":true}}' >&3
+`), 0755)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	h := NewHook("hook.sh", hookPath)
+	_, err = h.LoadConfig([]byte(`
+configVersion: v1
+onStartup: 1
+settings:
+  bindingContextDelivery: stdin
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	h.WithHookController(controller.NewHookController())
+	h.WithTmpDir(tmpDir)
+
+	bc := BindingContext{Binding: "onStartup"}
+	bc.Metadata.BindingType = OnStartup
+
+	res, err := h.Run(OnStartup, []BindingContext{bc}, map[string]string{}, TaskInfo{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(res.Metrics).To(HaveLen(1))
+	g.Expect(res.Metrics[0].Labels).To(HaveKeyWithValue("binding", "onStartup"))
+	g.Expect(res.ValidatingResponse).ToNot(BeNil())
+	g.Expect(res.ValidatingResponse.Allowed).To(BeTrue())
+	g.Expect(res.KubernetesPatchBytes).To(BeEmpty())
+}
+
+func Test_Hook_Run_LastRun(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	hookPath := filepath.Join(tmpDir, "hook.sh")
+	err := ioutil.WriteFile(hookPath, []byte(`#!/bin/sh
+echo "checking pods"
+echo "pods are not ready" >&2
+exit 3
+`), 0755)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	h := NewHook("hook.sh", hookPath)
+	_, err = h.LoadConfig([]byte(`
+configVersion: v1
+onStartup: 1
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	h.WithHookController(controller.NewHookController())
+	h.WithTmpDir(tmpDir)
+
+	g.Expect(h.LastRun()).To(BeNil())
+
+	bc := BindingContext{Binding: "onStartup"}
+	bc.Metadata.BindingType = OnStartup
+
+	_, err = h.Run(OnStartup, []BindingContext{bc}, map[string]string{}, TaskInfo{})
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).To(Equal("hook.sh FAILED: exit status 3"))
+	g.Expect(FailureMessage(err)).To(Equal("hook.sh FAILED: exit status 3\nstdout:\nchecking pods\nstderr:\npods are not ready"))
+
+	lastRun := h.LastRun()
+	g.Expect(lastRun).ToNot(BeNil())
+	g.Expect(lastRun.ExitCode).To(Equal(3))
+	g.Expect(lastRun.BindingType).To(Equal(OnStartup))
+	g.Expect(lastRun.Bindings).To(Equal([]string{"onStartup"}))
+	g.Expect(lastRun.Stdout).To(Equal("checking pods\n"))
+	g.Expect(lastRun.Stderr).To(Equal("pods are not ready\n"))
+	g.Expect(lastRun.Error).To(Equal("hook.sh FAILED: exit status 3"))
+}
+
+func Test_Hook_Run_EnvWorkingDirArgs(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	g.Expect(os.Mkdir(filepath.Join(tmpDir, "work"), 0755)).Should(Succeed())
+	g.Expect(ioutil.WriteFile(filepath.Join(tmpDir, "token"), []byte("secret\n"), 0644)).Should(Succeed())
+
+	hookPath := filepath.Join(tmpDir, "hook.sh")
+	err := ioutil.WriteFile(hookPath, []byte(`#!/bin/sh
+echo "$(basename $(pwd)) $1 $GREETING $TOKEN $FROM_ENV $HOOK_NAME $HOOK_BINDING $HOOK_BINDING_TYPE $HOOK_QUEUE $HOOK_TASK_ID $HOOK_FAILURE_COUNT" >&2
+`), 0755)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	os.Setenv("TEST_HOOK_ENV_SOURCE", "from-operator")
+	defer os.Unsetenv("TEST_HOOK_ENV_SOURCE")
+
+	h := NewHook("hook.sh", hookPath)
+	_, err = h.LoadConfig([]byte(`
+configVersion: v1
+schedule:
+- name: every-minute
+  crontab: "* * * * *"
+settings:
+  env:
+    GREETING: hello
+    TOKEN:
+      valueFrom:
+        file: token
+    FROM_ENV:
+      valueFrom:
+        env: TEST_HOOK_ENV_SOURCE
+  workingDir: work
+  args: ["--verbose"]
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	h.WithHookController(controller.NewHookController())
+	h.WithTmpDir(tmpDir)
+
+	bc := BindingContext{Binding: "every-minute"}
+	bc.Metadata.BindingType = Schedule
+
+	_, err = h.Run(Schedule, []BindingContext{bc, bc}, map[string]string{}, TaskInfo{
+		Queue:        "main",
+		TaskId:       "task-1",
+		FailureCount: 2,
+	})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(h.LastRun().Stderr).To(Equal("work --verbose hello secret from-operator hook.sh every-minute schedule main task-1 2\n"))
+}
+
+func Test_Hook_Run_RunAs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("root is required to change credentials")
+	}
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	// The hook's user should be able to reach the hook.
+	g.Expect(os.Chmod(filepath.Dir(tmpDir), 0755)).Should(Succeed())
+	g.Expect(os.Chmod(tmpDir, 0755)).Should(Succeed())
+	hookPath := filepath.Join(tmpDir, "hook.sh")
+	err := ioutil.WriteFile(hookPath, []byte(`#!/bin/sh
+cat $BINDING_CONTEXT_PATH > /dev/null || exit 1
+echo '{"name":"hook_uid","set":'$(id -u)'}' > $METRICS_PATH
+`), 0755)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	h := NewHook("hook.sh", hookPath)
+	_, err = h.LoadConfig([]byte(`
+configVersion: v1
+onStartup: 1
+settings:
+  runAs:
+    uid: 65534
+  dropPrivileges: true
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	h.WithHookController(controller.NewHookController())
+	h.WithTmpDir(tmpDir)
+
+	bc := BindingContext{Binding: "onStartup"}
+	bc.Metadata.BindingType = OnStartup
+
+	res, err := h.Run(OnStartup, []BindingContext{bc}, map[string]string{}, TaskInfo{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(res.Metrics).To(HaveLen(1))
+	g.Expect(*res.Metrics[0].Value).To(Equal(65534.0))
+}
+
+func Test_Hook_Run_ResponseSizeLimit(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(size int) {
+		app.HookMaxMetricsSize = size
+	}(app.HookMaxMetricsSize)
+	app.HookMaxMetricsSize = 100
+
+	tmpDir := t.TempDir()
+	hookPath := filepath.Join(tmpDir, "hook.sh")
+	err := ioutil.WriteFile(hookPath, []byte(`#!/bin/sh
+for i in 1 2 3 4 5; do
+  echo '{"name":"hook_metric","set":1}' >> $METRICS_PATH
+done
+`), 0755)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	h := NewHook("hook.sh", hookPath)
+	_, err = h.LoadConfig([]byte(`
+configVersion: v1
+onStartup: 1
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	h.WithHookController(controller.NewHookController())
+	h.WithTmpDir(tmpDir)
+
+	bc := BindingContext{Binding: "onStartup"}
+	bc.Metadata.BindingType = OnStartup
+
+	_, err = h.Run(OnStartup, []BindingContext{bc}, map[string]string{}, TaskInfo{})
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(executor.IsOutputLimit(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("metrics exceeds the limit of 100 bytes"))
+}
+
+func Test_Hook_RunWithBindingContextList(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	hookPath := filepath.Join(tmpDir, "hook.sh")
+	err := ioutil.WriteFile(hookPath, []byte(`#!/bin/sh
+objects=$(grep -c '"name": "pod-1"' $BINDING_CONTEXT_PATH)
+echo '{"name":"hook_run","set":'$objects',"labels":{"binding":"'$HOOK_BINDING'","type":"'$HOOK_BINDING_TYPE'"}}' > $METRICS_PATH
+echo '{"operation":"Delete","kind":"Pod","namespace":"default","name":"pod-1"}' > $KUBERNETES_PATCH_PATH
+`), 0755)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	h := NewHook("hook.sh", hookPath)
+	_, err = h.LoadConfig([]byte(`
+configVersion: v1
+kubernetes:
+- name: pods
+  kind: Pod
+`))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	h.WithTmpDir(tmpDir)
+
+	// Recorded binding context is passed as is, no HookController is required.
+	contextList := BindingContextList{
+		{
+			"binding": "pods",
+			"type":    "Synchronization",
+			"objects": []interface{}{
+				map[string]interface{}{"object": map[string]interface{}{"metadata": map[string]interface{}{"name": "pod-1"}}},
+			},
+		},
+	}
+
+	res, err := h.RunWithBindingContextList(contextList, map[string]string{}, TaskInfo{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(res.Metrics).To(HaveLen(1))
+	g.Expect(*res.Metrics[0].Set).To(Equal(1.0))
+	g.Expect(res.Metrics[0].Labels).To(HaveKeyWithValue("binding", "pods"))
+	g.Expect(res.Metrics[0].Labels).To(HaveKeyWithValue("type", "kubernetes"))
+	g.Expect(string(res.KubernetesPatchBytes)).To(ContainSubstring("Delete"))
+
+	_, err = h.RunWithBindingContextList(BindingContextList{{"binding": "unknown"}}, map[string]string{}, TaskInfo{})
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).Should(ContainSubstring("hook has no binding 'unknown'"))
+}
@@ -0,0 +1,139 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/flant/shell-operator/pkg/hook/binding_context"
+	. "github.com/flant/shell-operator/pkg/hook/types"
+
+	"github.com/flant/shell-operator/pkg/app"
+	"github.com/flant/shell-operator/pkg/executor"
+)
+
+// LastRun describes the last execution of the hook.
+type LastRun struct {
+	StartedAt   time.Time   `json:"startedAt"`
+	Duration    string      `json:"duration"`
+	BindingType BindingType `json:"bindingType"`
+	Bindings    []string    `json:"bindings"`
+	ExitCode    int         `json:"exitCode"`
+	Error       string      `json:"error,omitempty"`
+	UserCPU     string      `json:"userCpu,omitempty"`
+	SysCPU      string      `json:"sysCpu,omitempty"`
+	MaxRssBytes int64       `json:"maxRssBytes,omitempty"`
+	Stdout      string      `json:"stdout"`
+	Stderr      string      `json:"stderr"`
+}
+
+func (r *LastRun) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Started at: %s\n", r.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Duration: %s\n", r.Duration)
+	fmt.Fprintf(&b, "Binding: %s %s\n", r.BindingType, strings.Join(r.Bindings, ", "))
+	fmt.Fprintf(&b, "Exit code: %d\n", r.ExitCode)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\n", r.Error)
+	}
+	fmt.Fprintf(&b, "Usage: user %s, sys %s, max rss %d bytes\n", r.UserCPU, r.SysCPU, r.MaxRssBytes)
+	fmt.Fprintf(&b, "Stdout:\n%s\n", r.Stdout)
+	fmt.Fprintf(&b, "Stderr:\n%s\n", r.Stderr)
+	return b.String()
+}
+
+// runOutput keeps tails of stdout and stderr of the hook process.
+type runOutput struct {
+	Stdout *executor.TailBuffer
+	Stderr *executor.TailBuffer
+}
+
+func newRunOutput() *runOutput {
+	return &runOutput{
+		Stdout: executor.NewTailBuffer(app.HookOutputTailSize),
+		Stderr: executor.NewTailBuffer(app.HookOutputTailSize),
+	}
+}
+
+func tailString(b *executor.TailBuffer) string {
+	if b.Truncated() {
+		return "..." + b.String()
+	}
+	return b.String()
+}
+
+// RunError is returned when the hook fails. It holds tails of the hook's output.
+type RunError struct {
+	Err    error
+	Stdout string
+	Stderr string
+}
+
+func (e *RunError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// FailureMessage returns an error message with tails of the hook's output if available.
+func FailureMessage(err error) string {
+	var runErr *RunError
+	if !errors.As(err, &runErr) {
+		return err.Error()
+	}
+	msg := err.Error()
+	if runErr.Stdout != "" {
+		msg += "\nstdout:\n" + strings.TrimRight(runErr.Stdout, "\n")
+	}
+	if runErr.Stderr != "" {
+		msg += "\nstderr:\n" + strings.TrimRight(runErr.Stderr, "\n")
+	}
+	return msg
+}
+
+// lastRunStore keeps the last execution of the hook.
+type lastRunStore struct {
+	mu      sync.Mutex
+	lastRun *LastRun
+}
+
+func (s *lastRunStore) set(r *LastRun) {
+	s.mu.Lock()
+	s.lastRun = r
+	s.mu.Unlock()
+}
+
+func (s *lastRunStore) get() *LastRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastRun
+}
+
+func newLastRun(startedAt time.Time, bindingType BindingType, context []BindingContext, result *HookResult, output *runOutput, err error) *LastRun {
+	r := &LastRun{
+		StartedAt:   startedAt,
+		Duration:    time.Since(startedAt).String(),
+		BindingType: bindingType,
+		Bindings:    make([]string, 0, len(context)),
+		Stdout:      tailString(output.Stdout),
+		Stderr:      tailString(output.Stderr),
+	}
+	for _, bc := range context {
+		r.Bindings = append(r.Bindings, bc.Binding)
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if result != nil && result.Usage != nil {
+		r.ExitCode = result.Usage.ExitCode
+		r.UserCPU = result.Usage.User.String()
+		r.SysCPU = result.Usage.Sys.String()
+		// Maxrss is in kilobytes.
+		r.MaxRssBytes = result.Usage.MaxRss * 1024
+	}
+	return r
+}
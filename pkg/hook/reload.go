@@ -0,0 +1,206 @@
+package hook
+
+import (
+	"fmt"
+	"path/filepath"
+
+	. "github.com/flant/shell-operator/pkg/hook/types"
+)
+
+// HooksReload describes changes in the hooks directory found by the Reload method.
+type HooksReload struct {
+	// Added are hooks with new executables.
+	Added []*Hook
+	// Removed are hooks with deleted executables.
+	Removed []*Hook
+	// Changed are hooks with modified executables.
+	Changed []*HookChange
+	// Invalid are hooks with invalid configurations quarantined by
+	// the "skip-invalid" load policy. Previous instances of such hooks
+	// continue to run.
+	Invalid []InvalidHook
+}
+
+// InvalidHook is a hook with an invalid configuration.
+type InvalidHook struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// HookChange describes a hook with a modified executable.
+type HookChange struct {
+	Old *Hook
+	New *Hook
+	// ChangedBindings are binding types with a new configuration.
+	// Bindings of other types are inherited from the Old hook.
+	ChangedBindings []BindingType
+}
+
+func (r *HooksReload) IsEmpty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+func (r *HooksReload) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed, %d invalid", len(r.Added), len(r.Removed), len(r.Changed), len(r.Invalid))
+}
+
+// Reload searches for added, removed and changed executables in WorkingDir and
+// loads configurations of new and changed hooks. Indices are updated only if all
+// configurations are loaded successfully. With the "skip-invalid" load policy,
+// invalid hooks are quarantined and loaded again on the next reload.
+//
+// Controllers of unchanged bindings are inherited by new instances of changed hooks.
+// The caller is responsible for disabling bindings of removed hooks and changed
+// bindings of old instances, and for enabling bindings of new hooks and changed
+// bindings of new instances.
+func (hm *hookManager) Reload() (*HooksReload, error) {
+	hooksRelativePaths, err := hm.searchHooks()
+	if err != nil {
+		return nil, err
+	}
+
+	hm.m.RLock()
+	oldNames := hm.hookNamesInOrder
+	oldHooks := hm.hooksByName
+	oldChecksums := hm.checksums
+	hm.m.RUnlock()
+
+	hookNames := make([]string, 0, len(hooksRelativePaths))
+	checksums := make(map[string]string)
+	// Paths of new and changed hooks.
+	loadPaths := make([]string, 0)
+
+	for _, hookPath := range hooksRelativePaths {
+		hookName, err := filepath.Rel(hm.workingDir, hookPath)
+		if err != nil {
+			return nil, err
+		}
+		hookNames = append(hookNames, hookName)
+
+		sum, err := hookChecksum(hookPath)
+		if err != nil {
+			return nil, err
+		}
+		checksums[hookName] = sum
+
+		if _, exists := oldHooks[hookName]; exists && oldChecksums[hookName] == sum {
+			continue
+		}
+		loadPaths = append(loadPaths, hookPath)
+	}
+
+	loaded, invalidHooks, err := hm.loadHooks(loadPaths)
+	if err != nil {
+		return nil, err
+	}
+	// Previous instances of changed hooks continue to run if new instances are quarantined.
+	running := make(map[string]bool)
+	for _, hookName := range hookNames {
+		if _, exists := oldHooks[hookName]; exists {
+			running[hookName] = true
+		}
+	}
+	loaded, invalidHooks = quarantineDependents(loaded, invalidHooks, running)
+	loadedByName := make(map[string]*Hook)
+	for _, hook := range loaded {
+		loadedByName[hook.Name] = hook
+	}
+
+	res := &HooksReload{Invalid: invalidHooks}
+	hooks := make([]*Hook, 0, len(hookNames))
+	for _, hookName := range hookNames {
+		oldHook, exists := oldHooks[hookName]
+		hook, isLoaded := loadedByName[hookName]
+		if !isLoaded {
+			if exists {
+				// Keep the previous instance of unchanged or invalid hook. The previous
+				// checksum is kept to load the invalid hook again on the next reload.
+				hooks = append(hooks, oldHook)
+				checksums[hookName] = oldChecksums[hookName]
+			}
+			continue
+		}
+		hooks = append(hooks, hook)
+
+		if !exists {
+			res.Added = append(res.Added, hook)
+			continue
+		}
+
+		changed := oldHook.Config.ChangedBindings(hook.Config)
+		hook.inheritBindings(oldHook, unchangedBindings(changed))
+		res.Changed = append(res.Changed, &HookChange{
+			Old:             oldHook,
+			New:             hook,
+			ChangedBindings: changed,
+		})
+	}
+
+	for _, hookName := range oldNames {
+		if _, has := checksums[hookName]; !has {
+			res.Removed = append(res.Removed, oldHooks[hookName])
+		}
+	}
+
+	if res.IsEmpty() {
+		hm.setInvalidHooks(invalidHooks)
+		return res, nil
+	}
+
+	err = hm.updateIndices(hooks, checksums)
+	if err != nil {
+		stopWorkers(loaded)
+		return nil, err
+	}
+	hm.setInvalidHooks(invalidHooks)
+
+	// Enable added hooks after their dependencies.
+	hm.m.RLock()
+	res.Added = orderByDependencies(res.Added, hm.hooksByName)
+	hm.m.RUnlock()
+
+	return res, nil
+}
+
+// inheritBindings takes configurations and binding controllers for bindingTypes
+// from the previous instance of the hook.
+func (h *Hook) inheritBindings(prev *Hook, bindingTypes []BindingType) {
+	for _, bindingType := range bindingTypes {
+		switch bindingType {
+		case OnStartup:
+			h.Config.OnStartup = prev.Config.OnStartup
+		case Schedule:
+			h.Config.Schedules = prev.Config.Schedules
+		case OnKubernetesEvent:
+			h.Config.OnKubernetesEvents = prev.Config.OnKubernetesEvents
+		case KubernetesValidating:
+			h.Config.KubernetesValidating = prev.Config.KubernetesValidating
+		case KubernetesConversion:
+			h.Config.KubernetesConversion = prev.Config.KubernetesConversion
+		}
+	}
+	h.HookController.InheritBindings(prev.HookController, bindingTypes)
+}
+
+func unchangedBindings(changed []BindingType) []BindingType {
+	res := []BindingType{}
+	for _, bindingType := range []BindingType{OnStartup, Schedule, OnKubernetesEvent, KubernetesValidating, KubernetesConversion} {
+		isChanged := false
+		for _, changedType := range changed {
+			if changedType == bindingType {
+				isChanged = true
+				break
+			}
+		}
+		if !isChanged {
+			res = append(res, bindingType)
+		}
+	}
+	return res
+}
+
+func stopWorkers(hooks []*Hook) {
+	for _, hook := range hooks {
+		hook.StopWorker()
+	}
+}
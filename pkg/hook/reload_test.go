@@ -0,0 +1,124 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/shell-operator/pkg/app"
+	. "github.com/flant/shell-operator/pkg/hook/types"
+)
+
+func writeHook(t *testing.T, dir string, name string, config string) {
+	script := "#!/bin/bash\nif [[ $1 == \"--config\" ]]; then\ncat <<EOF\n" + config + "\nEOF\nfi\n"
+	err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755)
+	if err != nil {
+		t.Fatalf("write hook %s: %v", name, err)
+	}
+}
+
+func Test_HookManager_Reload(t *testing.T) {
+	g := NewWithT(t)
+
+	hooksDir := t.TempDir()
+	writeHook(t, hooksDir, "a.sh", `{"configVersion":"v1", "schedule":[{"crontab":"* * * * *"}], "kubernetes":[{"kind":"Pod"}]}`)
+	writeHook(t, hooksDir, "b.sh", `{"configVersion":"v1", "onStartup": 1}`)
+
+	hm := newHookManager(t, hooksDir)
+	err := hm.Init()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(hm.GetHookNames()).To(Equal([]string{"a.sh", "b.sh"}))
+
+	// No changes.
+	reload, err := hm.Reload()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(reload.IsEmpty()).To(BeTrue())
+
+	oldA := hm.GetHook("a.sh")
+	oldB := hm.GetHook("b.sh")
+
+	writeHook(t, hooksDir, "a.sh", `{"configVersion":"v1", "schedule":[{"crontab":"*/5 * * * *"}], "kubernetes":[{"kind":"Pod"}]}`)
+	g.Expect(os.Remove(filepath.Join(hooksDir, "b.sh"))).To(Succeed())
+	writeHook(t, hooksDir, "c.sh", `{"configVersion":"v1", "schedule":[{"crontab":"* * * * *"}]}`)
+
+	reload, err = hm.Reload()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(reload.String()).To(Equal("1 added, 1 removed, 1 changed, 0 invalid"))
+	g.Expect(reload.Added[0].Name).To(Equal("c.sh"))
+	g.Expect(reload.Removed[0]).To(BeIdenticalTo(oldB))
+	g.Expect(reload.Changed[0].Old).To(BeIdenticalTo(oldA))
+	g.Expect(reload.Changed[0].ChangedBindings).To(Equal([]BindingType{Schedule}))
+
+	newA := hm.GetHook("a.sh")
+	g.Expect(newA).To(BeIdenticalTo(reload.Changed[0].New))
+	g.Expect(newA.Config.Schedules[0].ScheduleEntry.Crontab).To(Equal("*/5 * * * *"))
+	// Unchanged kubernetes binding is inherited with its monitor.
+	g.Expect(newA.Config.OnKubernetesEvents[0].Monitor.Metadata.MonitorId).To(Equal(oldA.Config.OnKubernetesEvents[0].Monitor.Metadata.MonitorId))
+
+	g.Expect(hm.GetHookNames()).To(Equal([]string{"a.sh", "c.sh"}))
+	g.Expect(hm.LookupHook("b.sh")).To(BeNil())
+	onStartupHooks, _ := hm.GetHooksInOrder(OnStartup)
+	g.Expect(onStartupHooks).To(BeEmpty())
+	scheduleHooks, _ := hm.GetHooksInOrder(Schedule)
+	g.Expect(scheduleHooks).To(Equal([]string{"a.sh", "c.sh"}))
+
+	// Hooks are not changed if config of some hook is invalid.
+	writeHook(t, hooksDir, "d.sh", `{"configVersion":"v1", "schedule":[{"crontab":"bad crontab"}]}`)
+	g.Expect(os.Remove(filepath.Join(hooksDir, "c.sh"))).To(Succeed())
+
+	_, err = hm.Reload()
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(hm.GetHookNames()).To(Equal([]string{"a.sh", "c.sh"}))
+}
+
+func Test_HookManager_SkipInvalid(t *testing.T) {
+	g := NewWithT(t)
+
+	defer func(policy string) {
+		app.HooksLoadPolicy = policy
+	}(app.HooksLoadPolicy)
+	app.HooksLoadPolicy = app.HooksLoadPolicySkipInvalid
+
+	hooksDir := t.TempDir()
+	writeHook(t, hooksDir, "a.sh", `{"configVersion":"v1", "onStartup": 1}`)
+	writeHook(t, hooksDir, "b.sh", `{"configVersion":"v1", "schedule":[{"crontab":"bad crontab"}]}`)
+
+	hm := newHookManager(t, hooksDir)
+	err := hm.Init()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(hm.GetHookNames()).To(Equal([]string{"a.sh"}))
+	g.Expect(hm.GetInvalidHooks()).To(HaveLen(1))
+	g.Expect(hm.GetInvalidHooks()[0].Name).To(Equal("b.sh"))
+	g.Expect(hm.GetInvalidHooks()[0].Error).To(ContainSubstring("crontab"))
+
+	// Fixed hook is added on reload.
+	writeHook(t, hooksDir, "b.sh", `{"configVersion":"v1", "schedule":[{"crontab":"* * * * *"}]}`)
+	reload, err := hm.Reload()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(reload.String()).To(Equal("1 added, 0 removed, 0 changed, 0 invalid"))
+	g.Expect(hm.GetHookNames()).To(Equal([]string{"a.sh", "b.sh"}))
+	g.Expect(hm.GetInvalidHooks()).To(BeEmpty())
+
+	// Previous instance of the broken hook continues to run.
+	oldA := hm.GetHook("a.sh")
+	writeHook(t, hooksDir, "a.sh", `{"configVersion":"v1", "onStartup": "bad"}`)
+	reload, err = hm.Reload()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(reload.IsEmpty()).To(BeTrue())
+	g.Expect(reload.Invalid).To(HaveLen(1))
+	g.Expect(hm.GetHook("a.sh")).To(BeIdenticalTo(oldA))
+	g.Expect(hm.GetInvalidHooks()[0].Name).To(Equal("a.sh"))
+
+	// Invalid hook is loaded again on the next reload.
+	reload, err = hm.Reload()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(reload.Invalid).To(HaveLen(1))
+
+	writeHook(t, hooksDir, "a.sh", `{"configVersion":"v1", "onStartup": 2}`)
+	reload, err = hm.Reload()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(reload.Changed).To(HaveLen(1))
+	g.Expect(hm.GetInvalidHooks()).To(BeEmpty())
+}
@@ -0,0 +1,164 @@
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	. "github.com/flant/shell-operator/pkg/webhook/validating/types"
+
+	"github.com/flant/shell-operator/pkg/app"
+	"github.com/flant/shell-operator/pkg/executor"
+	"github.com/flant/shell-operator/pkg/metric_storage/operation"
+	"github.com/flant/shell-operator/pkg/webhook/conversion"
+)
+
+// Channels for tagged JSON lines written to the response file descriptor.
+const (
+	ResponseChannelMetrics         = "metrics"
+	ResponseChannelKubernetesPatch = "kubernetesPatch"
+	ResponseChannelValidating      = "validatingResponse"
+	ResponseChannelConversion      = "conversionResponse"
+)
+
+// ResponseLine is a tagged JSON line written by the hook to the response file descriptor:
+//
+//	{"channel":"metrics", "data":{"name":"my_metric", "set":1}}
+type ResponseLine struct {
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// hookResponse is a raw content of hook response channels.
+// It is read from files or from the response file descriptor.
+type hookResponse struct {
+	Metrics         []byte
+	Validating      []byte
+	Conversion      []byte
+	KubernetesPatch []byte
+}
+
+// hookResponseFromLines splits tagged JSON lines into response channels.
+// Lines for metrics and Kubernetes patches are accumulated, the last line is used for
+// validating and conversion responses.
+func hookResponseFromLines(data []byte) (*hookResponse, error) {
+	res := &hookResponse{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		lineBytes := bytes.TrimSpace(scanner.Bytes())
+		if len(lineBytes) == 0 {
+			continue
+		}
+
+		var line ResponseLine
+		err := json.Unmarshal(lineBytes, &line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		if len(line.Data) == 0 {
+			return nil, fmt.Errorf("line %d: 'data' field is required", lineNum)
+		}
+
+		switch line.Channel {
+		case ResponseChannelMetrics:
+			res.Metrics = append(append(res.Metrics, line.Data...), '\n')
+		case ResponseChannelKubernetesPatch:
+			res.KubernetesPatch = append(append(res.KubernetesPatch, line.Data...), '\n')
+		case ResponseChannelValidating:
+			res.Validating = line.Data
+		case ResponseChannelConversion:
+			res.Conversion = line.Data
+		default:
+			return nil, fmt.Errorf("line %d: unknown channel '%s'", lineNum, line.Channel)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// readResponseFile reads the response file if its size is within the limit.
+func readResponseFile(filePath string, channel string, limit int) ([]byte, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Read one byte more to detect files that grow while reading.
+	data, err := ioutil.ReadAll(io.LimitReader(f, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, &executor.OutputLimitError{Output: channel, Limit: limit}
+	}
+	return data, nil
+}
+
+// maxResponseSize is a limit for all channels written to the response file descriptor.
+func maxResponseSize() int {
+	return app.HookMaxMetricsSize + app.HookMaxKubernetesPatchSize + app.HookMaxValidatingResponseSize + app.HookMaxConversionResponseSize
+}
+
+// checkSizes returns an error if one of the channels exceeds its limit.
+func (r *hookResponse) checkSizes() error {
+	for _, channel := range []struct {
+		name  string
+		data  []byte
+		limit int
+	}{
+		{ResponseChannelMetrics, r.Metrics, app.HookMaxMetricsSize},
+		{ResponseChannelKubernetesPatch, r.KubernetesPatch, app.HookMaxKubernetesPatchSize},
+		{ResponseChannelValidating, r.Validating, app.HookMaxValidatingResponseSize},
+		{ResponseChannelConversion, r.Conversion, app.HookMaxConversionResponseSize},
+	} {
+		if len(channel.data) > channel.limit {
+			return &executor.OutputLimitError{Output: channel.name, Limit: channel.limit}
+		}
+	}
+	return nil
+}
+
+// fillResult parses response channels into the HookResult.
+func (r *hookResponse) fillResult(result *HookResult) (err error) {
+	err = r.checkSizes()
+	if err != nil {
+		return err
+	}
+
+	if len(r.Metrics) > 0 {
+		result.Metrics, err = operation.MetricOperationsFromBytes(r.Metrics)
+		if err != nil {
+			return fmt.Errorf("got bad metrics: %s", err)
+		}
+	}
+
+	if len(r.Validating) > 0 {
+		result.ValidatingResponse, err = ValidatingResponseFromBytes(r.Validating)
+		if err != nil {
+			return fmt.Errorf("got bad validating response: %s", err)
+		}
+	}
+
+	if len(r.Conversion) > 0 {
+		result.ConversionResponse, err = conversion.ResponseFromBytes(r.Conversion)
+		if err != nil {
+			return fmt.Errorf("got bad conversion response: %s", err)
+		}
+	}
+
+	result.KubernetesPatchBytes = r.KubernetesPatch
+
+	return nil
+}
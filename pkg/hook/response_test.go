@@ -0,0 +1,36 @@
+package hook
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_HookResponseFromLines(t *testing.T) {
+	g := NewWithT(t)
+
+	data := []byte(`
+{"channel":"metrics","data":{"name":"metric_one","add":1}}
+{"channel":"kubernetesPatch","data":{"operation":"Delete","kind":"Pod","name":"pod-1"}}
+{"channel":"metrics","data":{"name":"metric_two","set":2}}
+{"channel":"conversionResponse","data":{"convertedObjects":[]}}
+`)
+
+	response, err := hookResponseFromLines(data)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	result := &HookResult{}
+	err = response.fillResult(result)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(result.Metrics).To(HaveLen(2))
+	g.Expect(result.Metrics[1].Action).To(Equal("set"))
+	g.Expect(result.KubernetesPatchBytes).ToNot(BeEmpty())
+	g.Expect(result.ConversionResponse).ToNot(BeNil())
+	g.Expect(result.ValidatingResponse).To(BeNil())
+
+	_, err = hookResponseFromLines([]byte(`{"channel":"unknown","data":{}}`))
+	g.Expect(err).Should(HaveOccurred())
+
+	_, err = hookResponseFromLines([]byte(`not a json`))
+	g.Expect(err).Should(HaveOccurred())
+}
@@ -0,0 +1,198 @@
+package hook
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/flant/shell-operator/pkg/utils/checksum"
+	utils_file "github.com/flant/shell-operator/pkg/utils/file"
+)
+
+// StaticConfigExtensions are extensions of sidecar config files:
+// a config for 'hooks/pods.sh' is 'hooks/pods.sh.yaml' or 'hooks/pods.sh.json'.
+var StaticConfigExtensions = utils_file.StaticConfigExtensions
+
+// FrontMatterCommentPrefixes are prefixes of comment lines with a front-matter config.
+var FrontMatterCommentPrefixes = []string{"#", "//"}
+
+// frontMatterMaxLineSize limits a line length to not read a whole binary file.
+const frontMatterMaxLineSize = 64 * 1024
+
+// LoadStaticConfig returns a hook config from a sidecar file or from a front-matter
+// block in the hook file and a description of its source. nil config is returned
+// if the hook has no static config and should be executed with --config flag.
+//
+// An error is returned if several sidecar files are found or if both the sidecar
+// file and the front-matter are present and have different configs.
+func LoadStaticConfig(hookPath string) ([]byte, string, error) {
+	sidecarPath, err := findSidecarConfig(hookPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	frontMatter, err := readFrontMatter(hookPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("read front-matter config from '%s': %v", hookPath, err)
+	}
+
+	if sidecarPath == "" {
+		if frontMatter == nil {
+			return nil, "", nil
+		}
+		return frontMatter, fmt.Sprintf("front-matter in '%s'", hookPath), nil
+	}
+
+	sidecar, err := os.ReadFile(sidecarPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("read config file: %v", err)
+	}
+
+	if frontMatter != nil {
+		equal, err := configsEqual(sidecar, frontMatter)
+		if err != nil {
+			return nil, "", err
+		}
+		if !equal {
+			return nil, "", fmt.Errorf("config in '%s' differs from front-matter config in '%s': keep only one of them", sidecarPath, hookPath)
+		}
+	}
+
+	return sidecar, fmt.Sprintf("'%s'", sidecarPath), nil
+}
+
+// findSidecarConfig returns a path to the sidecar config file for the hook or an empty string.
+func findSidecarConfig(hookPath string) (string, error) {
+	found := make([]string, 0)
+	for _, ext := range StaticConfigExtensions {
+		path := hookPath + ext
+		stat, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", err
+		}
+		if !stat.IsDir() {
+			found = append(found, path)
+		}
+	}
+
+	if len(found) > 1 {
+		return "", fmt.Errorf("several config files found for hook '%s': %s", hookPath, strings.Join(found, ", "))
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+	return "", nil
+}
+
+// readFrontMatter returns a config from a front-matter block or nil if there is no block.
+// The block is a sequence of comment lines at the start of the file, right after
+// the shebang line. It is delimited with '---' lines:
+//
+//	#!/usr/bin/env bash
+//	# ---
+//	# configVersion: v1
+//	# onStartup: 10
+//	# ---
+func readFrontMatter(hookPath string) ([]byte, error) {
+	f, err := os.Open(hookPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 4096), frontMatterMaxLineSize)
+
+	// Skip the shebang line.
+	if !scanner.Scan() {
+		return nil, nil
+	}
+	line := scanner.Text()
+	if strings.HasPrefix(line, "#!") {
+		if !scanner.Scan() {
+			return nil, nil
+		}
+		line = scanner.Text()
+	}
+
+	prefix := frontMatterDelimiterPrefix(line)
+	if prefix == "" {
+		return nil, nil
+	}
+
+	var buf strings.Builder
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line = scanner.Text()
+		if !strings.HasPrefix(line, prefix) {
+			return nil, fmt.Errorf("front-matter line %d is not a '%s' comment", lineNum, prefix)
+		}
+		if frontMatterDelimiterPrefix(line) == prefix {
+			return []byte(buf.String()), nil
+		}
+		line = strings.TrimPrefix(line, prefix)
+		line = strings.TrimPrefix(line, " ")
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("front-matter is not closed with '%s ---' line", prefix)
+}
+
+// frontMatterDelimiterPrefix returns a comment prefix if the line is a front-matter delimiter.
+func frontMatterDelimiterPrefix(line string) string {
+	for _, prefix := range FrontMatterCommentPrefixes {
+		if strings.HasPrefix(line, prefix) && strings.TrimSpace(strings.TrimPrefix(line, prefix)) == "---" {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// configsEqual compares configs in YAML or JSON format.
+func configsEqual(a, b []byte) (bool, error) {
+	var objA, objB interface{}
+	err := yaml.Unmarshal(a, &objA)
+	if err != nil {
+		return false, fmt.Errorf("parse config: %v", err)
+	}
+	err = yaml.Unmarshal(b, &objB)
+	if err != nil {
+		return false, fmt.Errorf("parse front-matter config: %v", err)
+	}
+	return reflect.DeepEqual(objA, objB), nil
+}
+
+// hookChecksum returns a checksum of the hook file and its sidecar config files.
+func hookChecksum(hookPath string) (string, error) {
+	sum, err := checksum.CalculateChecksumOfFile(hookPath)
+	if err != nil {
+		return "", err
+	}
+
+	sums := []string{hookPath + ":" + sum}
+	for _, ext := range StaticConfigExtensions {
+		path := hookPath + ext
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		sum, err := checksum.CalculateChecksumOfFile(path)
+		if err != nil {
+			return "", err
+		}
+		sums = append(sums, path+":"+sum)
+	}
+
+	return checksum.CalculateChecksum(sums...), nil
+}
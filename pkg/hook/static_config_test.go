@@ -0,0 +1,125 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_LoadStaticConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	const script = "#!/bin/bash\necho hook\n"
+	const frontMatter = "#!/bin/bash\n# ---\n# configVersion: v1\n# schedule:\n# - crontab: '* * * * *'\n# ---\necho hook\n"
+
+	tests := []struct {
+		name   string
+		hook   string
+		files  map[string]string
+		config string
+		source string
+		err    string
+	}{
+		{
+			name: "no static config",
+			hook: script,
+		},
+		{
+			name:   "sidecar yaml",
+			hook:   script,
+			files:  map[string]string{"hook.sh.yaml": "configVersion: v1\nonStartup: 10\n"},
+			config: "configVersion: v1\nonStartup: 10\n",
+			source: "hook.sh.yaml",
+		},
+		{
+			name:   "front-matter",
+			hook:   frontMatter,
+			config: "configVersion: v1\nschedule:\n- crontab: '* * * * *'\n",
+			source: "front-matter",
+		},
+		{
+			name:   "front-matter without shebang and with slashes",
+			hook:   "// ---\n// configVersion: v1\n//\n// onStartup: 10\n// ---\nconsole.log('hook')\n",
+			config: "configVersion: v1\n\nonStartup: 10\n",
+			source: "front-matter",
+		},
+		{
+			name:   "same sidecar json and front-matter",
+			hook:   frontMatter,
+			files:  map[string]string{"hook.sh.json": `{"configVersion":"v1","schedule":[{"crontab":"* * * * *"}]}`},
+			config: `{"configVersion":"v1","schedule":[{"crontab":"* * * * *"}]}`,
+			source: "hook.sh.json",
+		},
+		{
+			name:  "different sidecar and front-matter",
+			hook:  frontMatter,
+			files: map[string]string{"hook.sh.yaml": "configVersion: v1\nonStartup: 10\n"},
+			err:   "differs from front-matter",
+		},
+		{
+			name:  "several sidecar files",
+			hook:  script,
+			files: map[string]string{"hook.sh.yaml": "onStartup: 10\n", "hook.sh.json": `{"onStartup": 10}`},
+			err:   "several config files",
+		},
+		{
+			name: "not closed front-matter",
+			hook: "#!/bin/bash\n# ---\n# onStartup: 10\n",
+			err:  "not closed",
+		},
+		{
+			name: "not a comment in front-matter",
+			hook: "#!/bin/bash\n# ---\nonStartup: 10\n# ---\n",
+			err:  "line 1 is not a '#' comment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			hookPath := filepath.Join(dir, "hook.sh")
+			g.Expect(os.WriteFile(hookPath, []byte(tt.hook), 0755)).To(Succeed())
+			for name, content := range tt.files {
+				g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+			}
+
+			config, source, err := LoadStaticConfig(hookPath)
+			if tt.err != "" {
+				g.Expect(err).Should(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.err))
+				return
+			}
+			g.Expect(err).ShouldNot(HaveOccurred())
+			if tt.config == "" {
+				g.Expect(config).To(BeNil())
+				return
+			}
+			g.Expect(string(config)).To(Equal(tt.config))
+			g.Expect(source).To(ContainSubstring(tt.source))
+		})
+	}
+}
+
+func Test_HookManager_StaticConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	hooksDir := t.TempDir()
+	// The hook fails if executed with --config.
+	g.Expect(os.WriteFile(filepath.Join(hooksDir, "hook.sh"), []byte("#!/bin/bash\nexit 1\n"), 0755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(hooksDir, "hook.sh.yaml"), []byte("configVersion: v1\nonStartup: 10\n"), 0644)).To(Succeed())
+
+	hm := newHookManager(t, hooksDir)
+	err := hm.Init()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(hm.GetHookNames()).To(Equal([]string{"hook.sh"}))
+	g.Expect(hm.GetHook("hook.sh").Config.OnStartup.Order).To(Equal(10.0))
+
+	// A changed sidecar file reloads the hook.
+	g.Expect(os.WriteFile(filepath.Join(hooksDir, "hook.sh.yaml"), []byte("configVersion: v1\nonStartup: 20\n"), 0644)).To(Succeed())
+	reload, err := hm.Reload()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(reload.Changed).To(HaveLen(1))
+	g.Expect(hm.GetHook("hook.sh").Config.OnStartup.Order).To(Equal(20.0))
+}
@@ -23,6 +23,11 @@ const (
 type CommonBindingConfig struct {
 	BindingName  string
 	AllowFailure bool
+	// ExecutionTimeout overrides Settings.ExecutionTimeout for the binding.
+	ExecutionTimeout time.Duration
+	// ExecutionMinInterval and ExecutionBurst override the execution rate from Settings for the binding.
+	ExecutionMinInterval time.Duration
+	ExecutionBurst       int
 }
 
 type OnStartupConfig struct {
@@ -47,6 +52,8 @@ type OnKubernetesEventConfig struct {
 	ExecuteHookOnSynchronization bool
 	WaitForSynchronization       bool
 	KeepFullObjectsInMemory      bool
+	// ResynchronizationPeriod is a period to run the hook with a fresh Synchronization binding context.
+	ResynchronizationPeriod time.Duration
 }
 
 type ConversionConfig struct {
@@ -63,7 +70,70 @@ type ValidatingConfig struct {
 	Webhook              *validating.ValidatingWebhookConfig
 }
 
+// Methods to pass binding context to the hook.
+const (
+	BindingContextDeliveryFile  = "file"
+	BindingContextDeliveryStdin = "stdin"
+)
+
+// Hook execution modes.
+const (
+	ExecutionModeExec   = "exec"
+	ExecutionModeWorker = "worker"
+)
+
+// When to start a worker.
+const (
+	WorkerStartLazy     = "lazy"
+	WorkerStartOnConfig = "onConfig"
+)
+
 type Settings struct {
-	ExecutionMinInterval time.Duration
-	ExecutionBurst       int
+	ExecutionMinInterval    time.Duration
+	ExecutionBurst          int
+	ExecutionTimeout        time.Duration
+	BindingContextDelivery  string
+	ExecutionMode           string
+	WorkerStart             string
+	WorkerHealthCheckPeriod time.Duration
+	Limits                  *ResourceLimits
+	Security                *SecurityContext
+	Env                     []EnvVar
+	WorkingDir              string
+	Args                    []string
+	// EnvAllowlist and EnvDenylist narrow operator-level lists.
+	EnvAllowlist []string
+	EnvDenylist  []string
+	// DependsOn are names of hooks that should run their startup and
+	// Synchronization tasks before this hook.
+	DependsOn []string
+}
+
+// EnvVar is an environment variable for the hook process. The value
+// is read from a file or from the Shell-operator's environment if
+// ValueFromFile or ValueFromEnv is set.
+type EnvVar struct {
+	Name          string
+	Value         string
+	ValueFromFile string
+	ValueFromEnv  string
+}
+
+// ResourceLimits restrict resources available to the hook process.
+type ResourceLimits struct {
+	Memory       int64
+	AddressSpace int64
+	CPUTime      time.Duration
+	NoFile       uint64
+	NProc        uint64
+}
+
+// SecurityContext defines a user and privileges for the hook process.
+type SecurityContext struct {
+	// RunAsUser is true if the hook should be started with Uid, Gid and Groups.
+	RunAsUser      bool
+	Uid            uint32
+	Gid            uint32
+	Groups         []uint32
+	DropPrivileges bool
 }
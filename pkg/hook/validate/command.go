@@ -0,0 +1,50 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/flant/shell-operator/pkg/app"
+)
+
+// DefineValidateCommand adds "validate" subcommand to check hooks without a cluster.
+func DefineValidateCommand(hookCmd *kingpin.CmdClause) {
+	var dir string
+	var outputFormat string
+
+	validateCmd := hookCmd.Command("validate", "Validate configurations of hooks in the directory. Exit code is non-zero if some hook is invalid.").
+		Action(func(c *kingpin.ParseContext) error {
+			report, err := Validate(dir)
+			if err != nil {
+				return err
+			}
+
+			var out []byte
+			switch outputFormat {
+			case "json":
+				out, err = report.JSON()
+			case "junit":
+				out, err = report.JUnit()
+			default:
+				out = report.Text()
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+
+			if report.InvalidCount() > 0 {
+				os.Exit(1)
+			}
+			return nil
+		})
+	validateCmd.Arg("hooks_dir", "A directory with hooks.").
+		Default(app.HooksDir).
+		StringVar(&dir)
+	validateCmd.Flag("output", "Output format: json|junit|text.").Short('o').
+		Default("text").
+		EnumVar(&outputFormat, "json", "junit", "text")
+	app.DefineJqFlags(validateCmd)
+}
@@ -0,0 +1,100 @@
+package validate
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// JUnit XML structures. Each hook is a testcase, each issue is a failure.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	Failures  []junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func (r *Report) JUnit() ([]byte, error) {
+	suite := junitTestSuite{
+		Name:     "hooks",
+		Tests:    len(r.Hooks),
+		Failures: r.InvalidCount(),
+	}
+	for _, hr := range r.Hooks {
+		tc := junitTestCase{
+			Name:      hr.File,
+			ClassName: "hooks",
+		}
+		for _, issue := range hr.Issues {
+			tc.Failures = append(tc.Failures, junitFailure{
+				Message: issue.Message,
+				Type:    issue.Path,
+				Text:    issue.String(),
+			})
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	out, err := xml.MarshalIndent(junitTestSuites{
+		Suites:   []junitTestSuite{suite},
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+func (r *Report) Text() []byte {
+	var b strings.Builder
+	for _, hr := range r.Hooks {
+		if hr.IsValid() {
+			fmt.Fprintf(&b, "OK    %s\n", hr.File)
+			continue
+		}
+		fmt.Fprintf(&b, "FAIL  %s\n", hr.File)
+		for _, issue := range hr.Issues {
+			fmt.Fprintf(&b, "      %s\n", issue.String())
+		}
+	}
+	fmt.Fprintf(&b, "%d hooks, %d invalid\n", len(r.Hooks), r.InvalidCount())
+	return []byte(b.String())
+}
+
+// String returns "file: binding: path: message" omitting empty parts.
+func (i Issue) String() string {
+	parts := []string{i.File}
+	if i.Binding != "" {
+		parts = append(parts, i.Binding)
+	}
+	if i.Path != "" {
+		parts = append(parts, i.Path)
+	}
+	parts = append(parts, i.Message)
+	return strings.Join(parts, ": ")
+}
@@ -0,0 +1,301 @@
+package validate
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/errors"
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/robfig/cron.v2"
+
+	"github.com/flant/shell-operator/pkg/app"
+	"github.com/flant/shell-operator/pkg/hook"
+	"github.com/flant/shell-operator/pkg/hook/config"
+	"github.com/flant/shell-operator/pkg/jq"
+	utils_file "github.com/flant/shell-operator/pkg/utils/file"
+	"github.com/flant/shell-operator/pkg/webhook/conversion"
+)
+
+// Issue is a problem found in a hook configuration.
+type Issue struct {
+	// File is a hook path relative to the hooks directory.
+	File string `json:"file"`
+	// Binding is a binding name or a section with index if the binding has no name.
+	Binding string `json:"binding,omitempty"`
+	// Path is a path to the invalid field, e.g. "schedule[0].crontab".
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+// HookReport is a result of validation of one hook.
+type HookReport struct {
+	File   string  `json:"file"`
+	Issues []Issue `json:"issues"`
+}
+
+func (r *HookReport) IsValid() bool {
+	return len(r.Issues) == 0
+}
+
+// Report is a result of validation of all hooks in the directory.
+type Report struct {
+	Hooks []*HookReport `json:"hooks"`
+}
+
+// InvalidCount returns a number of hooks with issues.
+func (r *Report) InvalidCount() int {
+	count := 0
+	for _, h := range r.Hooks {
+		if !h.IsValid() {
+			count++
+		}
+	}
+	return count
+}
+
+// Validate runs checks for every hook in the directory:
+//   - a config is read from a sidecar file, from a front-matter or from
+//     the output of the hook executed with --config flag
+//   - a config is validated with the openapi schema
+//   - crontabs and jqFilters are parsed
+//   - a config is converted with the same checks as in the running operator
+//   - conversion rules of all hooks should connect all versions of each CRD
+func Validate(dir string) (*Report, error) {
+	hookPaths, err := utils_file.RecursiveGetExecutablePaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(hookPaths)
+
+	report := &Report{Hooks: make([]*HookReport, 0, len(hookPaths))}
+	chains := conversion.NewChainStorage()
+	// Conversion bindings by CRD name to attribute chain issues to hook files.
+	crdBindings := map[string][]Issue{}
+	crdNames := make([]string, 0)
+
+	for _, hookPath := range hookPaths {
+		hookName, err := filepath.Rel(dir, hookPath)
+		if err != nil {
+			return nil, err
+		}
+
+		hr := &HookReport{File: hookName, Issues: []Issue{}}
+		report.Hooks = append(report.Hooks, hr)
+
+		hookConfig := validateHook(hr, dir, hookPath)
+		if hookConfig == nil {
+			continue
+		}
+
+		for i, cfg := range hookConfig.KubernetesConversion {
+			crdName := cfg.Webhook.CrdName
+			chain := chains.Get(crdName)
+			for _, rule := range cfg.Webhook.Rules {
+				chain.Put(rule)
+			}
+			if _, has := crdBindings[crdName]; !has {
+				crdNames = append(crdNames, crdName)
+			}
+			crdBindings[crdName] = append(crdBindings[crdName], Issue{
+				File:    hookName,
+				Binding: cfg.BindingName,
+				Path:    fmt.Sprintf("kubernetesCustomResourceConversion[%d]", i),
+			})
+		}
+	}
+
+	hookReports := map[string]*HookReport{}
+	for _, hr := range report.Hooks {
+		hookReports[hr.File] = hr
+	}
+	for _, crdName := range crdNames {
+		err := chains.CheckChain(crdName)
+		if err == nil {
+			continue
+		}
+		for _, binding := range crdBindings[crdName] {
+			hr := hookReports[binding.File]
+			for _, chainErr := range flattenErrors(err) {
+				issue := binding
+				issue.Message = chainErr.Error()
+				hr.Issues = append(hr.Issues, issue)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// validateHook adds issues to the report and returns an effective config
+// if the hook config is valid.
+func validateHook(hr *HookReport, dir string, hookPath string) *config.HookConfig {
+	addIssue := func(binding, path, message string) {
+		hr.Issues = append(hr.Issues, Issue{File: hr.File, Binding: binding, Path: path, Message: message})
+	}
+
+	data, _, err := hook.LoadStaticConfig(hookPath)
+	if err != nil {
+		addIssue("", "", err.Error())
+		return nil
+	}
+	if data == nil {
+		data, err = hook.RunConfigCommand(dir, hr.File, hookPath)
+		if err != nil {
+			addIssue("", "", fmt.Sprintf("run hook with --config: %v", err))
+			return nil
+		}
+	}
+
+	vu := config.NewDefaultVersionedUntyped()
+	err = vu.Load(data)
+	if err != nil {
+		addIssue("", "", err.Error())
+		return nil
+	}
+
+	schemaErr := config.ValidateConfig(vu.Obj, config.GetSchema(vu.Version), "")
+	for _, e := range flattenErrors(schemaErr) {
+		path := ""
+		if ve, ok := e.(*errors.Validation); ok {
+			path = schemaErrorPath(ve.Name)
+		}
+		addIssue(bindingForPath(vu.Obj, path), path, e.Error())
+	}
+
+	kubeSection := "kubernetes"
+	if vu.Version == "v0" {
+		kubeSection = "onKubernetesEvent"
+	}
+	for i, item := range sectionItems(vu.Obj, "schedule") {
+		crontab, _ := item["crontab"].(string)
+		if _, err := cron.Parse(crontab); err != nil {
+			path := fmt.Sprintf("schedule[%d]", i)
+			addIssue(bindingForPath(vu.Obj, path), path+".crontab", fmt.Sprintf("crontab is invalid: %v", err))
+		}
+	}
+	for i, item := range sectionItems(vu.Obj, kubeSection) {
+		jqFilter, _ := item["jqFilter"].(string)
+		if jqFilter == "" {
+			continue
+		}
+		if err := jq.CheckJqFilter(jqFilter, app.JqLibraryPath); err != nil {
+			path := fmt.Sprintf("%s[%d]", kubeSection, i)
+			addIssue(bindingForPath(vu.Obj, path), path+".jqFilter", fmt.Sprintf("jqFilter is invalid: %v", err))
+		}
+	}
+
+	// Typed configs cannot be loaded if schema is not valid.
+	if schemaErr != nil {
+		return nil
+	}
+
+	hookConfig := &config.HookConfig{Version: vu.Version}
+	err = hookConfig.ConvertAndCheck(data)
+	if err != nil {
+		for _, e := range flattenErrors(err) {
+			path := convertErrorPath(e.Error())
+			if hasIssueUnder(hr.Issues, path) {
+				// crontab errors are already reported.
+				continue
+			}
+			addIssue(bindingForPath(vu.Obj, path), path, e.Error())
+		}
+		return nil
+	}
+	if len(hr.Issues) > 0 {
+		return nil
+	}
+
+	return hookConfig
+}
+
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if merr, ok := err.(*multierror.Error); ok {
+		res := make([]error, 0)
+		for _, e := range merr.Errors {
+			res = append(res, flattenErrors(e)...)
+		}
+		return res
+	}
+	return []error{err}
+}
+
+// schemaErrorPath transforms "kubernetes.0.jqFilter" into "kubernetes[0].jqFilter".
+func schemaErrorPath(name string) string {
+	parts := strings.Split(name, ".")
+	var b strings.Builder
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+var convertErrorRe = regexp.MustCompile(`^invalid (\w+) config \[(\d+)\]`)
+
+// convertErrorPath returns a binding path from errors like "invalid schedule config [1]: ...".
+func convertErrorPath(message string) string {
+	m := convertErrorRe.FindStringSubmatch(message)
+	if m == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s[%s]", m[1], m[2])
+}
+
+var bindingPathRe = regexp.MustCompile(`^(\w+)\[(\d+)\]`)
+
+// bindingForPath returns a name of the binding for path "section[index]..." or
+// "section[index]" if the binding has no name.
+func bindingForPath(obj map[string]interface{}, path string) string {
+	m := bindingPathRe.FindStringSubmatch(path)
+	if m == nil {
+		return ""
+	}
+	idx, _ := strconv.Atoi(m[2])
+	items := sectionItems(obj, m[1])
+	if idx < len(items) {
+		if name, ok := items[idx]["name"].(string); ok && name != "" {
+			return name
+		}
+	}
+	return m[0]
+}
+
+// sectionItems returns objects from the array section of the raw config.
+func sectionItems(obj map[string]interface{}, section string) []map[string]interface{} {
+	arr, _ := obj[section].([]interface{})
+	res := make([]map[string]interface{}, 0, len(arr))
+	for _, item := range arr {
+		m, _ := item.(map[string]interface{})
+		res = append(res, m)
+	}
+	return res
+}
+
+func hasIssueUnder(issues []Issue, path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, issue := range issues {
+		if strings.HasPrefix(issue.Path, path+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package validate
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func writeHook(t *testing.T, dir string, name string, config string) {
+	script := "#!/bin/bash\nif [[ $1 == \"--config\" ]]; then\ncat <<EOF\n" + config + "\nEOF\nfi\n"
+	err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755)
+	if err != nil {
+		t.Fatalf("write hook %s: %v", name, err)
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	g := NewWithT(t)
+
+	hooksDir := t.TempDir()
+	writeHook(t, hooksDir, "a.sh", `{"configVersion":"v1", "onStartup": 1}`)
+	writeHook(t, hooksDir, "b.sh", `
+configVersion: v1
+schedule:
+- name: every-minute
+  crontab: "* * * * *"
+- crontab: "* * *"
+kubernetes:
+- name: pods
+  kind: Pod
+  jqFilter: ".metadata | {"
+`)
+	writeHook(t, hooksDir, "c.sh", `{"configVersion":"v1", "onStartup": "bad"}`)
+	writeHook(t, hooksDir, "d.sh", `{"configVersion":"v1", "kubernetes":[{"kind":"Pod", "includeSnapshotsFrom":["unknown"]}]}`)
+	err := os.WriteFile(filepath.Join(hooksDir, "e.sh"), []byte("#!/bin/bash\nexit 1\n"), 0755)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	report, err := Validate(hooksDir)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(report.Hooks).To(HaveLen(5))
+	g.Expect(report.InvalidCount()).To(Equal(4))
+
+	g.Expect(report.Hooks[0].File).To(Equal("a.sh"))
+	g.Expect(report.Hooks[0].IsValid()).To(BeTrue())
+
+	b := report.Hooks[1]
+	g.Expect(b.Issues).To(HaveLen(2))
+	g.Expect(b.Issues[0].File).To(Equal("b.sh"))
+	g.Expect(b.Issues[0].Binding).To(Equal("schedule[1]"))
+	g.Expect(b.Issues[0].Path).To(Equal("schedule[1].crontab"))
+	g.Expect(b.Issues[0].Message).To(ContainSubstring("crontab is invalid"))
+	g.Expect(b.Issues[1].Binding).To(Equal("pods"))
+	g.Expect(b.Issues[1].Path).To(Equal("kubernetes[0].jqFilter"))
+	g.Expect(b.Issues[1].Message).To(ContainSubstring("jqFilter is invalid"))
+
+	c := report.Hooks[2]
+	g.Expect(c.Issues).To(HaveLen(1))
+	g.Expect(c.Issues[0].Path).To(Equal("onStartup"))
+
+	d := report.Hooks[3]
+	g.Expect(d.Issues).To(HaveLen(1))
+	g.Expect(d.Issues[0].Binding).To(Equal("kubernetes[0]"))
+	g.Expect(d.Issues[0].Message).To(ContainSubstring("includeSnapshots"))
+
+	e := report.Hooks[4]
+	g.Expect(e.Issues).To(HaveLen(1))
+	g.Expect(e.Issues[0].Message).To(ContainSubstring("--config"))
+}
+
+func Test_Validate_ConversionChains(t *testing.T) {
+	g := NewWithT(t)
+
+	hooksDir := t.TempDir()
+	writeHook(t, hooksDir, "up.sh", `
+configVersion: v1
+kubernetesCustomResourceConversion:
+- name: up
+  crdName: crontabs.stable.example.com
+  conversions:
+  - fromVersion: stable.example.com/v1alpha1
+    toVersion: stable.example.com/v1
+`)
+
+	report, err := Validate(hooksDir)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(report.InvalidCount()).To(Equal(1))
+	issues := report.Hooks[0].Issues
+	g.Expect(issues).To(HaveLen(1))
+	g.Expect(issues[0].Binding).To(Equal("up"))
+	g.Expect(issues[0].Path).To(Equal("kubernetesCustomResourceConversion[0]"))
+	g.Expect(issues[0].Message).To(ContainSubstring("no conversion path from 'stable.example.com/v1' to 'stable.example.com/v1alpha1'"))
+
+	// A reverse conversion in another hook completes the chain.
+	writeHook(t, hooksDir, "down.sh", `
+configVersion: v1
+kubernetesCustomResourceConversion:
+- name: down
+  crdName: crontabs.stable.example.com
+  conversions:
+  - fromVersion: stable.example.com/v1
+    toVersion: stable.example.com/v1alpha1
+`)
+
+	report, err = Validate(hooksDir)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(report.InvalidCount()).To(Equal(0))
+}
+
+func Test_Report_JUnit(t *testing.T) {
+	g := NewWithT(t)
+
+	report := &Report{Hooks: []*HookReport{
+		{File: "a.sh", Issues: []Issue{}},
+		{File: "b.sh", Issues: []Issue{{File: "b.sh", Binding: "pods", Path: "kubernetes[0].jqFilter", Message: "jqFilter is invalid"}}},
+	}}
+
+	out, err := report.JUnit()
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	var suites junitTestSuites
+	err = xml.Unmarshal(out, &suites)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(suites.Tests).To(Equal(2))
+	g.Expect(suites.Failures).To(Equal(1))
+	g.Expect(suites.Suites[0].TestCases).To(HaveLen(2))
+	g.Expect(suites.Suites[0].TestCases[0].Failures).To(BeEmpty())
+	g.Expect(suites.Suites[0].TestCases[1].Failures[0].Text).To(Equal("b.sh: pods: kubernetes[0].jqFilter: jqFilter is invalid"))
+}
@@ -0,0 +1,478 @@
+package hook
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	uuid "gopkg.in/satori/go.uuid.v1"
+
+	"github.com/flant/shell-operator/pkg/app"
+	"github.com/flant/shell-operator/pkg/executor"
+	"github.com/flant/shell-operator/pkg/utils/exponential_backoff"
+)
+
+// Worker protocol message types.
+const (
+	WorkerRequestRun    = "run"
+	WorkerRequestPing   = "ping"
+	WorkerResponseRun   = "result"
+	WorkerResponsePong  = "pong"
+	WorkerArg           = "--worker"
+	WorkerPingTimeout   = 10 * time.Second
+	WorkerRestartDelay  = time.Second
+	WorkerMaxFrameBytes = 64 * 1024 * 1024
+)
+
+// WorkerRequest is a frame sent to the worker's stdin. Frames are JSON objects separated by a new line.
+type WorkerRequest struct {
+	Id             string          `json:"id"`
+	Type           string          `json:"type"`
+	BindingContext json.RawMessage `json:"bindingContext,omitempty"`
+	// Env contains standard variables for the execution: HOOK_BINDING, HOOK_QUEUE, etc.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// WorkerResponse is a frame read from the worker's stdout. It carries the same
+// payloads as response files in the "exec" mode.
+type WorkerResponse struct {
+	Id                 string            `json:"id"`
+	Type               string            `json:"type"`
+	Error              string            `json:"error,omitempty"`
+	Metrics            []json.RawMessage `json:"metrics,omitempty"`
+	KubernetesPatch    []json.RawMessage `json:"kubernetesPatch,omitempty"`
+	ValidatingResponse json.RawMessage   `json:"validatingResponse,omitempty"`
+	ConversionResponse json.RawMessage   `json:"conversionResponse,omitempty"`
+}
+
+// hookResponse converts payloads into response channels.
+func (r *WorkerResponse) hookResponse() *hookResponse {
+	res := &hookResponse{
+		Validating: r.ValidatingResponse,
+		Conversion: r.ConversionResponse,
+	}
+	for _, metric := range r.Metrics {
+		res.Metrics = append(append(res.Metrics, metric...), '\n')
+	}
+	for _, patch := range r.KubernetesPatch {
+		res.KubernetesPatch = append(append(res.KubernetesPatch, patch...), '\n')
+	}
+	return res
+}
+
+// workerProcess is a running worker executable.
+type workerProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	frames chan *WorkerResponse
+	// done is closed when the process exits.
+	done chan struct{}
+	// exitErr is set before closing done if the process exceeded resource limits.
+	exitErr error
+}
+
+// kill sends SIGKILL to the worker's process group. The process is reaped by the waiting go-routine.
+func (p *workerProcess) kill() {
+	_ = syscall.Kill(-p.cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// hookWorker is a long-running hook executable that handles binding
+// contexts one by one. Requests are serialized, so the worker never
+// handles two binding contexts at the same time.
+type hookWorker struct {
+	hookName string
+	hookPath string
+
+	healthCheckPeriod time.Duration
+	limits            *executor.ResourceLimits
+	security          *executor.SecurityContext
+
+	// dir, args and makeEnv define the worker's command. The hook's directory,
+	// no extra arguments and the Shell-operator's environment are used by default.
+	dir     string
+	args    []string
+	makeEnv func(standard map[string]string) ([]string, error)
+
+	// reqMu serializes requests to the worker.
+	reqMu sync.Mutex
+
+	mu           sync.Mutex
+	proc         *workerProcess
+	restartCount int
+	nextStart    time.Time
+	stopped      bool
+	stopCh       chan struct{}
+	// healthCheck is true when the health checker go-routine is started.
+	healthCheck bool
+}
+
+func newHookWorker(hookName, hookPath string, healthCheckPeriod time.Duration, limits *executor.ResourceLimits) *hookWorker {
+	return &hookWorker{
+		hookName:          hookName,
+		hookPath:          hookPath,
+		healthCheckPeriod: healthCheckPeriod,
+		limits:            limits,
+		stopCh:            make(chan struct{}),
+	}
+}
+
+func (w *hookWorker) logEntry() *log.Entry {
+	return log.WithField("hook", w.hookName).WithField("phase", "worker")
+}
+
+// Start starts the worker process without waiting for the first request.
+func (w *hookWorker) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.ensureStarted()
+	return err
+}
+
+// Stop closes the worker's stdin and kills the worker after a grace period.
+func (w *hookWorker) Stop() {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.stopped = true
+	close(w.stopCh)
+	proc := w.proc
+	w.mu.Unlock()
+
+	if proc == nil {
+		return
+	}
+
+	_ = proc.stdin.Close()
+	select {
+	case <-proc.done:
+	case <-time.After(app.HookTerminationGracePeriod):
+		w.logEntry().Warnf("Worker is not stopped after %s, kill it", app.HookTerminationGracePeriod.String())
+		proc.kill()
+		<-proc.done
+	}
+}
+
+// Run sends the binding context to the worker and waits for the result.
+func (w *hookWorker) Run(bindingContext []byte, env map[string]string, timeout time.Duration) (*hookResponse, error) {
+	w.reqMu.Lock()
+	defer w.reqMu.Unlock()
+
+	resp, err := w.call(&WorkerRequest{
+		Id:             uuid.NewV4().String(),
+		Type:           WorkerRequestRun,
+		BindingContext: bindingContext,
+		Env:            env,
+	}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Type != WorkerResponseRun {
+		return nil, fmt.Errorf("worker responds with '%s' instead of '%s'", resp.Type, WorkerResponseRun)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("worker error: %s", resp.Error)
+	}
+
+	w.mu.Lock()
+	w.restartCount = 0
+	w.mu.Unlock()
+
+	return resp.hookResponse(), nil
+}
+
+// Ping checks that the worker responds to requests.
+func (w *hookWorker) Ping() error {
+	w.reqMu.Lock()
+	defer w.reqMu.Unlock()
+
+	resp, err := w.call(&WorkerRequest{
+		Id:   uuid.NewV4().String(),
+		Type: WorkerRequestPing,
+	}, WorkerPingTimeout)
+	if err != nil {
+		return err
+	}
+	if resp.Type != WorkerResponsePong {
+		return fmt.Errorf("worker responds with '%s' instead of '%s'", resp.Type, WorkerResponsePong)
+	}
+	return nil
+}
+
+func (w *hookWorker) healthCheckLoop() {
+	ticker := time.NewTicker(w.healthCheckPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		w.mu.Lock()
+		running := w.proc != nil
+		w.mu.Unlock()
+		if !running {
+			continue
+		}
+
+		err := w.Ping()
+		if err != nil {
+			w.logEntry().Errorf("Worker health check failed, restart it: %v", err)
+			w.mu.Lock()
+			if w.proc != nil {
+				w.proc.kill()
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// call sends a request and waits for the response with the same id.
+// The worker is killed if no response is received in time.
+func (w *hookWorker) call(req *WorkerRequest, timeout time.Duration) (*WorkerResponse, error) {
+	w.mu.Lock()
+	proc, err := w.ensureStarted()
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	_, err = proc.stdin.Write(append(data, '\n'))
+	if err != nil {
+		return nil, fmt.Errorf("write request to worker: %v", err)
+	}
+
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	for {
+		select {
+		case resp := <-proc.frames:
+			if resp.Id != req.Id {
+				w.logEntry().Warnf("Ignore worker response with unexpected id '%s', expect '%s'", resp.Id, req.Id)
+				continue
+			}
+			return resp, nil
+		case <-proc.done:
+			if proc.exitErr != nil {
+				return nil, fmt.Errorf("worker exited while handling request: %w", proc.exitErr)
+			}
+			return nil, fmt.Errorf("worker exited while handling request")
+		case <-timeoutCh:
+			w.logEntry().Errorf("Worker does not respond in %s, kill it", timeout.String())
+			proc.kill()
+			<-proc.done
+			return nil, &executor.TimeoutError{Timeout: timeout}
+		}
+	}
+}
+
+// ensureStarted returns a running worker process or starts a new one.
+// It should be called with w.mu held.
+func (w *hookWorker) ensureStarted() (*workerProcess, error) {
+	if w.stopped {
+		return nil, fmt.Errorf("worker is stopped")
+	}
+	if w.proc != nil {
+		return w.proc, nil
+	}
+	if wait := time.Until(w.nextStart); wait > 0 {
+		return nil, fmt.Errorf("worker is restarting after failure, next start in %s", wait.Truncate(time.Millisecond).String())
+	}
+
+	proc, err := w.startProcess()
+	if err != nil {
+		w.scheduleRestart()
+		return nil, fmt.Errorf("start worker: %v", err)
+	}
+	w.proc = proc
+
+	if !w.healthCheck && w.healthCheckPeriod > 0 {
+		w.healthCheck = true
+		go w.healthCheckLoop()
+	}
+
+	return proc, nil
+}
+
+// scheduleRestart calculates a delay before the next start. It should be called with w.mu held.
+func (w *hookWorker) scheduleRestart() {
+	delay := exponential_backoff.CalculateDelay(WorkerRestartDelay, w.restartCount)
+	w.restartCount++
+	w.nextStart = time.Now().Add(delay)
+
+	time.AfterFunc(delay, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.stopped || w.proc != nil {
+			return
+		}
+		_, err := w.ensureStarted()
+		if err != nil {
+			w.logEntry().Errorf("Restart worker: %v", err)
+		}
+	})
+}
+
+func (w *hookWorker) startProcess() (*workerProcess, error) {
+	logEntry := w.logEntry()
+
+	envs := []string{}
+	if w.makeEnv != nil {
+		hookEnv, err := w.makeEnv(map[string]string{EnvHookName: w.hookName})
+		if err != nil {
+			return nil, err
+		}
+		envs = append(envs, hookEnv...)
+	} else {
+		envs = append(envs, os.Environ()...)
+	}
+	envs = append(envs, "HOOK_MODE=worker")
+
+	dir := w.dir
+	if dir == "" {
+		dir = path.Dir(w.hookPath)
+	}
+	args := append([]string{WorkerArg}, w.args...)
+
+	cmd := executor.MakeCommand(dir, w.hookPath, args, envs)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, stderrWriter, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutWriter.Close()
+		return nil, err
+	}
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	var limitsGuard *executor.LimitsGuard
+	err = executor.TrackChild(cmd, func() (err error) {
+		limitsGuard, err = executor.StartCommandWithLimits(cmd, w.security, w.limits, logEntry)
+		return err
+	})
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		return nil, err
+	}
+	logEntry.Infof("Worker started with pid %d", cmd.Process.Pid)
+
+	proc := &workerProcess{
+		cmd:    cmd,
+		stdin:  stdin,
+		frames: make(chan *WorkerResponse),
+		done:   make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer stderr.Close()
+		executor.LogLines(stderr, logEntry.WithField("output", "stderr"))
+	}()
+	go func() {
+		defer wg.Done()
+		defer stdout.Close()
+		w.readFrames(stdout, proc)
+	}()
+
+	go func() {
+		err := cmd.Wait()
+		executor.UntrackChild(cmd)
+		// Kill leftovers to close pipes held by background jobs.
+		executor.KillProcessGroup(cmd)
+		if limit := limitsGuard.Exceeded(cmd.ProcessState); limit != "" {
+			err = &executor.LimitExceededError{Limit: limit, Err: err}
+			proc.exitErr = err
+		}
+		limitsGuard.Release()
+		close(proc.done)
+		wg.Wait()
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.proc == proc {
+			w.proc = nil
+		}
+		if w.stopped {
+			logEntry.Infof("Worker stopped")
+			return
+		}
+		logEntry.Errorf("Worker exited unexpectedly: %v", err)
+		w.scheduleRestart()
+	}()
+
+	return proc, nil
+}
+
+// readFrames decodes responses from the worker's stdout.
+func (w *hookWorker) readFrames(r io.Reader, proc *workerProcess) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, err := readFrameLine(reader)
+		if len(line) > 0 {
+			resp := new(WorkerResponse)
+			if jsonErr := json.Unmarshal(line, resp); jsonErr != nil {
+				w.logEntry().Errorf("Bad frame from worker: %v: %s", jsonErr, string(line))
+			} else {
+				select {
+				case proc.frames <- resp:
+				case <-proc.done:
+					return
+				}
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				w.logEntry().Errorf("Read worker stdout: %v", err)
+				proc.kill()
+			}
+			return
+		}
+	}
+}
+
+// readFrameLine reads one line limited by WorkerMaxFrameBytes.
+func readFrameLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		line = append(line, chunk...)
+		if len(line) > WorkerMaxFrameBytes {
+			return nil, fmt.Errorf("frame is bigger than %d bytes", WorkerMaxFrameBytes)
+		}
+		if err != nil || !isPrefix {
+			return line, err
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package hook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/flant/shell-operator/pkg/executor"
+)
+
+// workerScript answers pings, returns a metric for run requests and exits on "crash" in binding context.
+const workerScript = `#!/bin/bash
+while read -r line; do
+  id=$(echo "$line" | grep -o '"id": *"[^"]*"' | sed 's/.*"\([^"]*\)"$/\1/')
+  case "$line" in
+    *'"type":"ping"'*)
+      echo "{\"id\":\"$id\",\"type\":\"pong\"}"
+      ;;
+    *crash*)
+      exit 1
+      ;;
+    *)
+      echo "{\"id\":\"$id\",\"type\":\"result\",\"metrics\":[{\"name\":\"runs\",\"action\":\"add\",\"value\":1}]}"
+      ;;
+  esac
+done
+`
+
+func prepareWorkerScript(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "hook-worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	p := filepath.Join(dir, "worker.sh")
+	if err := ioutil.WriteFile(p, []byte(workerScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func Test_HookWorker_Run(t *testing.T) {
+	g := NewWithT(t)
+
+	w := newHookWorker("worker.sh", prepareWorkerScript(t), 0, nil)
+	defer w.Stop()
+
+	g.Expect(w.Ping()).Should(Succeed())
+
+	for i := 0; i < 3; i++ {
+		res, err := w.Run([]byte(`[{"binding":"test"}]`), nil, 5*time.Second)
+		g.Expect(err).ShouldNot(HaveOccurred())
+
+		result := &HookResult{}
+		g.Expect(res.fillResult(result)).Should(Succeed())
+		g.Expect(result.Metrics).Should(HaveLen(1))
+		g.Expect(result.Metrics[0].Name).Should(Equal("runs"))
+	}
+}
+
+func Test_HookWorker_Restart(t *testing.T) {
+	g := NewWithT(t)
+
+	w := newHookWorker("worker.sh", prepareWorkerScript(t), 0, nil)
+	defer w.Stop()
+
+	_, err := w.Run([]byte(`[{"binding":"crash"}]`), nil, 5*time.Second)
+	g.Expect(err).Should(HaveOccurred())
+
+	// Worker should be restarted after the crash.
+	g.Eventually(func() error {
+		_, err := w.Run([]byte(`[{"binding":"test"}]`), nil, 5*time.Second)
+		return err
+	}, "10s", "100ms").Should(Succeed())
+}
+
+func Test_HookWorker_Timeout(t *testing.T) {
+	g := NewWithT(t)
+
+	// A worker that never answers.
+	p := prepareWorkerScript(t)
+	g.Expect(ioutil.WriteFile(p, []byte("#!/bin/bash\nsleep 30\n"), 0755)).Should(Succeed())
+
+	w := newHookWorker("worker.sh", p, 0, nil)
+	defer w.Stop()
+
+	_, err := w.Run([]byte(`[{"binding":"test"}]`), nil, 500*time.Millisecond)
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(executor.IsTimeout(err)).Should(BeTrue())
+}
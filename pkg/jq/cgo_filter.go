@@ -18,6 +18,18 @@ func runJqFilter(jqFilter string, jsonData []byte, libPath string) (result strin
 	return jqFilterLibJqGo(jqFilter, jsonData, libPath)
 }
 
+// checkJqFilter compiles the program with libjq-go without running it.
+func checkJqFilter(jqFilter string, libPath string) error {
+	if os.Getenv("JQ_EXEC") == "yes" {
+		return checkJqFilterExec(jqFilter, libPath)
+	}
+	_, err := Jq().WithLibPath(libPath).Program(jqFilter).Precompile()
+	if err != nil {
+		return fmt.Errorf("libjq filter '%s': '%s'", jqFilter, err)
+	}
+	return nil
+}
+
 func jqFilterLibJqGo(jqFilter string, jsonData []byte, libPath string) (result string, err error) {
 	result, err = Jq().WithLibPath(libPath).Program(jqFilter).Cached().Run(string(jsonData))
 	if err != nil {
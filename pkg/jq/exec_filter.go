@@ -2,6 +2,7 @@ package jq
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -38,3 +39,26 @@ func jqFilterExec(jqFilter string, jsonData []byte, libPath string) (result stri
 
 	return stdout, nil
 }
+
+// jqCompileErrorExitCode is an exit status of jq if the program cannot be compiled.
+const jqCompileErrorExitCode = 3
+
+// checkJqFilterExec runs jq binary with null input. jq has no option to only
+// compile the program, so the exit status is used to tell compile errors from
+// runtime errors.
+func checkJqFilterExec(jqFilter string, libPath string) error {
+	args := []string{"-n"}
+	if libPath != "" {
+		args = append(args, "-L", libPath)
+	}
+	cmd := exec.Command("/usr/bin/jq", append(args, jqFilter)...)
+	var stderrBuf bytes.Buffer
+	cmd.Stderr = &stderrBuf
+
+	err := executor.Run(cmd)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == jqCompileErrorExitCode {
+		return fmt.Errorf("exec jq: \nerr: '%s'\nstderr: '%s'", err, strings.TrimSpace(stderrBuf.String()))
+	}
+	return nil
+}
@@ -7,3 +7,9 @@ package jq
 func ApplyJqFilter(jqFilter string, jsonData []byte, libPath string) (string, error) {
 	return runJqFilter(jqFilter, jsonData, libPath)
 }
+
+// CheckJqFilter returns an error if jq expression cannot be compiled.
+// Runtime errors are not reported.
+func CheckJqFilter(jqFilter string, libPath string) error {
+	return checkJqFilter(jqFilter, libPath)
+}
@@ -6,3 +6,8 @@ package jq
 func runJqFilter(jqFilter string, jsonData []byte, libPath string) (result string, err error) {
 	return jqFilterExec(jqFilter, jsonData, libPath)
 }
+
+// checkJqFilter runs exec check if CGO is disabled.
+func checkJqFilter(jqFilter string, libPath string) error {
+	return checkJqFilterExec(jqFilter, libPath)
+}
@@ -44,6 +44,19 @@ const (
 )
 
 func ParseOperations(specBytes []byte) ([]Operation, error) {
+	specs, err := ParseOperationSpecs(specBytes)
+
+	var ops = make([]Operation, 0)
+	for _, spec := range specs {
+		ops = append(ops, NewFromOperationSpec(spec))
+	}
+
+	return ops, err
+}
+
+// ParseOperationSpecs returns operation specs from JSON or YAML. Parsing is stopped
+// on the first invalid spec, valid specs before it are returned with the error.
+func ParseOperationSpecs(specBytes []byte) ([]OperationSpec, error) {
 	log.Debugf("parsing patcher operations:\n%s", specBytes)
 
 	specs, err := unmarshalFromJSONOrYAML(specBytes)
@@ -52,17 +65,17 @@ func ParseOperations(specBytes []byte) ([]Operation, error) {
 	}
 
 	var validationErrors = &multierror.Error{}
-	var ops = make([]Operation, 0)
+	var validSpecs = make([]OperationSpec, 0)
 	for _, spec := range specs {
 		err = ValidateOperationSpec(spec, GetSchema("v0"), "")
 		if err != nil {
 			validationErrors = multierror.Append(validationErrors, err)
 			break
 		}
-		ops = append(ops, NewFromOperationSpec(spec))
+		validSpecs = append(validSpecs, spec)
 	}
 
-	return ops, validationErrors.ErrorOrNil()
+	return validSpecs, validationErrors.ErrorOrNil()
 }
 
 // Operation is a command for ObjectPatcher.
@@ -0,0 +1,275 @@
+package kube_events_manager
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
+)
+
+var crdGVR = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// crdHandlerId is a key of the manager's handler in the shared informer for CRDs.
+const crdHandlerId = "kube-events-manager-crds"
+
+// pendingMonitor is a monitor with waitForCRD that waits for its CRD.
+type pendingMonitor struct {
+	config *MonitorConfig
+	// started is true if StartMonitor was called for the monitor.
+	started bool
+}
+
+// crdEvent is a CRD event queued for handleCRDEvents.
+type crdEvent struct {
+	crd     *unstructured.Unstructured
+	deleted bool
+}
+
+// ensureCRDInformer subscribes the manager to events of CustomResourceDefinition objects.
+func (mgr *kubeEventsManager) ensureCRDInformer() {
+	mgr.monitorsLock.Lock()
+	if mgr.crdFactory != nil {
+		mgr.monitorsLock.Unlock()
+		return
+	}
+	index := FactoryIndex{GVR: crdGVR, WatchMode: WatchModeFull}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			mgr.queueCRDEvent(obj, false)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			mgr.queueCRDEvent(obj, false)
+		},
+		DeleteFunc: func(obj interface{}) {
+			mgr.queueCRDEvent(obj, true)
+		},
+	}
+	mgr.crdFactory = mgr.factoryStore.Add(index, crdHandlerId, handler, func() cache.SharedIndexInformer {
+		return newDynamicInformer(mgr.KubeClient.Dynamic(), index)
+	})
+	factory := mgr.crdFactory
+	mgr.monitorsLock.Unlock()
+
+	go mgr.handleCRDEvents()
+	go func() {
+		if err := factory.Start(mgr.informerSyncTime, mgr.ctx.Done()); err != nil {
+			log.Debugf("CRD informer is not synced: %v", err)
+		}
+	}()
+	go func() {
+		<-mgr.ctx.Done()
+		mgr.factoryStore.Remove(index, crdHandlerId)
+	}()
+}
+
+// queueCRDEvent saves the event for handleCRDEvents. It is called by the shared informer and should not block.
+func (mgr *kubeEventsManager) queueCRDEvent(obj interface{}, deleted bool) {
+	if staleObj, stale := obj.(cache.DeletedFinalStateUnknown); stale {
+		obj = staleObj.Obj
+	}
+	crd, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	mgr.crdEventsLock.Lock()
+	mgr.crdEvents = append(mgr.crdEvents, crdEvent{crd: crd, deleted: deleted})
+	mgr.crdEventsLock.Unlock()
+
+	select {
+	case mgr.crdEventsCh <- struct{}{}:
+	default:
+	}
+}
+
+// handleCRDEvents starts and stops monitors for queued CRD events until the manager's context is done.
+func (mgr *kubeEventsManager) handleCRDEvents() {
+	for {
+		select {
+		case <-mgr.ctx.Done():
+			return
+		case <-mgr.crdEventsCh:
+		}
+
+		mgr.crdEventsLock.Lock()
+		events := mgr.crdEvents
+		mgr.crdEvents = nil
+		mgr.crdEventsLock.Unlock()
+
+		for _, ev := range events {
+			if mgr.ctx.Err() != nil {
+				return
+			}
+			mgr.handleCRDEvent(ev.crd, ev.deleted)
+		}
+	}
+}
+
+func (mgr *kubeEventsManager) handleCRDEvent(crd *unstructured.Unstructured, deleted bool) {
+	if deleted {
+		mgr.stopMonitorsForCRD(crd)
+		return
+	}
+
+	// CRD can be not served yet on Added event, so start is retried on Modified events,
+	// e.g. when CRD becomes Established.
+	mgr.monitorsLock.RLock()
+	ids := make([]string, 0)
+	for id, pending := range mgr.pendingMonitors {
+		if crdMatches(crd, pending.config.ApiVersion, pending.config.Kind) {
+			ids = append(ids, id)
+		}
+	}
+	mgr.monitorsLock.RUnlock()
+
+	for _, id := range ids {
+		mgr.startPendingMonitor(id)
+	}
+}
+
+// startPendingMonitor creates informers for the pending monitor. If StartMonitor
+// was already called, the monitor is started and the Synchronization event is emitted.
+func (mgr *kubeEventsManager) startPendingMonitor(monitorID string) {
+	mgr.monitorsLock.RLock()
+	pending, has := mgr.pendingMonitors[monitorID]
+	mgr.monitorsLock.RUnlock()
+	if !has {
+		return
+	}
+	cfg := pending.config
+
+	_, err := mgr.KubeClient.GroupVersionResource(cfg.ApiVersion, cfg.Kind)
+	if err != nil {
+		log.Debugf("%s: apiVersion '%s' kind '%s' is not available yet: %v", cfg.Metadata.DebugName, cfg.ApiVersion, cfg.Kind, err)
+		return
+	}
+
+	monitor := mgr.newMonitor(cfg)
+	err = monitor.CreateInformers()
+	if err != nil {
+		log.Errorf("%s: create informers after CRD is created: %v", cfg.Metadata.DebugName, err)
+		monitor.Stop()
+		return
+	}
+
+	mgr.monitorsLock.Lock()
+	if mgr.pendingMonitors[monitorID] != pending {
+		// Monitor is stopped or started by another event.
+		mgr.monitorsLock.Unlock()
+		monitor.Stop()
+		return
+	}
+	delete(mgr.pendingMonitors, monitorID)
+	mgr.Monitors[monitorID] = monitor
+	mgr.monitorsLock.Unlock()
+
+	log.Infof("%s: CRD for apiVersion '%s' kind '%s' is created, monitor is ready", cfg.Metadata.DebugName, cfg.ApiVersion, cfg.Kind)
+
+	// Monitor will be started with other monitors of the hook.
+	if !pending.started {
+		return
+	}
+
+	monitor.Start(mgr.ctx)
+	if !mgr.sendKubeEvent(KubeEvent{MonitorId: monitorID, Type: TypeSynchronization}) {
+		return
+	}
+	monitor.EnableKubeEventCb()
+}
+
+// stopMonitorsForCRD stops monitors with waitForCRD for the deleted CRD and makes them
+// pending again. Deleted event is emitted for each object in snapshot.
+func (mgr *kubeEventsManager) stopMonitorsForCRD(crd *unstructured.Unstructured) {
+	stopped := make(map[string]Monitor)
+
+	mgr.monitorsLock.Lock()
+	for id, monitor := range mgr.Monitors {
+		if _, has := mgr.pendingMonitors[id]; has {
+			continue
+		}
+		cfg := monitor.GetConfig()
+		if !cfg.WaitForCRD || !crdMatches(crd, cfg.ApiVersion, cfg.Kind) {
+			continue
+		}
+		mgr.Monitors[id] = mgr.newMonitor(cfg)
+		mgr.pendingMonitors[id] = &pendingMonitor{config: cfg, started: true}
+		stopped[id] = monitor
+	}
+	mgr.monitorsLock.Unlock()
+
+	for id, monitor := range stopped {
+		cfg := monitor.GetConfig()
+		objects := monitor.Snapshot()
+		monitor.Stop()
+		log.Infof("%s: CRD for apiVersion '%s' kind '%s' is deleted, monitor is stopped with %d objects", cfg.Metadata.DebugName, cfg.ApiVersion, cfg.Kind, len(objects))
+
+		if !hasWatchEventType(cfg.EventTypes, WatchEventDeleted) {
+			continue
+		}
+		for _, obj := range objects {
+			ev := KubeEvent{
+				MonitorId:   id,
+				Type:        TypeEvent,
+				WatchEvents: []WatchEventType{WatchEventDeleted},
+				Objects:     []ObjectAndFilterResult{obj},
+			}
+			if !mgr.sendKubeEvent(ev) {
+				return
+			}
+		}
+	}
+}
+
+// sendKubeEvent sends the event to KubeEventCh. It returns false if the manager's context is done.
+func (mgr *kubeEventsManager) sendKubeEvent(ev KubeEvent) bool {
+	select {
+	case mgr.KubeEventCh <- ev:
+		return true
+	case <-mgr.ctx.Done():
+		return false
+	}
+}
+
+// crdMatches returns true if CRD defines a resource with apiVersion and kind from the monitor config.
+// Kind is matched case-insensitively with kind, plural, singular and short names like in discovery.
+func crdMatches(crd *unstructured.Unstructured, apiVersion string, kind string) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	if apiVersion != "" {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil || gv.Group != group {
+			return false
+		}
+	}
+
+	names := make([]string, 0)
+	for _, field := range []string{"kind", "plural", "singular"} {
+		name, _, _ := unstructured.NestedString(crd.Object, "spec", "names", field)
+		names = append(names, name)
+	}
+	shortNames, _, _ := unstructured.NestedStringSlice(crd.Object, "spec", "names", "shortNames")
+	names = append(names, shortNames...)
+
+	for _, name := range names {
+		if name != "" && strings.EqualFold(name, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasWatchEventType(eventTypes []WatchEventType, eventType WatchEventType) bool {
+	for _, e := range eventTypes {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package kube_events_manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flant/kube-client/fake"
+	"github.com/flant/kube-client/manifest"
+	. "github.com/onsi/gomega"
+
+	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
+)
+
+const testCRDYAML = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: crontabs.stable.example.com
+spec:
+  group: stable.example.com
+  scope: Namespaced
+  names:
+    kind: CronTab
+    plural: crontabs
+    singular: crontab
+    shortNames:
+    - ct
+`
+
+const testCronTabYAML = `
+apiVersion: stable.example.com/v1
+kind: CronTab
+metadata:
+  name: cron-1
+  namespace: default
+spec:
+  cronSpec: "* * * * */5"
+`
+
+func Test_KubeEventsManager_WaitForCRD(t *testing.T) {
+	g := NewWithT(t)
+	fc := fake.NewFakeCluster(fake.ClusterVersionV121)
+	createNsWithLabels(fc, "default", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewKubeEventsManager()
+	mgr.WithContext(ctx)
+	mgr.WithKubeClient(fc.Client)
+
+	cfg := &MonitorConfig{
+		ApiVersion: "stable.example.com/v1",
+		Kind:       "crontab",
+		WaitForCRD: true,
+		EventTypes: []WatchEventType{WatchEventAdded, WatchEventModified, WatchEventDeleted},
+		NamespaceSelector: &NamespaceSelector{
+			NameSelector: &NameSelector{
+				MatchNames: []string{"default"},
+			},
+		},
+	}
+	cfg.Metadata.MonitorId = "crontabs"
+	cfg.Metadata.DebugName = "crontabs"
+
+	// Monitor is pending without CRD.
+	err := mgr.AddMonitor(cfg)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(mgr.IsMonitorPending("crontabs")).To(BeTrue())
+	mgr.StartMonitor("crontabs")
+	mgr.GetMonitor("crontabs").EnableKubeEventCb()
+	g.Expect(mgr.GetMonitor("crontabs").Snapshot()).To(BeEmpty())
+
+	// Monitor is started with Synchronization when CRD is created.
+	fc.RegisterCRD("stable.example.com", "v1", "CronTab", true)
+	g.Expect(fc.Create("default", manifest.MustFromYAML(testCronTabYAML))).To(Succeed())
+	g.Expect(fc.Create("", manifest.MustFromYAML(testCRDYAML))).To(Succeed())
+
+	var ev KubeEvent
+	g.Eventually(mgr.Ch(), "5s", "10ms").Should(Receive(&ev))
+	g.Expect(ev.MonitorId).To(Equal("crontabs"))
+	g.Expect(ev.Type).To(Equal(TypeSynchronization))
+	g.Expect(mgr.IsMonitorPending("crontabs")).To(BeFalse())
+	g.Expect(snapshotResourceIDs(mgr.GetMonitor("crontabs").Snapshot())).To(Equal([]string{"default/CronTab/cron-1"}))
+
+	// Monitor becomes pending again and emits Deleted events when CRD is deleted.
+	g.Expect(fc.Delete("", manifest.MustFromYAML(testCRDYAML))).To(Succeed())
+
+	g.Eventually(mgr.Ch(), "5s", "10ms").Should(Receive(&ev))
+	g.Expect(ev.MonitorId).To(Equal("crontabs"))
+	g.Expect(ev.Type).To(Equal(TypeEvent))
+	g.Expect(ev.WatchEvents).To(Equal([]WatchEventType{WatchEventDeleted}))
+	g.Expect(snapshotResourceIDs(ev.Objects)).To(Equal([]string{"default/CronTab/cron-1"}))
+	g.Expect(mgr.IsMonitorPending("crontabs")).To(BeTrue())
+	g.Expect(mgr.GetMonitor("crontabs").Snapshot()).To(BeEmpty())
+}
+
+func Test_KubeEventsManager_WaitForCRD_existing_CRD(t *testing.T) {
+	g := NewWithT(t)
+	fc := fake.NewFakeCluster(fake.ClusterVersionV121)
+	createNsWithLabels(fc, "default", nil)
+	fc.RegisterCRD("stable.example.com", "v1", "CronTab", true)
+	g.Expect(fc.Create("default", manifest.MustFromYAML(testCronTabYAML))).To(Succeed())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewKubeEventsManager()
+	mgr.WithContext(ctx)
+	mgr.WithKubeClient(fc.Client)
+
+	cfg := &MonitorConfig{
+		ApiVersion: "stable.example.com/v1",
+		Kind:       "CronTab",
+		WaitForCRD: true,
+		EventTypes: []WatchEventType{WatchEventAdded},
+	}
+	cfg.Metadata.MonitorId = "crontabs"
+	cfg.Metadata.DebugName = "crontabs"
+
+	err := mgr.AddMonitor(cfg)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(mgr.IsMonitorPending("crontabs")).To(BeFalse())
+	g.Expect(snapshotResourceIDs(mgr.GetMonitor("crontabs").Snapshot())).To(Equal([]string{"default/CronTab/cron-1"}))
+}
@@ -0,0 +1,160 @@
+package kube_events_manager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+
+	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
+)
+
+// FactoryIndex is a key of the shared informer. Resource informers with equal
+// resource, namespace, selectors and watch mode use one informer and one LIST/WATCH stream.
+type FactoryIndex struct {
+	GVR           schema.GroupVersionResource
+	Namespace     string
+	FieldSelector string
+	LabelSelector string
+	WatchMode     KubeWatchMode
+}
+
+// Factory is a shared informer that passes events to all subscribed handlers.
+type Factory struct {
+	informer cache.SharedIndexInformer
+
+	handlers     map[string]cache.ResourceEventHandler
+	handlersLock sync.RWMutex
+
+	startOnce sync.Once
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func (f *Factory) Informer() cache.SharedIndexInformer {
+	return f.informer
+}
+
+// HasSynced returns true if the informer is started and its cache is filled.
+func (f *Factory) HasSynced() bool {
+	return f.informer.HasSynced()
+}
+
+// Start runs the informer if it is not running and waits until the cache is synced
+// or the stopCh is closed.
+func (f *Factory) Start(syncPeriod time.Duration, stopCh <-chan struct{}) error {
+	f.startOnce.Do(func() {
+		go f.informer.Run(f.ctx.Done())
+	})
+	return wait.PollImmediateUntil(syncPeriod, func() (bool, error) {
+		return f.informer.HasSynced(), nil
+	}, stopCh)
+}
+
+func (f *Factory) OnAdd(obj interface{}) {
+	for _, handler := range f.currentHandlers() {
+		handler.OnAdd(obj)
+	}
+}
+
+func (f *Factory) OnUpdate(oldObj, newObj interface{}) {
+	for _, handler := range f.currentHandlers() {
+		handler.OnUpdate(oldObj, newObj)
+	}
+}
+
+func (f *Factory) OnDelete(obj interface{}) {
+	for _, handler := range f.currentHandlers() {
+		handler.OnDelete(obj)
+	}
+}
+
+// currentHandlers returns a copy of handlers to not hold the lock while handling events.
+func (f *Factory) currentHandlers() []cache.ResourceEventHandler {
+	f.handlersLock.RLock()
+	defer f.handlersLock.RUnlock()
+	res := make([]cache.ResourceEventHandler, 0, len(f.handlers))
+	for _, handler := range f.handlers {
+		res = append(res, handler)
+	}
+	return res
+}
+
+// FactoryStore keeps shared informers. The informer is created for the first handler
+// and stopped when the last handler is removed.
+type FactoryStore struct {
+	data map[FactoryIndex]*Factory
+	m    sync.Mutex
+}
+
+func NewFactoryStore() *FactoryStore {
+	return &FactoryStore{
+		data: make(map[FactoryIndex]*Factory),
+	}
+}
+
+// Add subscribes the handler to events of the shared informer for the index.
+// newInformer is called to create the informer if there is no informer for the index.
+func (s *FactoryStore) Add(index FactoryIndex, handlerId string, handler cache.ResourceEventHandler, newInformer func() cache.SharedIndexInformer) *Factory {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	factory, has := s.data[index]
+	if !has {
+		factory = newFactory(newInformer())
+		s.data[index] = factory
+		log.Debugf("Factory store: create shared informer for %+v", index)
+	}
+
+	factory.handlersLock.Lock()
+	factory.handlers[handlerId] = handler
+	factory.handlersLock.Unlock()
+	return factory
+}
+
+// Remove unsubscribes the handler. The informer is stopped if there are no more handlers.
+func (s *FactoryStore) Remove(index FactoryIndex, handlerId string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	factory, has := s.data[index]
+	if !has {
+		return
+	}
+
+	factory.handlersLock.Lock()
+	if _, has := factory.handlers[handlerId]; !has {
+		factory.handlersLock.Unlock()
+		return
+	}
+	delete(factory.handlers, handlerId)
+	handlersCount := len(factory.handlers)
+	factory.handlersLock.Unlock()
+
+	if handlersCount == 0 {
+		factory.cancel()
+		delete(s.data, index)
+		log.Debugf("Factory store: stop shared informer for %+v", index)
+	}
+}
+
+// Len returns a number of shared informers.
+func (s *FactoryStore) Len() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return len(s.data)
+}
+
+func newFactory(informer cache.SharedIndexInformer) *Factory {
+	factory := &Factory{
+		informer: informer,
+		handlers: make(map[string]cache.ResourceEventHandler),
+	}
+	factory.ctx, factory.cancel = context.WithCancel(context.Background())
+	factory.informer.AddEventHandler(factory)
+	return factory
+}
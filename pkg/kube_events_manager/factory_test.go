@@ -0,0 +1,106 @@
+package kube_events_manager
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/flant/kube-client/fake"
+	. "github.com/onsi/gomega"
+
+	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
+)
+
+func Test_KubeEventsManager_SharedInformers(t *testing.T) {
+	g := NewWithT(t)
+	fc := fake.NewFakeCluster(fake.ClusterVersionV121)
+
+	createNsWithLabels(fc, "default", nil)
+	createCM(fc, "default", testCM("cm-1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewKubeEventsManager()
+	mgr.WithContext(ctx)
+	mgr.WithKubeClient(fc.Client)
+
+	// Catch events for each monitor.
+	events := map[string][]string{}
+	eventsLock := sync.Mutex{}
+	go func() {
+		for {
+			select {
+			case ev := <-mgr.Ch():
+				eventsLock.Lock()
+				events[ev.MonitorId] = append(events[ev.MonitorId], snapshotResourceIDs(ev.Objects)...)
+				eventsLock.Unlock()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	monitorEvents := func(monitorID string) func() []string {
+		return func() []string {
+			eventsLock.Lock()
+			defer eventsLock.Unlock()
+			return append([]string{}, events[monitorID]...)
+		}
+	}
+
+	newMonitorConfig := func(monitorID string) *MonitorConfig {
+		cfg := &MonitorConfig{
+			ApiVersion: "v1",
+			Kind:       "ConfigMap",
+			EventTypes: []WatchEventType{WatchEventAdded, WatchEventModified, WatchEventDeleted},
+			NamespaceSelector: &NamespaceSelector{
+				NameSelector: &NameSelector{
+					MatchNames: []string{"default"},
+				},
+			},
+		}
+		cfg.Metadata.MonitorId = monitorID
+		cfg.Metadata.DebugName = monitorID
+		return cfg
+	}
+
+	for _, monitorID := range []string{"first", "second"} {
+		err := mgr.AddMonitor(newMonitorConfig(monitorID))
+		g.Expect(err).ShouldNot(HaveOccurred())
+		mgr.StartMonitor(monitorID)
+		mgr.GetMonitor(monitorID).EnableKubeEventCb()
+		g.Expect(snapshotResourceIDs(mgr.GetMonitor(monitorID).Snapshot())).To(Equal([]string{"default/ConfigMap/cm-1"}))
+	}
+
+	// Monitors with equal resources and selectors use one informer.
+	g.Expect(mgr.factoryStore.Len()).To(Equal(1))
+
+	createCM(fc, "default", testCM("cm-2"))
+	for _, monitorID := range []string{"first", "second"} {
+		g.Eventually(monitorEvents(monitorID), "5s", "10ms").Should(ContainElement("default/ConfigMap/cm-2"))
+	}
+
+	// The informer continues to run for the second monitor.
+	err := mgr.StopMonitor("first")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Eventually(func() int { return factoryHandlersCount(mgr.factoryStore) }, "5s", "10ms").Should(Equal(1))
+	createCM(fc, "default", testCM("cm-3"))
+	g.Eventually(monitorEvents("second"), "5s", "10ms").Should(ContainElement("default/ConfigMap/cm-3"))
+	g.Expect(monitorEvents("first")()).ShouldNot(ContainElement("default/ConfigMap/cm-3"))
+	g.Expect(mgr.factoryStore.Len()).To(Equal(1))
+
+	// The informer is stopped with the last monitor.
+	err = mgr.StopMonitor("second")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Eventually(mgr.factoryStore.Len, "5s", "10ms").Should(Equal(0))
+}
+
+func factoryHandlersCount(store *FactoryStore) int {
+	store.m.Lock()
+	defer store.m.Unlock()
+	count := 0
+	for _, factory := range store.data {
+		count += len(factory.currentHandlers())
+	}
+	return count
+}
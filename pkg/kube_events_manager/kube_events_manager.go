@@ -3,9 +3,11 @@ package kube_events_manager
 import (
 	"context"
 	"runtime/trace"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/metadata"
 
 	klient "github.com/flant/kube-client/client"
 	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
@@ -16,9 +18,12 @@ type KubeEventsManager interface {
 	WithContext(ctx context.Context)
 	WithMetricStorage(mstor *metric_storage.MetricStorage)
 	WithKubeClient(client klient.Client)
+	WithMetadataClient(client metadata.Interface)
 	WithSyncPeriod(time.Duration)
+	WithListPageSize(int64)
 	AddMonitor(monitorConfig *MonitorConfig) error
 	HasMonitor(monitorID string) bool
+	IsMonitorPending(monitorID string) bool
 	GetMonitor(monitorID string) Monitor
 	StartMonitor(monitorID string)
 	StopMonitor(monitorID string) error
@@ -30,12 +35,26 @@ type KubeEventsManager interface {
 // kubeEventsManager is a main implementation of KubeEventsManager.
 type kubeEventsManager struct {
 	// Array of monitors
-	Monitors map[string]Monitor
+	Monitors     map[string]Monitor
+	monitorsLock sync.RWMutex
+	// Monitors with waitForCRD that wait for the CRD to be created.
+	pendingMonitors map[string]*pendingMonitor
+	// Shared informer for CustomResourceDefinition objects, it is started for the first waitForCRD monitor.
+	crdFactory *Factory
+	// CRD events are handled in a separate go-routine to not block the shared informer.
+	crdEvents     []crdEvent
+	crdEventsLock sync.Mutex
+	crdEventsCh   chan struct{}
 	// channel to emit KubeEvent objects
 	KubeEventCh      chan KubeEvent
 	informerSyncTime time.Duration
+	listPageSize     int64
+	// Shared informers for all monitors.
+	factoryStore *FactoryStore
 
 	KubeClient klient.Client
+	// A client for monitors with "metadata" watch mode.
+	MetadataClient metadata.Interface
 
 	ctx           context.Context
 	cancel        context.CancelFunc
@@ -49,8 +68,12 @@ var _ KubeEventsManager = &kubeEventsManager{}
 var NewKubeEventsManager = func() *kubeEventsManager {
 	em := &kubeEventsManager{
 		Monitors:         make(map[string]Monitor),
+		pendingMonitors:  make(map[string]*pendingMonitor),
+		crdEventsCh:      make(chan struct{}, 1),
 		KubeEventCh:      make(chan KubeEvent, 1),
 		informerSyncTime: 100 * time.Millisecond,
+		listPageSize:     DefaultListPageSize,
+		factoryStore:     NewFactoryStore(),
 	}
 	return em
 }
@@ -67,59 +90,117 @@ func (mgr *kubeEventsManager) WithKubeClient(client klient.Client) {
 	mgr.KubeClient = client
 }
 
+func (mgr *kubeEventsManager) WithMetadataClient(client metadata.Interface) {
+	mgr.MetadataClient = client
+}
+
 func (mgr *kubeEventsManager) WithSyncPeriod(period time.Duration) {
 	mgr.informerSyncTime = period
 }
 
+// WithListPageSize sets a limit for requests of the initial LIST. 0 disables pagination.
+func (mgr *kubeEventsManager) WithListPageSize(pageSize int64) {
+	mgr.listPageSize = pageSize
+}
+
 // AddMonitor creates a monitor with informers and return a KubeEvent with existing objects.
+// A monitor with waitForCRD is pending until the resource appears in the cluster.
 // TODO cleanup informers in case of error
 // TODO use Context to stop informers
 func (mgr *kubeEventsManager) AddMonitor(monitorConfig *MonitorConfig) error {
 	log.Debugf("Add MONITOR %+v", monitorConfig)
+	monitor := mgr.newMonitor(monitorConfig)
+
+	if monitorConfig.WaitForCRD {
+		// Watch CRDs to start the monitor when CRD is created and to stop it when CRD is deleted.
+		mgr.ensureCRDInformer()
+		_, err := mgr.KubeClient.GroupVersionResource(monitorConfig.ApiVersion, monitorConfig.Kind)
+		if err != nil {
+			log.Infof("%s: wait for CRD with apiVersion '%s' kind '%s': %v", monitorConfig.Metadata.DebugName, monitorConfig.ApiVersion, monitorConfig.Kind, err)
+			mgr.monitorsLock.Lock()
+			mgr.Monitors[monitorConfig.Metadata.MonitorId] = monitor
+			mgr.pendingMonitors[monitorConfig.Metadata.MonitorId] = &pendingMonitor{config: monitorConfig}
+			mgr.monitorsLock.Unlock()
+			return nil
+		}
+	}
+
+	err := monitor.CreateInformers()
+	if err != nil {
+		return err
+	}
+
+	mgr.monitorsLock.Lock()
+	mgr.Monitors[monitorConfig.Metadata.MonitorId] = monitor
+	mgr.monitorsLock.Unlock()
+
+	return nil
+}
+
+// newMonitor returns a monitor with manager's dependencies. Informers are not created.
+func (mgr *kubeEventsManager) newMonitor(monitorConfig *MonitorConfig) Monitor {
 	monitor := NewMonitor()
 	monitor.WithContext(mgr.ctx)
 	monitor.WithKubeClient(mgr.KubeClient)
 	monitor.WithMetricStorage(mgr.metricStorage)
 	monitor.WithConfig(monitorConfig)
 	monitor.WithSyncPeriod(mgr.informerSyncTime)
+	monitor.WithListPageSize(mgr.listPageSize)
+	monitor.WithFactoryStore(mgr.factoryStore)
+	monitor.WithMetadataClient(mgr.MetadataClient)
 	monitor.WithKubeEventCb(func(ev KubeEvent) {
 		defer trace.StartRegion(context.Background(), "EmitKubeEvent").End()
 		mgr.KubeEventCh <- ev
 	})
-
-	err := monitor.CreateInformers()
-	if err != nil {
-		return err
-	}
-
-	mgr.Monitors[monitorConfig.Metadata.MonitorId] = monitor
-
-	return nil
+	return monitor
 }
 
 // HasMonitor returns true if there is a monitor with monitorID.
 func (mgr *kubeEventsManager) HasMonitor(monitorID string) bool {
+	mgr.monitorsLock.RLock()
+	defer mgr.monitorsLock.RUnlock()
 	_, has := mgr.Monitors[monitorID]
 	return has
 }
 
+// IsMonitorPending returns true if the monitor waits for its CRD.
+func (mgr *kubeEventsManager) IsMonitorPending(monitorID string) bool {
+	mgr.monitorsLock.RLock()
+	defer mgr.monitorsLock.RUnlock()
+	_, has := mgr.pendingMonitors[monitorID]
+	return has
+}
+
 // GetMonitor returns monitor by its ID.
 func (mgr *kubeEventsManager) GetMonitor(monitorID string) Monitor {
+	mgr.monitorsLock.RLock()
+	defer mgr.monitorsLock.RUnlock()
 	return mgr.Monitors[monitorID]
 }
 
 // StartMonitor starts all informers for the monitor.
+// A pending monitor is started later, when its CRD is created.
 func (mgr *kubeEventsManager) StartMonitor(monitorID string) {
+	mgr.monitorsLock.Lock()
+	if pending, has := mgr.pendingMonitors[monitorID]; has {
+		pending.started = true
+		mgr.monitorsLock.Unlock()
+		return
+	}
 	monitor := mgr.Monitors[monitorID]
+	mgr.monitorsLock.Unlock()
 	monitor.Start(mgr.ctx)
 }
 
 // StopMonitor stops monitor and removes it from the index.
 func (mgr *kubeEventsManager) StopMonitor(monitorID string) error {
+	mgr.monitorsLock.Lock()
 	monitor, ok := mgr.Monitors[monitorID]
+	delete(mgr.Monitors, monitorID)
+	delete(mgr.pendingMonitors, monitorID)
+	mgr.monitorsLock.Unlock()
 	if ok {
 		monitor.Stop()
-		delete(mgr.Monitors, monitorID)
 	}
 	return nil
 }
@@ -133,6 +214,8 @@ func (mgr *kubeEventsManager) Ch() chan KubeEvent {
 // Useful for shutdown without panicking.
 // Calling cancel() leads to a race and panicking, see https://github.com/kubernetes/kubernetes/issues/59822
 func (mgr *kubeEventsManager) PauseHandleEvents() {
+	mgr.monitorsLock.RLock()
+	defer mgr.monitorsLock.RUnlock()
 	for _, monitor := range mgr.Monitors {
 		monitor.PauseHandleEvents()
 	}
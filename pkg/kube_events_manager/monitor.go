@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/metadata"
 
 	klient "github.com/flant/kube-client/client"
 	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
@@ -21,6 +23,9 @@ type Monitor interface {
 	WithConfig(config *MonitorConfig)
 	WithKubeEventCb(eventCb func(KubeEvent))
 	WithSyncPeriod(time.Duration)
+	WithListPageSize(int64)
+	WithFactoryStore(store *FactoryStore)
+	WithMetadataClient(client metadata.Interface)
 	CreateInformers() error
 	Start(context.Context)
 	Stop()
@@ -36,6 +41,8 @@ type monitor struct {
 	Name       string
 	Config     *MonitorConfig
 	KubeClient klient.Client
+	// A client for monitors with "metadata" watch mode.
+	MetadataClient metadata.Interface
 	// Static list of informers
 	ResourceInformers []ResourceInformer
 	// Namespace informer to get new namespaces
@@ -44,9 +51,14 @@ type monitor struct {
 	VaryingInformers map[string][]ResourceInformer
 
 	informerSyncTime time.Duration
-
-	eventCb       func(KubeEvent)
-	eventsEnabled bool
+	listPageSize     int64
+	// Shared informers for resource informers.
+	factoryStore *FactoryStore
+
+	eventCb func(KubeEvent)
+	// eventsEnabled is 1 after EnableKubeEventCb. It is accessed atomically,
+	// because it is read by namespace informer and resynchronization go-routines.
+	eventsEnabled int32
 	// Index of namespaces statically defined in monitor configuration
 	staticNamespaces map[string]bool
 
@@ -62,6 +74,8 @@ var NewMonitor = func() Monitor {
 		ResourceInformers: make([]ResourceInformer, 0),
 		VaryingInformers:  make(map[string][]ResourceInformer),
 		informerSyncTime:  100 * time.Millisecond,
+		listPageSize:      DefaultListPageSize,
+		factoryStore:      NewFactoryStore(),
 		cancelForNs:       make(map[string]context.CancelFunc),
 		staticNamespaces:  make(map[string]bool),
 	}
@@ -95,6 +109,18 @@ func (m *monitor) WithSyncPeriod(period time.Duration) {
 	m.informerSyncTime = period
 }
 
+func (m *monitor) WithListPageSize(pageSize int64) {
+	m.listPageSize = pageSize
+}
+
+func (m *monitor) WithFactoryStore(store *FactoryStore) {
+	m.factoryStore = store
+}
+
+func (m *monitor) WithMetadataClient(client metadata.Interface) {
+	m.MetadataClient = client
+}
+
 // CreateInformers creates all informers and
 // a namespace informer if namespace.labelSelector is defined.
 // If MonitorConfig.NamespaceSelector.MatchNames is defined, then
@@ -160,7 +186,7 @@ func (m *monitor) CreateInformers() error {
 
 				for _, informer := range m.VaryingInformers[nsName] {
 					informer.WithContext(ctx)
-					if m.eventsEnabled {
+					if atomic.LoadInt32(&m.eventsEnabled) == 1 {
 						informer.EnableKubeEventCb()
 					}
 					informer.Start()
@@ -243,7 +269,7 @@ func (m *monitor) EnableKubeEventCb() {
 		}
 	}
 	// Enable events for future VaryingInformers.
-	m.eventsEnabled = true
+	atomic.StoreInt32(&m.eventsEnabled, 1)
 }
 
 // CreateInformersForNamespace creates informers bounded to the namespace. If no matchName is specified,
@@ -268,6 +294,9 @@ func (m *monitor) CreateInformersForNamespace(namespace string) (informers []Res
 		informer.WithName(objName)
 		informer.WithKubeEventCb(m.eventCb)
 		informer.WithSyncPeriod(m.informerSyncTime)
+		informer.WithListPageSize(m.listPageSize)
+		informer.WithFactoryStore(m.factoryStore)
+		informer.WithMetadataClient(m.MetadataClient)
 
 		err := informer.CreateSharedInformer()
 		if err != nil {
@@ -302,6 +331,32 @@ func (m *monitor) Start(parentCtx context.Context) {
 		m.NamespaceInformer.WithSyncPeriod(m.informerSyncTime)
 		m.NamespaceInformer.Start()
 	}
+
+	if m.Config.ResynchronizationPeriod > 0 {
+		go m.runResynchronization(m.ctx, m.Config.ResynchronizationPeriod)
+	}
+}
+
+// runResynchronization emits Synchronization events periodically until ctx is done.
+// Events are emitted only after EnableKubeEventCb, i.e. after the first Synchronization is handled.
+func (m *monitor) runResynchronization(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if atomic.LoadInt32(&m.eventsEnabled) == 0 || m.eventCb == nil {
+				continue
+			}
+			log.Debugf("%s: periodic Synchronization", m.Config.Metadata.DebugName)
+			m.eventCb(KubeEvent{
+				MonitorId: m.Config.Metadata.MonitorId,
+				Type:      TypeSynchronization,
+			})
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
 // Stop stops all informers
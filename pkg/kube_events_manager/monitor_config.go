@@ -1,6 +1,8 @@
 package kube_events_manager
 
 import (
+	"time"
+
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -30,6 +32,13 @@ type MonitorConfig struct {
 	Mode                    KubeEventMode
 	KeepFullObjectsInMemory bool
 	FilterFunc              func(*unstructured.Unstructured) (interface{}, error)
+	// WatchMode is "metadata" to receive only metadata of objects. Empty value means "full".
+	WatchMode KubeWatchMode
+	// WaitForCRD is true if the monitor should wait for the CRD instead of failing.
+	WaitForCRD bool
+	// ResynchronizationPeriod is a period to emit Synchronization events with all objects.
+	// Zero value disables periodic Synchronization.
+	ResynchronizationPeriod time.Duration
 }
 
 func (c *MonitorConfig) WithEventTypes(types []WatchEventType) *MonitorConfig {
@@ -3,7 +3,9 @@ package kube_events_manager
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/flant/kube-client/fake"
 	"github.com/flant/kube-client/manifest"
@@ -151,3 +153,52 @@ func snapshotResourceIDs(snap []ObjectAndFilterResult) []string {
 	}
 	return ids
 }
+
+func Test_Monitor_should_emit_periodic_Synchronization(t *testing.T) {
+	g := NewWithT(t)
+	fc := fake.NewFakeCluster(fake.ClusterVersionV121)
+
+	createNsWithLabels(fc, "default", nil)
+	createCM(fc, "default", testCM("cm-1"))
+
+	monitorCfg := &MonitorConfig{
+		ApiVersion:              "v1",
+		Kind:                    "ConfigMap",
+		EventTypes:              []WatchEventType{WatchEventAdded, WatchEventModified, WatchEventDeleted},
+		ResynchronizationPeriod: 50 * time.Millisecond,
+	}
+	monitorCfg.Metadata.MonitorId = "test-monitor"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mon := NewMonitor()
+	mon.WithContext(ctx)
+	mon.WithKubeClient(fc.Client)
+	mon.WithConfig(monitorCfg)
+
+	syncEvents := int32(0)
+	mon.WithKubeEventCb(func(ev KubeEvent) {
+		if ev.Type == TypeSynchronization && ev.MonitorId == "test-monitor" {
+			atomic.AddInt32(&syncEvents, 1)
+		}
+	})
+
+	err := mon.CreateInformers()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	mon.Start(ctx)
+
+	// No periodic Synchronization until the first Synchronization is done.
+	time.Sleep(200 * time.Millisecond)
+	g.Expect(atomic.LoadInt32(&syncEvents)).To(BeZero())
+
+	mon.EnableKubeEventCb()
+	g.Eventually(func() int32 { return atomic.LoadInt32(&syncEvents) }, "5s", "10ms").Should(BeNumerically(">=", 2))
+
+	// Stopped monitor emits no events.
+	mon.Stop()
+	time.Sleep(100 * time.Millisecond)
+	stopped := atomic.LoadInt32(&syncEvents)
+	time.Sleep(200 * time.Millisecond)
+	g.Expect(atomic.LoadInt32(&syncEvents)).To(Equal(stopped))
+}
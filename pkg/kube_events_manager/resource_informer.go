@@ -8,11 +8,12 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	uuid "gopkg.in/satori/go.uuid.v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/tools/cache"
 
 	klient "github.com/flant/kube-client/client"
@@ -30,6 +31,9 @@ type ResourceInformer interface {
 	WithName(string)
 	WithKubeEventCb(eventCb func(KubeEvent))
 	WithSyncPeriod(time.Duration)
+	WithListPageSize(int64)
+	WithFactoryStore(store *FactoryStore)
+	WithMetadataClient(client metadata.Interface)
 	CreateSharedInformer() error
 	CachedObjects() []ObjectAndFilterResult
 	CachedObjectsBytes() int64
@@ -43,7 +47,9 @@ type ResourceInformer interface {
 
 type resourceInformer struct {
 	KubeClient klient.Client
-	Monitor    *MonitorConfig
+	// A client for metadata-only watches. Full objects are trimmed if it is not set.
+	MetadataClient metadata.Interface
+	Monitor        *MonitorConfig
 	// Filter by namespace
 	Namespace string
 	// Filter by object name
@@ -54,6 +60,18 @@ type resourceInformer struct {
 	GroupVersionResource schema.GroupVersionResource
 	ListOptions          metav1.ListOptions
 	informerSyncTime     time.Duration
+	// A limit for requests of the initial LIST, 0 means no pagination.
+	listPageSize int64
+	// apiVersion and kind for objects received in "metadata" watch mode.
+	objectApiVersion string
+	objectKind       string
+
+	// Informers with the same resource, namespace and selectors share one Kubernetes informer.
+	factoryStore *FactoryStore
+	factoryIndex FactoryIndex
+	factory      *Factory
+	// id is a key of this informer in the shared informer's handlers.
+	id string
 
 	// A cache of objects and filterResults. It is a part of the Monitor's snapshot.
 	cachedObjects map[string]*ObjectAndFilterResult
@@ -86,6 +104,9 @@ type resourceInformer struct {
 // resourceInformer should implement ResourceInformer
 var _ ResourceInformer = &resourceInformer{}
 
+// DefaultListPageSize is a default limit for requests of the initial LIST. It is equal to the page size of client-go's reflector.
+var DefaultListPageSize int64 = 500
+
 var NewResourceInformer = func(monitor *MonitorConfig) ResourceInformer {
 	informer := &resourceInformer{
 		Monitor:                monitor,
@@ -95,6 +116,8 @@ var NewResourceInformer = func(monitor *MonitorConfig) ResourceInformer {
 		cachedObjectsInfo:      &CachedObjectsInfo{},
 		cachedObjectsIncrement: &CachedObjectsInfo{},
 		informerSyncTime:       100 * time.Millisecond,
+		listPageSize:           DefaultListPageSize,
+		id:                     uuid.NewV4().String(),
 	}
 	return informer
 }
@@ -123,6 +146,18 @@ func (ei *resourceInformer) WithSyncPeriod(period time.Duration) {
 	ei.informerSyncTime = period
 }
 
+func (ei *resourceInformer) WithListPageSize(pageSize int64) {
+	ei.listPageSize = pageSize
+}
+
+func (ei *resourceInformer) WithFactoryStore(store *FactoryStore) {
+	ei.factoryStore = store
+}
+
+func (ei *resourceInformer) WithMetadataClient(client metadata.Interface) {
+	ei.MetadataClient = client
+}
+
 func (ei *resourceInformer) WithKubeEventCb(eventCb func(KubeEvent)) {
 	ei.eventCb = eventCb
 }
@@ -143,11 +178,15 @@ func (ei *resourceInformer) CreateSharedInformer() (err error) {
 	}
 	log.Debugf("%s: GVR for kind '%s' is '%s'", ei.Monitor.Metadata.DebugName, ei.Monitor.Kind, ei.GroupVersionResource.String())
 
-	// define resyncPeriod for informer
-	resyncPeriod := RandomizedResyncPeriod()
-
-	// define indexers for informer
-	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	if ei.Monitor.WatchMode == WatchModeMetadata {
+		// Kind in the monitor config can be plural or lowercased, get the real kind for objects.
+		apiRes, err := ei.KubeClient.APIResource(ei.Monitor.ApiVersion, ei.Monitor.Kind)
+		if err != nil {
+			return err
+		}
+		ei.objectApiVersion = ei.GroupVersionResource.GroupVersion().String()
+		ei.objectKind = apiRes.Kind
+	}
 
 	// define tweakListOptions for informer
 	fmtLabelSelector, err := FormatLabelSelector(ei.Monitor.LabelSelector)
@@ -172,20 +211,51 @@ func (ei *resourceInformer) CreateSharedInformer() (err error) {
 	ei.ListOptions = metav1.ListOptions{}
 	tweakListOptions(&ei.ListOptions)
 
-	// create informer with add, update, delete callbacks
-	informer := dynamicinformer.NewFilteredDynamicInformer(ei.KubeClient.Dynamic(), ei.GroupVersionResource, ei.Namespace, resyncPeriod, indexers, tweakListOptions)
-	informer.Informer().AddEventHandler(ei)
-	ei.SharedInformer = informer.Informer()
+	// Subscribe to the shared informer with add, update, delete callbacks.
+	if ei.factoryStore == nil {
+		ei.factoryStore = NewFactoryStore()
+	}
+	ei.factoryIndex = FactoryIndex{
+		GVR:           ei.GroupVersionResource,
+		Namespace:     ei.Namespace,
+		FieldSelector: fmtFieldSelector,
+		LabelSelector: fmtLabelSelector,
+		WatchMode:     WatchModeFull,
+	}
+	if ei.useMetadataClient() {
+		ei.factoryIndex.WatchMode = WatchModeMetadata
+	}
+	ei.factory = ei.factoryStore.Add(ei.factoryIndex, ei.id, ei, func() cache.SharedIndexInformer {
+		if ei.useMetadataClient() {
+			return newMetadataInformer(ei.MetadataClient, ei.factoryIndex)
+		}
+		return newDynamicInformer(ei.KubeClient.Dynamic(), ei.factoryIndex)
+	})
+	ei.SharedInformer = ei.factory.Informer()
+	if ei.ctx != nil {
+		go func(ctx context.Context) {
+			<-ctx.Done()
+			ei.unsubscribe()
+		}(ei.ctx)
+	}
 
 	err = ei.LoadExistedObjects()
 	if err != nil {
 		log.Errorf("load existing objects: %v", err)
+		ei.unsubscribe()
 		return err
 	}
 
 	return nil
 }
 
+// unsubscribe removes the informer from handlers of the shared informer.
+func (ei *resourceInformer) unsubscribe() {
+	if ei.factoryStore != nil {
+		ei.factoryStore.Remove(ei.factoryIndex, ei.id)
+	}
+}
+
 // Snapshot returns all cached objects for this informer
 func (ei *resourceInformer) CachedObjects() []ObjectAndFilterResult {
 	ei.cacheLock.RLock()
@@ -196,20 +266,20 @@ func (ei *resourceInformer) CachedObjects() []ObjectAndFilterResult {
 	ei.cacheLock.RUnlock()
 
 	// Reset eventBuf if needed.
+	ei.eventBufLock.Lock()
 	if !ei.eventCbEnabled {
-		ei.eventBufLock.Lock()
 		ei.eventBuf = nil
-		ei.eventBufLock.Unlock()
 	}
+	ei.eventBufLock.Unlock()
 	return res
 }
 
 func (ei *resourceInformer) EnableKubeEventCb() {
+	ei.eventBufLock.Lock()
+	defer ei.eventBufLock.Unlock()
 	if ei.eventCbEnabled {
 		return
 	}
-	ei.eventBufLock.Lock()
-	defer ei.eventBufLock.Unlock()
 	ei.eventCbEnabled = true
 	for _, kubeEvent := range ei.eventBuf {
 		// Handle saved kube events.
@@ -226,39 +296,136 @@ func (ei *resourceInformer) CachedObjectsBytes() int64 {
 
 // LoadExistedObjects get a list of existed objects in namespace that match selectors and
 // fills Checksum map with checksums of existing objects.
+// Objects are listed from the cache of the shared informer if it is already synced.
+// Otherwise, objects are listed page by page to not keep all full objects in memory.
 func (ei *resourceInformer) LoadExistedObjects() error {
 	defer trace.StartRegion(context.Background(), "LoadExistedObjects").End()
-	objList, err := ei.KubeClient.Dynamic().
-		Resource(ei.GroupVersionResource).
-		Namespace(ei.Namespace).
-		List(context.TODO(), ei.ListOptions)
+	filteredObjects, err := ei.listExistedObjects(ei.listPageSize)
+	for restart := 1; err != nil && ei.listPageSize > 0 && errors.IsResourceExpired(err) && restart <= maxInitialListRestarts; restart++ {
+		// Pages should be from one resourceVersion, so start again from the first page.
+		log.Warnf("%s: initial list of '%s': continue token is expired, list again from the first page (%d/%d)", ei.Monitor.Metadata.DebugName, ei.Monitor.Kind, restart, maxInitialListRestarts)
+		filteredObjects, err = ei.listExistedObjects(ei.listPageSize)
+	}
 	if err != nil {
 		log.Errorf("%s: initial list resources of kind '%s': %v", ei.Monitor.Metadata.DebugName, ei.Monitor.Kind, err)
 		return err
 	}
 
-	if objList == nil || len(objList.Items) == 0 {
+	if len(filteredObjects) == 0 {
 		log.Debugf("%s: Got no existing '%s' resources", ei.Monitor.Metadata.DebugName, ei.Monitor.Kind)
 		return nil
 	}
 
-	// FIXME objList.Items has too much information for log
-	//log.Debugf("%s: Got %d existing '%s' resources: %+v", ei.Monitor.Metadata.DebugName, len(objList.Items), ei.Monitor.Kind, objList.Items)
-	log.Debugf("%s: '%s' initial list: Got %d existing resources", ei.Monitor.Metadata.DebugName, ei.Monitor.Kind, len(objList.Items))
+	log.Debugf("%s: '%s' initial list: Got %d existing resources", ei.Monitor.Metadata.DebugName, ei.Monitor.Kind, len(filteredObjects))
 
-	var filteredObjects = make(map[string]*ObjectAndFilterResult)
+	// Save objects to the cache.
+	ei.cacheLock.Lock()
+	defer ei.cacheLock.Unlock()
+	for k, v := range filteredObjects {
+		ei.cachedObjects[k] = v
+	}
 
-	for _, item := range objList.Items {
-		// copy loop var to avoid duplication of pointer in filteredObjects
-		obj := item
+	ei.cachedObjectsInfo.Count = uint64(len(ei.cachedObjects))
+	ei.metricStorage.GaugeSet("{PREFIX}kube_snapshot_objects", float64(len(ei.cachedObjects)), ei.Monitor.Metadata.MetricLabels)
+	ei.metricStorage.GaugeSet("{PREFIX}kube_snapshot_bytes", float64(ObjectAndFilterResults(ei.cachedObjects).Bytes()), ei.Monitor.Metadata.MetricLabels)
+
+	return nil
+}
+
+// maxInitialListRestarts is a number of attempts to list all pages again when the continue token is expired.
+const maxInitialListRestarts = 3
+
+// listExistedObjects returns filtered objects from the shared informer cache or from
+// the API server. pageSize is a limit for one LIST request, 0 means no pagination.
+func (ei *resourceInformer) listExistedObjects(pageSize int64) (map[string]*ObjectAndFilterResult, error) {
+	filteredObjects := make(map[string]*ObjectAndFilterResult)
+
+	if ei.factory != nil && ei.factory.HasSynced() {
+		log.Debugf("%s: list '%s' from the shared informer cache", ei.Monitor.Metadata.DebugName, ei.Monitor.Kind)
+		items := ei.factory.Informer().GetStore().List()
+		err := ei.filterExistedObjects(items, filteredObjects)
+		if err != nil {
+			return nil, err
+		}
+		ei.metricStorage.GaugeSet("{PREFIX}kube_initial_list_objects", float64(len(items)), ei.Monitor.Metadata.MetricLabels)
+		return filteredObjects, nil
+	}
+
+	// Continue tokens guarantee that all pages are from the resourceVersion of the first page.
+	options := ei.ListOptions
+	options.Limit = pageSize
+	listed := 0
+	ei.metricStorage.GaugeSet("{PREFIX}kube_initial_list_objects", 0, ei.Monitor.Metadata.MetricLabels)
+	for page := 1; ; page++ {
+		items, listMeta, err := ei.listPage(options)
+		if err != nil {
+			return nil, err
+		}
+		err = ei.filterExistedObjects(items, filteredObjects)
+		if err != nil {
+			return nil, err
+		}
+
+		listed += len(items)
+		ei.metricStorage.GaugeSet("{PREFIX}kube_initial_list_objects", float64(listed), ei.Monitor.Metadata.MetricLabels)
+		log.Debugf("%s: '%s' initial list: page %d: got %d resources, %d in total, resourceVersion %s",
+			ei.Monitor.Metadata.DebugName, ei.Monitor.Kind, page, len(items), listed, listMeta.ResourceVersion)
+
+		if listMeta.Continue == "" {
+			return filteredObjects, nil
+		}
+		options.Continue = listMeta.Continue
+	}
+}
+
+// listPage requests one page of objects from the API server.
+func (ei *resourceInformer) listPage(options metav1.ListOptions) ([]interface{}, metav1.ListMeta, error) {
+	var items []interface{}
+	if ei.useMetadataClient() {
+		metaList, err := ei.MetadataClient.
+			Resource(ei.GroupVersionResource).
+			Namespace(ei.Namespace).
+			List(context.TODO(), options)
+		if err != nil {
+			return nil, metav1.ListMeta{}, err
+		}
+		for i := range metaList.Items {
+			items = append(items, &metaList.Items[i])
+		}
+		return items, metaList.ListMeta, nil
+	}
+
+	objList, err := ei.KubeClient.Dynamic().
+		Resource(ei.GroupVersionResource).
+		Namespace(ei.Namespace).
+		List(context.TODO(), options)
+	if err != nil {
+		return nil, metav1.ListMeta{}, err
+	}
+	for i := range objList.Items {
+		items = append(items, &objList.Items[i])
+	}
+	listMeta := metav1.ListMeta{
+		ResourceVersion: objList.GetResourceVersion(),
+		Continue:        objList.GetContinue(),
+	}
+	return items, listMeta, nil
+}
+
+// filterExistedObjects applies the filter to objects and saves results into filteredObjects.
+func (ei *resourceInformer) filterExistedObjects(items []interface{}, filteredObjects map[string]*ObjectAndFilterResult) error {
+	for _, item := range items {
+		obj, err := ei.toUnstructured(item)
+		if err != nil {
+			return err
+		}
 
 		var objFilterRes *ObjectAndFilterResult
-		var err error
 		func() {
 			defer measure.Duration(func(d time.Duration) {
 				ei.metricStorage.HistogramObserve("{PREFIX}kube_jq_filter_duration_seconds", d.Seconds(), ei.Monitor.Metadata.MetricLabels, nil)
 			})()
-			objFilterRes, err = ApplyFilter(ei.Monitor.JqFilter, ei.Monitor.FilterFunc, &obj)
+			objFilterRes, err = ApplyFilter(ei.Monitor.JqFilter, ei.Monitor.FilterFunc, obj)
 		}()
 
 		if err != nil {
@@ -276,19 +443,27 @@ func (ei *resourceInformer) LoadExistedObjects() error {
 			objFilterRes.Metadata.ResourceId,
 			objFilterRes.Metadata.Checksum)
 	}
+	return nil
+}
 
-	// Save objects to the cache.
-	ei.cacheLock.Lock()
-	defer ei.cacheLock.Unlock()
-	for k, v := range filteredObjects {
-		ei.cachedObjects[k] = v
-	}
-
-	ei.cachedObjectsInfo.Count = uint64(len(ei.cachedObjects))
-	ei.metricStorage.GaugeSet("{PREFIX}kube_snapshot_objects", float64(len(ei.cachedObjects)), ei.Monitor.Metadata.MetricLabels)
-	ei.metricStorage.GaugeSet("{PREFIX}kube_snapshot_bytes", float64(ObjectAndFilterResults(ei.cachedObjects).Bytes()), ei.Monitor.Metadata.MetricLabels)
+// useMetadataClient returns true if objects should be received from the metadata API.
+func (ei *resourceInformer) useMetadataClient() bool {
+	return ei.Monitor.WatchMode == WatchModeMetadata && ei.MetadataClient != nil
+}
 
-	return nil
+// toUnstructured converts an object from the informer into an Unstructured object.
+// In "metadata" watch mode only apiVersion, kind and metadata fields are returned.
+func (ei *resourceInformer) toUnstructured(object interface{}) (*unstructured.Unstructured, error) {
+	switch obj := object.(type) {
+	case *unstructured.Unstructured:
+		if ei.Monitor.WatchMode == WatchModeMetadata {
+			return trimToMetadata(obj), nil
+		}
+		return obj, nil
+	case *metav1.PartialObjectMetadata:
+		return metadataToUnstructured(obj, ei.objectApiVersion, ei.objectKind)
+	}
+	return nil, fmt.Errorf("unexpected object type %T", object)
 }
 
 func (ei *resourceInformer) OnAdd(obj interface{}) {
@@ -321,14 +496,20 @@ func (ei *resourceInformer) HandleWatchEvent(object interface{}, eventType Watch
 	if staleObj, stale := object.(cache.DeletedFinalStateUnknown); stale {
 		object = staleObj.Obj
 	}
-	var obj = object.(*unstructured.Unstructured)
+	obj, err := ei.toUnstructured(object)
+	if err != nil {
+		log.Errorf("%s: WATCH %s: %s",
+			ei.Monitor.Metadata.DebugName,
+			eventType,
+			err)
+		return
+	}
 
 	resourceId := ResourceId(obj)
 
 	// Always calculate checksum and update cache, because we need an actual state in ei.cachedObjects.
 
 	var objFilterRes *ObjectAndFilterResult
-	var err error
 	func() {
 		defer measure.Duration(func(d time.Duration) {
 			ei.metricStorage.HistogramObserve("{PREFIX}kube_jq_filter_duration_seconds", d.Seconds(), ei.Monitor.Metadata.MetricLabels, nil)
@@ -483,14 +664,11 @@ func (ei *resourceInformer) Start() {
 	go func() {
 		<-ei.ctx.Done()
 		ei.stopped = true
+		ei.unsubscribe()
 		close(stopCh)
 	}()
 
-	go ei.SharedInformer.Run(stopCh)
-
-	if err := wait.PollImmediateUntil(ei.informerSyncTime, func() (bool, error) {
-		return ei.SharedInformer.HasSynced(), nil
-	}, stopCh); err != nil {
+	if err := ei.factory.Start(ei.informerSyncTime, stopCh); err != nil {
 		ei.Monitor.LogEntry.Errorf("%s: cache is not synced for informer", ei.Monitor.Metadata.DebugName)
 	}
 
@@ -503,6 +681,7 @@ func (ei *resourceInformer) Stop() {
 		ei.cancel()
 	}
 	ei.stopped = true
+	ei.unsubscribe()
 }
 
 func (ei *resourceInformer) PauseHandleEvents() {
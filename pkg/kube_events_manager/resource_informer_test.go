@@ -0,0 +1,162 @@
+package kube_events_manager
+
+import (
+	"context"
+	"testing"
+
+	klient "github.com/flant/kube-client/client"
+	"github.com/flant/kube-client/fake"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
+	"github.com/flant/shell-operator/pkg/metric_storage"
+)
+
+// pagedClient is a Kubernetes client with a dynamic client that responds to LIST requests
+// with results from the pages function. Fake dynamic client ignores limit and continue token.
+type pagedClient struct {
+	klient.Client
+	pages func(options metav1.ListOptions) (*unstructured.UnstructuredList, error)
+}
+
+func (c *pagedClient) Dynamic() dynamic.Interface {
+	return &pagedDynamic{Interface: c.Client.Dynamic(), pages: c.pages}
+}
+
+type pagedDynamic struct {
+	dynamic.Interface
+	pages func(options metav1.ListOptions) (*unstructured.UnstructuredList, error)
+}
+
+func (d *pagedDynamic) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &pagedResource{NamespaceableResourceInterface: d.Interface.Resource(gvr), pages: d.pages}
+}
+
+type pagedResource struct {
+	dynamic.NamespaceableResourceInterface
+	pages func(options metav1.ListOptions) (*unstructured.UnstructuredList, error)
+}
+
+func (r *pagedResource) Namespace(string) dynamic.ResourceInterface {
+	return r
+}
+
+func (r *pagedResource) List(_ context.Context, options metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return r.pages(options)
+}
+
+func testCMList(continueToken string, names ...string) *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetResourceVersion("100")
+	list.SetContinue(continueToken)
+	for _, name := range names {
+		obj := unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		list.Items = append(list.Items, obj)
+	}
+	return list
+}
+
+func testListMonitorConfig() *MonitorConfig {
+	cfg := &MonitorConfig{
+		ApiVersion: "v1",
+		Kind:       "ConfigMap",
+		EventTypes: []WatchEventType{WatchEventAdded, WatchEventModified, WatchEventDeleted},
+		NamespaceSelector: &NamespaceSelector{
+			NameSelector: &NameSelector{
+				MatchNames: []string{"default"},
+			},
+		},
+	}
+	cfg.Metadata.MonitorId = "list"
+	cfg.Metadata.DebugName = "list"
+	return cfg
+}
+
+func Test_ResourceInformer_initial_list_by_pages(t *testing.T) {
+	g := NewWithT(t)
+	fc := fake.NewFakeCluster(fake.ClusterVersionV121)
+
+	requests := make([]metav1.ListOptions, 0)
+	client := &pagedClient{Client: fc.Client, pages: func(options metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+		requests = append(requests, options)
+		if options.Continue == "" {
+			return testCMList("page-2", "cm-1", "cm-2"), nil
+		}
+		return testCMList("", "cm-3"), nil
+	}}
+
+	mgr := NewKubeEventsManager()
+	mgr.WithContext(context.Background())
+	mgr.WithKubeClient(client)
+	mgr.WithListPageSize(2)
+
+	err := mgr.AddMonitor(testListMonitorConfig())
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(requests).To(HaveLen(2))
+	g.Expect(requests[0].Limit).To(Equal(int64(2)))
+	g.Expect(requests[1].Limit).To(Equal(int64(2)))
+	g.Expect(requests[1].Continue).To(Equal("page-2"))
+	g.Expect(snapshotResourceIDs(mgr.GetMonitor("list").Snapshot())).To(ConsistOf(
+		"default/ConfigMap/cm-1",
+		"default/ConfigMap/cm-2",
+		"default/ConfigMap/cm-3",
+	))
+}
+
+func Test_ResourceInformer_initial_list_with_expired_continue_token(t *testing.T) {
+	g := NewWithT(t)
+	fc := fake.NewFakeCluster(fake.ClusterVersionV121)
+
+	requests := make([]metav1.ListOptions, 0)
+	restarted := false
+	client := &pagedClient{Client: fc.Client, pages: func(options metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+		requests = append(requests, options)
+		switch options.Continue {
+		case "":
+			if restarted {
+				// cm-1 is deleted before the restart.
+				return testCMList("page-2-new", "cm-2"), nil
+			}
+			return testCMList("page-2", "cm-1", "cm-2"), nil
+		case "page-2":
+			restarted = true
+			return nil, errors.NewResourceExpired("continue token is expired")
+		}
+		return testCMList("", "cm-3"), nil
+	}}
+
+	mstor := metric_storage.NewMetricStorage()
+	mstor.WithNewRegistry()
+
+	mgr := NewKubeEventsManager()
+	mgr.WithContext(context.Background())
+	mgr.WithKubeClient(client)
+	mgr.WithMetricStorage(mstor)
+	mgr.WithListPageSize(2)
+
+	err := mgr.AddMonitor(testListMonitorConfig())
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(requests).To(HaveLen(4))
+	// The list is restarted from the first page with the same limit.
+	g.Expect(requests[2].Limit).To(Equal(int64(2)))
+	g.Expect(requests[2].Continue).To(Equal(""))
+	g.Expect(requests[3].Limit).To(Equal(int64(2)))
+	g.Expect(requests[3].Continue).To(Equal("page-2-new"))
+	g.Expect(snapshotResourceIDs(mgr.GetMonitor("list").Snapshot())).To(ConsistOf(
+		"default/ConfigMap/cm-2",
+		"default/ConfigMap/cm-3",
+	))
+	// Objects of the expired list are not counted.
+	gauge := mstor.Gauge("{PREFIX}kube_initial_list_objects", nil).With(nil)
+	g.Expect(testutil.ToFloat64(gauge)).To(Equal(float64(2)))
+}
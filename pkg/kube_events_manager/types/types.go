@@ -33,6 +33,14 @@ const (
 	ModeIncremental KubeEventMode = "Incremental" // Send Synchronization with existed object and Event for each followed event.
 )
 
+// KubeWatchMode defines what parts of objects are received from the API server.
+type KubeWatchMode string
+
+const (
+	WatchModeFull     KubeWatchMode = "full"     // Full objects, the default.
+	WatchModeMetadata KubeWatchMode = "metadata" // Only apiVersion, kind and metadata of objects.
+)
+
 type ObjectAndFilterResult struct {
 	Metadata struct {
 		JqFilter     string
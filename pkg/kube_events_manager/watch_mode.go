@@ -0,0 +1,62 @@
+package kube_events_manager
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+func (index FactoryIndex) tweakListOptions(options *metav1.ListOptions) {
+	if index.FieldSelector != "" {
+		options.FieldSelector = index.FieldSelector
+	}
+	if index.LabelSelector != "" {
+		options.LabelSelector = index.LabelSelector
+	}
+}
+
+// newDynamicInformer returns an informer for full objects.
+func newDynamicInformer(client dynamic.Interface, index FactoryIndex) cache.SharedIndexInformer {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	return dynamicinformer.NewFilteredDynamicInformer(client, index.GVR, index.Namespace, RandomizedResyncPeriod(), indexers, index.tweakListOptions).Informer()
+}
+
+// newMetadataInformer returns an informer for PartialObjectMetadata objects.
+func newMetadataInformer(client metadata.Interface, index FactoryIndex) cache.SharedIndexInformer {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	return metadatainformer.NewFilteredMetadataInformer(client, index.GVR, index.Namespace, RandomizedResyncPeriod(), indexers, index.tweakListOptions).Informer()
+}
+
+// metadataToUnstructured converts PartialObjectMetadata into an Unstructured object
+// with apiVersion, kind and metadata fields. Metadata API returns objects with
+// the PartialObjectMetadata kind, so apiVersion and kind of the resource are set explicitly.
+func metadataToUnstructured(obj *metav1.PartialObjectMetadata, apiVersion, kind string) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&obj.ObjectMeta)
+	if err != nil {
+		return nil, fmt.Errorf("convert metadata of '%s/%s': %v", obj.GetNamespace(), obj.GetName(), err)
+	}
+	res := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": content,
+	}}
+	res.SetAPIVersion(apiVersion)
+	res.SetKind(kind)
+	return res, nil
+}
+
+// trimToMetadata returns a copy of the object with apiVersion, kind and metadata fields.
+func trimToMetadata(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	res := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for _, field := range []string{"apiVersion", "kind", "metadata"} {
+		if v, has := obj.Object[field]; has {
+			res.Object[field] = runtime.DeepCopyJSONValue(v)
+		}
+	}
+	return res
+}
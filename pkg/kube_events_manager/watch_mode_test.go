@@ -0,0 +1,109 @@
+package kube_events_manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flant/kube-client/fake"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	metadatafake "k8s.io/client-go/metadata/fake"
+
+	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
+)
+
+func testMetadataMonitorConfig() *MonitorConfig {
+	cfg := &MonitorConfig{
+		ApiVersion:              "v1",
+		Kind:                    "configmap",
+		WatchMode:               WatchModeMetadata,
+		KeepFullObjectsInMemory: true,
+		EventTypes:              []WatchEventType{WatchEventAdded, WatchEventModified, WatchEventDeleted},
+		NamespaceSelector: &NamespaceSelector{
+			NameSelector: &NameSelector{
+				MatchNames: []string{"default"},
+			},
+		},
+	}
+	cfg.Metadata.MonitorId = "metadata"
+	cfg.Metadata.DebugName = "metadata"
+	return cfg
+}
+
+func testPartialCM(name string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"app": name},
+		},
+	}
+}
+
+func Test_KubeEventsManager_WatchMode_Metadata(t *testing.T) {
+	g := NewWithT(t)
+	fc := fake.NewFakeCluster(fake.ClusterVersionV121)
+	createNsWithLabels(fc, "default", nil)
+
+	scheme := runtime.NewScheme()
+	metav1.AddMetaToScheme(scheme)
+	metadataClient := metadatafake.NewSimpleMetadataClient(scheme, testPartialCM("cm-1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewKubeEventsManager()
+	mgr.WithContext(ctx)
+	mgr.WithKubeClient(fc.Client)
+	mgr.WithMetadataClient(metadataClient)
+
+	err := mgr.AddMonitor(testMetadataMonitorConfig())
+	g.Expect(err).ShouldNot(HaveOccurred())
+	mgr.StartMonitor("metadata")
+	mgr.GetMonitor("metadata").EnableKubeEventCb()
+
+	snapshot := mgr.GetMonitor("metadata").Snapshot()
+	g.Expect(snapshotResourceIDs(snapshot)).To(Equal([]string{"default/ConfigMap/cm-1"}))
+	obj := snapshot[0].Object
+	g.Expect(obj.GetAPIVersion()).To(Equal("v1"))
+	g.Expect(obj.GetKind()).To(Equal("ConfigMap"))
+	g.Expect(obj.GetLabels()).To(Equal(map[string]string{"app": "cm-1"}))
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	_, err = metadataClient.Resource(gvr).Namespace("default").(metadatafake.MetadataClient).
+		CreateFake(testPartialCM("cm-2"), metav1.CreateOptions{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	var ev KubeEvent
+	g.Eventually(mgr.Ch(), "5s", "10ms").Should(Receive(&ev))
+	g.Expect(ev.WatchEvents).To(Equal([]WatchEventType{WatchEventAdded}))
+	g.Expect(snapshotResourceIDs(ev.Objects)).To(Equal([]string{"default/ConfigMap/cm-2"}))
+	g.Expect(ev.Objects[0].Object.GetKind()).To(Equal("ConfigMap"))
+}
+
+func Test_KubeEventsManager_WatchMode_Metadata_without_metadata_client(t *testing.T) {
+	g := NewWithT(t)
+	fc := fake.NewFakeCluster(fake.ClusterVersionV121)
+	createNsWithLabels(fc, "default", nil)
+	createCM(fc, "default", testCM("cm-1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewKubeEventsManager()
+	mgr.WithContext(ctx)
+	mgr.WithKubeClient(fc.Client)
+
+	err := mgr.AddMonitor(testMetadataMonitorConfig())
+	g.Expect(err).ShouldNot(HaveOccurred())
+	mgr.StartMonitor("metadata")
+
+	// Full objects are trimmed to apiVersion, kind and metadata.
+	snapshot := mgr.GetMonitor("metadata").Snapshot()
+	g.Expect(snapshotResourceIDs(snapshot)).To(Equal([]string{"default/ConfigMap/cm-1"}))
+	g.Expect(snapshot[0].Object.Object).To(HaveKey("metadata"))
+	g.Expect(snapshot[0].Object.Object).ToNot(HaveKey("data"))
+}
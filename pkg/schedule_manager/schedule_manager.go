@@ -2,6 +2,7 @@ package schedule_manager
 
 import (
 	"context"
+	"sync"
 
 	. "github.com/flant/shell-operator/pkg/schedule_manager/types"
 	log "github.com/sirupsen/logrus"
@@ -28,6 +29,8 @@ type scheduleManager struct {
 	cron       *cron.Cron
 	ScheduleCh chan string
 	Entries    map[string]CronEntry
+	// m protects Entries: bindings are enabled in the main queue and disabled on hooks reload.
+	m sync.Mutex
 }
 
 var _ ScheduleManager = &scheduleManager{}
@@ -57,6 +60,9 @@ func (sm *scheduleManager) Stop() {
 func (sm *scheduleManager) Add(newEntry ScheduleEntry) {
 	logEntry := log.WithField("operator.component", "scheduleManager")
 
+	sm.m.Lock()
+	defer sm.m.Unlock()
+
 	cronEntry, hasCronEntry := sm.Entries[newEntry.Crontab]
 
 	// If no entry, then add new scheduled function and save CronEntry.
@@ -86,6 +92,9 @@ func (sm *scheduleManager) Add(newEntry ScheduleEntry) {
 }
 
 func (sm *scheduleManager) Remove(delEntry ScheduleEntry) {
+	sm.m.Lock()
+	defer sm.m.Unlock()
+
 	cronEntry, hasCronEntry := sm.Entries[delEntry.Crontab]
 
 	// Nothing to Remove
@@ -105,6 +114,7 @@ func (sm *scheduleManager) Remove(delEntry ScheduleEntry) {
 	// if all ids are deleted, stop scheduled function
 	if len(sm.Entries[delEntry.Crontab].Ids) == 0 {
 		sm.cron.Remove(sm.Entries[delEntry.Crontab].EntryID)
+		delete(sm.Entries, delEntry.Crontab)
 		log.WithField("operator.component", "scheduleManager").Debugf("entry '%s' deleted", delEntry.Crontab)
 	}
 }
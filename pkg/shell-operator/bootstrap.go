@@ -132,6 +132,12 @@ func AssembleCommonOperator(op *ShellOperator) (err error) {
 		return err
 	}
 
+	// A client for metadata-only watches.
+	op.MetadataClient, err = InitDefaultMetadataClient()
+	if err != nil {
+		return err
+	}
+
 	// ObjectPatcher with a separate Kubernetes client.
 	op.ObjectPatcher, err = InitDefaultObjectPatcher(op.MetricStorage)
 	if err != nil {
@@ -202,6 +208,8 @@ func SetupEventManagers(op *ShellOperator) {
 	// Initialize kubernetes events manager.
 	op.KubeEventsManager = kube_events_manager.NewKubeEventsManager()
 	op.KubeEventsManager.WithKubeClient(op.KubeClient)
+	op.KubeEventsManager.WithMetadataClient(op.MetadataClient)
+	op.KubeEventsManager.WithListPageSize(app.KubeListPageSize)
 	op.KubeEventsManager.WithContext(op.ctx)
 	op.KubeEventsManager.WithMetricStorage(op.MetricStorage)
 
@@ -3,6 +3,7 @@ package shell_operator
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
@@ -10,6 +11,7 @@ import (
 	"github.com/flant/shell-operator/pkg/app"
 	"github.com/flant/shell-operator/pkg/config"
 	"github.com/flant/shell-operator/pkg/debug"
+	"github.com/flant/shell-operator/pkg/hook"
 	"github.com/flant/shell-operator/pkg/task/dump"
 )
 
@@ -42,16 +44,96 @@ func RegisterDebugQueueRoutes(dbgSrv *debug.Server, op *ShellOperator) {
 	})
 }
 
+// HookStatus is a response for the /hook/status endpoint.
+type HookStatus struct {
+	Hooks []string `json:"hooks"`
+	// Invalid are hooks quarantined because of invalid configurations.
+	Invalid []hook.InvalidHook `json:"invalid"`
+	// Disabled are hooks disabled at runtime.
+	Disabled []string `json:"disabled"`
+}
+
+func (l HookStatus) String() string {
+	var buf strings.Builder
+	for _, name := range l.Hooks {
+		buf.WriteString(name)
+		buf.WriteString("\n")
+	}
+	if len(l.Disabled) > 0 {
+		buf.WriteString("\nDisabled hooks:\n")
+		for _, name := range l.Disabled {
+			buf.WriteString(name)
+			buf.WriteString("\n")
+		}
+	}
+	if len(l.Invalid) > 0 {
+		buf.WriteString("\nInvalid hooks:\n")
+		for _, h := range l.Invalid {
+			buf.WriteString(fmt.Sprintf("%s: %s\n", h.Name, h.Error))
+		}
+	}
+	return buf.String()
+}
+
 func RegisterDebugHookRoutes(dbgSrv *debug.Server, op *ShellOperator) {
 	dbgSrv.Route("/hook/list.{format:(json|yaml|text)}", func(_ *http.Request) (interface{}, error) {
 		return op.HookManager.GetHookNames(), nil
 	})
 
+	// Invalid and disabled hooks are not in /hook/list to keep its format.
+	dbgSrv.Route("/hook/status.{format:(json|yaml|text)}", func(_ *http.Request) (interface{}, error) {
+		hookNames := op.HookManager.GetHookNames()
+		disabled := make([]string, 0)
+		for _, name := range hookNames {
+			if op.HookManager.IsHookDisabled(name) {
+				disabled = append(disabled, name)
+			}
+		}
+		return HookStatus{
+			Hooks:    hookNames,
+			Invalid:  op.HookManager.GetInvalidHooks(),
+			Disabled: disabled,
+		}, nil
+	})
+
+	// Pass "dropTasks=true" to remove queued tasks of the hook.
+	dbgSrv.RoutePOST("/hook/{name}/disable", func(r *http.Request) (interface{}, error) {
+		hookName := chi.URLParam(r, "name")
+		dropTasks := r.PostForm.Get("dropTasks") == "true"
+		err := op.DisableHook(hookName, dropTasks)
+		if err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("hook '%s' is disabled", hookName), nil
+	})
+
+	dbgSrv.RoutePOST("/hook/{name}/enable", func(r *http.Request) (interface{}, error) {
+		hookName := chi.URLParam(r, "name")
+		err := op.EnableHook(hookName)
+		if err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("hook '%s' is enabled", hookName), nil
+	})
+
 	dbgSrv.Route("/hook/{name}/snapshots.{format:(json|yaml|text)}", func(r *http.Request) (interface{}, error) {
 		hookName := chi.URLParam(r, "name")
 		h := op.HookManager.GetHook(hookName)
 		return h.HookController.SnapshotsDump(), nil
 	})
+
+	dbgSrv.Route("/hook/{name}/last-run.{format:(json|yaml|text)}", func(r *http.Request) (interface{}, error) {
+		hookName := chi.URLParam(r, "name")
+		h := op.HookManager.GetHook(hookName)
+		if h == nil {
+			return nil, fmt.Errorf("hook '%s' is not found", hookName)
+		}
+		lastRun := h.LastRun()
+		if lastRun == nil {
+			return "hook is not executed yet", nil
+		}
+		return lastRun, nil
+	})
 }
 
 // RegisterDebugConfigRoutes registers routes to manage runtime configuration.
@@ -0,0 +1,192 @@
+package shell_operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"sigs.k8s.io/yaml"
+
+	"github.com/flant/shell-operator/pkg/app"
+	"github.com/flant/shell-operator/pkg/executor"
+	"github.com/flant/shell-operator/pkg/hook"
+	. "github.com/flant/shell-operator/pkg/hook/binding_context"
+	"github.com/flant/shell-operator/pkg/kube/object_patch"
+	"github.com/flant/shell-operator/pkg/metric_storage"
+	"github.com/flant/shell-operator/pkg/metric_storage/operation"
+	"github.com/flant/shell-operator/pkg/webhook/conversion"
+	. "github.com/flant/shell-operator/pkg/webhook/validating/types"
+)
+
+// HookRunReport is a parsed result of the hook executed by the "hook run" command.
+type HookRunReport struct {
+	Hook                 string                       `json:"hook"`
+	Usage                *executor.CmdUsage           `json:"usage,omitempty"`
+	Metrics              []operation.MetricOperation  `json:"metrics"`
+	KubernetesOperations []object_patch.OperationSpec `json:"kubernetesOperations"`
+	ValidatingResponse   *ValidatingResponse          `json:"validatingResponse,omitempty"`
+	ConversionResponse   *conversion.Response         `json:"conversionResponse,omitempty"`
+	// Applied is true if Kubernetes operations are executed.
+	Applied bool `json:"applied"`
+}
+
+func (r *HookRunReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hook: %s\n", r.Hook)
+	if r.Usage != nil {
+		fmt.Fprintf(&b, "Exit code: %d, sys: %s, user: %s, max rss: %dKb\n", r.Usage.ExitCode, r.Usage.Sys, r.Usage.User, r.Usage.MaxRss)
+	}
+
+	fmt.Fprintf(&b, "Metrics: %d\n", len(r.Metrics))
+	for _, m := range r.Metrics {
+		fmt.Fprintf(&b, "  %s\n", m.String())
+	}
+
+	applied := "not applied, use --apply to execute"
+	if r.Applied {
+		applied = "applied"
+	}
+	fmt.Fprintf(&b, "Kubernetes operations: %d (%s)\n", len(r.KubernetesOperations), applied)
+	for _, spec := range r.KubernetesOperations {
+		fmt.Fprintf(&b, "  %s\n", object_patch.NewFromOperationSpec(spec).Description())
+	}
+
+	if r.ValidatingResponse != nil {
+		fmt.Fprintf(&b, "Validating response: %s\n", r.ValidatingResponse.Dump())
+	}
+	if r.ConversionResponse != nil {
+		fmt.Fprintf(&b, "Conversion response: %s\n", r.ConversionResponse.Dump())
+	}
+	return b.String()
+}
+
+// RunHookWithBindingContext executes the hook with binding contexts from the file
+// and returns parsed results. Kubernetes operations are executed only if apply is true.
+func RunHookWithBindingContext(hooksDir, tempDir, hookName, bindingContextPath string, apply bool) (*HookRunReport, error) {
+	data, err := os.ReadFile(bindingContextPath)
+	if err != nil {
+		return nil, fmt.Errorf("read binding context: %v", err)
+	}
+	var contextList BindingContextList
+	err = yaml.Unmarshal(data, &contextList)
+	if err != nil {
+		return nil, fmt.Errorf("parse binding context from '%s': %v", bindingContextPath, err)
+	}
+
+	h, err := hook.LoadHook(hooksDir, tempDir, filepath.Join(hooksDir, hookName))
+	if err != nil {
+		return nil, err
+	}
+	defer h.StopWorker()
+
+	result, err := h.RunWithBindingContextList(contextList, map[string]string{"hook": h.Name}, hook.TaskInfo{})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &HookRunReport{
+		Hook:                 h.Name,
+		Usage:                result.Usage,
+		Metrics:              result.Metrics,
+		KubernetesOperations: []object_patch.OperationSpec{},
+		ValidatingResponse:   result.ValidatingResponse,
+		ConversionResponse:   result.ConversionResponse,
+	}
+	if report.Metrics == nil {
+		report.Metrics = []operation.MetricOperation{}
+	}
+
+	if len(result.KubernetesPatchBytes) == 0 {
+		return report, nil
+	}
+
+	report.KubernetesOperations, err = object_patch.ParseOperationSpecs(result.KubernetesPatchBytes)
+	if err != nil {
+		return report, err
+	}
+	if !apply {
+		return report, nil
+	}
+
+	operations, err := object_patch.ParseOperations(result.KubernetesPatchBytes)
+	if err != nil {
+		return report, err
+	}
+	patcher, err := InitDefaultObjectPatcher(metric_storage.NewMetricStorage())
+	if err != nil {
+		return report, err
+	}
+	err = patcher.ExecuteOperations(operations)
+	if err != nil {
+		return report, err
+	}
+	report.Applied = true
+
+	return report, nil
+}
+
+// DefineHookRunCommand adds "run" subcommand to execute the hook with a recorded binding context.
+func DefineHookRunCommand(hookCmd *kingpin.CmdClause) {
+	var hookName string
+	var bindingContextPath string
+	var apply bool
+	var outputFormat string
+
+	runCmd := hookCmd.Command("run", "Run the hook with a binding context from the file and print parsed results. Kubernetes operations are not executed without --apply.").
+		Action(func(c *kingpin.ParseContext) error {
+			hooksDir, err := RequireExistingDirectory(app.HooksDir)
+			if err != nil {
+				return err
+			}
+			tempDir, err := EnsureTempDirectory(app.TempDir)
+			if err != nil {
+				return err
+			}
+
+			report, runErr := RunHookWithBindingContext(hooksDir, tempDir, hookName, bindingContextPath, apply)
+			if report != nil {
+				var out []byte
+				var err error
+				switch outputFormat {
+				case "json":
+					out, err = json.MarshalIndent(report, "", "  ")
+				case "yaml":
+					out, err = yaml.Marshal(report)
+				default:
+					out = []byte(report.String())
+				}
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(out))
+			}
+			return runErr
+		})
+	runCmd.Arg("hook_name", "A hook path relative to the hooks directory.").Required().StringVar(&hookName)
+	runCmd.Flag("binding-context", "A path to the JSON or YAML file with an array of binding contexts in the format of the hook's config version.").
+		Required().
+		StringVar(&bindingContextPath)
+	runCmd.Flag("apply", "Execute Kubernetes operations returned by the hook.").
+		BoolVar(&apply)
+	runCmd.Flag("output", "Output format: json|yaml|text.").Short('o').
+		Default("text").
+		EnumVar(&outputFormat, "json", "yaml", "text")
+
+	flag := app.CommonFlagsInfo["hooks-dir"]
+	runCmd.Flag(flag.Name, flag.Help).
+		Envar(flag.Envar).
+		Default(app.HooksDir).
+		StringVar(&app.HooksDir)
+	flag = app.CommonFlagsInfo["tmp-dir"]
+	runCmd.Flag(flag.Name, flag.Help).
+		Envar(flag.Envar).
+		Default(app.TempDir).
+		StringVar(&app.TempDir)
+
+	app.DefineKubeClientFlags(runCmd)
+	app.DefineHookFlags(runCmd)
+	app.DefineJqFlags(runCmd)
+}
@@ -0,0 +1,106 @@
+package shell_operator
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	. "github.com/flant/shell-operator/pkg/hook/task_metadata"
+	. "github.com/flant/shell-operator/pkg/hook/types"
+	. "github.com/flant/shell-operator/pkg/kube_events_manager/types"
+	"github.com/flant/shell-operator/pkg/task"
+	"github.com/flant/shell-operator/pkg/task/queue"
+)
+
+// DisableHook pauses schedule and kubernetes bindings of the hook. Queued tasks
+// to run the hook for these bindings are removed if dropTasks is true, otherwise
+// they are executed as usual.
+func (op *ShellOperator) DisableHook(name string, dropTasks bool) error {
+	err := op.HookManager.DisableHook(name)
+	if err != nil {
+		return err
+	}
+	log.WithField("hook", name).Warnf("Hook is disabled, schedule and kubernetes events are ignored")
+	op.updateHookDisabledMetric(name, true)
+
+	if dropTasks {
+		op.dropHookRunTasks(name)
+	}
+	return nil
+}
+
+// EnableHook resumes handling of schedule and kubernetes events for the disabled hook.
+// Kubernetes events are ignored while the hook is disabled, so Synchronization tasks
+// are queued for kubernetes bindings to handle objects changed in the meantime.
+func (op *ShellOperator) EnableHook(name string) error {
+	err := op.HookManager.EnableHook(name)
+	if err != nil {
+		return err
+	}
+	log.WithField("hook", name).Infof("Hook is enabled")
+	op.updateHookDisabledMetric(name, false)
+
+	h := op.HookManager.GetHook(name)
+	tasks := make([]task.Task, 0)
+	for _, kubeCfg := range h.GetConfig().OnKubernetesEvents {
+		tasks = append(tasks, op.kubeEventTasks(KubeEvent{
+			MonitorId: kubeCfg.Monitor.Metadata.MonitorId,
+			Type:      TypeSynchronization,
+		})...)
+	}
+	op.TaskQueues.DoWithLock(func(tqs *queue.TaskQueueSet) {
+		for _, t := range tasks {
+			q := tqs.GetByName(t.GetQueueName())
+			if q == nil {
+				log.Errorf("Possible bug!!! Got task for queue '%s' but queue is not created yet. task: %s", t.GetQueueName(), t.GetDescription())
+				continue
+			}
+			q.AddLast(t)
+		}
+	})
+	return nil
+}
+
+func (op *ShellOperator) updateHookDisabledMetric(name string, disabled bool) {
+	if op.MetricStorage == nil {
+		return
+	}
+	value := 0.0
+	if disabled {
+		value = 1.0
+	}
+	op.MetricStorage.GaugeSet("{PREFIX}hook_disabled", value, map[string]string{"hook": name})
+}
+
+// deleteHookDisabledMetric removes the metric for the removed hook.
+func (op *ShellOperator) deleteHookDisabledMetric(name string) {
+	if op.MetricStorage == nil {
+		return
+	}
+	labels := map[string]string{"hook": name}
+	op.MetricStorage.Gauge("{PREFIX}hook_disabled", labels).Delete(labels)
+}
+
+// dropHookRunTasks removes tasks to run the hook for schedule and kubernetes
+// events from all queues. Synchronization tasks are kept: they unlock
+// kubernetes events for monitors.
+func (op *ShellOperator) dropHookRunTasks(hookName string) {
+	dropped := 0
+	op.TaskQueues.Iterate(func(q *queue.TaskQueue) {
+		q.Filter(func(t task.Task) bool {
+			if t.GetType() != HookRun {
+				return true
+			}
+			hookMeta := HookMetadataAccessor(t)
+			if hookMeta.HookName != hookName || hookMeta.IsSynchronization() {
+				return true
+			}
+			if hookMeta.BindingType == Schedule || hookMeta.BindingType == OnKubernetesEvent {
+				dropped++
+				return false
+			}
+			return true
+		})
+	})
+	if dropped > 0 {
+		log.WithField("hook", hookName).Infof("Drop %d tasks for disabled hook", dropped)
+	}
+}
@@ -0,0 +1,264 @@
+package shell_operator
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/flant/shell-operator/pkg/app"
+	"github.com/flant/shell-operator/pkg/hook"
+	. "github.com/flant/shell-operator/pkg/hook/binding_context"
+	. "github.com/flant/shell-operator/pkg/hook/task_metadata"
+	. "github.com/flant/shell-operator/pkg/hook/types"
+	"github.com/flant/shell-operator/pkg/task"
+	"github.com/flant/shell-operator/pkg/task/queue"
+)
+
+var allBindingTypes = []BindingType{OnStartup, Schedule, OnKubernetesEvent, KubernetesValidating, KubernetesConversion}
+
+// RunHooksReload starts watching the hooks directory if --hooks-reload is enabled.
+func (op *ShellOperator) RunHooksReload() {
+	if !app.HooksReload || op.HookManager == nil {
+		return
+	}
+
+	watcher := hook.NewDirWatcher(op.HookManager.WorkingDir(), app.HooksReloadScanPeriod)
+	watcher.WithContext(op.ctx)
+	watcher.Start()
+
+	go func() {
+		for {
+			select {
+			case <-watcher.Ch():
+				op.ReloadHooks()
+			case <-op.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// ReloadHooks loads added, removed and changed hooks and applies changes:
+//   - tasks for removed hooks and changed bindings are drained from queues
+//   - monitors, schedules and webhooks for removed hooks and changed bindings are stopped
+//   - tasks to enable bindings of added hooks and changed bindings are queued
+//     into the main queue, added hooks also run with OnStartup binding
+//
+// Previous hooks continue to run if reload is failed.
+func (op *ShellOperator) ReloadHooks() {
+	logEntry := log.WithField("operator.component", "hooksReload")
+	logEntry.Info("Hooks directory is changed, reload hooks")
+
+	reload, err := op.HookManager.Reload()
+	if err != nil {
+		logEntry.Errorf("Reload hooks failed, previous hooks are kept: %v", err)
+		op.MetricStorage.CounterAdd("{PREFIX}hooks_reload_errors_total", 1.0, map[string]string{})
+		return
+	}
+	op.MetricStorage.CounterAdd("{PREFIX}hooks_reload_total", 1.0, map[string]string{})
+	op.updateInvalidHooksMetric()
+
+	if reload.IsEmpty() {
+		logEntry.Info("No hooks are changed")
+		return
+	}
+	logEntry.Infof("Hooks are reloaded: %s", reload.String())
+
+	for _, h := range reload.Removed {
+		logEntry.Infof("Hook '%s' is removed, stop its bindings", h.Name)
+		op.drainHookTasks(h.Name, allBindingTypes)
+		disableHookBindings(h, allBindingTypes)
+		h.StopWorker()
+		// A removed hook is enabled if it is added again.
+		op.deleteHookDisabledMetric(h.Name)
+	}
+
+	for _, change := range reload.Changed {
+		logEntry.Infof("Hook '%s' is changed, restart bindings: %v", change.New.Name, change.ChangedBindings)
+		op.drainHookTasks(change.Old.Name, change.ChangedBindings)
+		disableHookBindings(change.Old, change.ChangedBindings)
+		change.Old.StopWorker()
+	}
+
+	op.TaskQueues.DoWithLock(func(tqs *queue.TaskQueueSet) {
+		// Create queues for new bindings.
+		op.InitAndStartHookQueues()
+
+		mainQueue := tqs.GetMain()
+		for _, h := range reload.Added {
+			for _, newTask := range enableBindingsTasks(h, allBindingTypes) {
+				mainQueue.AddLast(newTask)
+				logEntry.Infof("queue task %s for hook %s", newTask.GetDescription(), h.Name)
+			}
+		}
+		for _, change := range reload.Changed {
+			// OnStartup is not executed again for changed hooks.
+			bindingTypes := make([]BindingType, 0)
+			for _, bindingType := range change.ChangedBindings {
+				if bindingType != OnStartup {
+					bindingTypes = append(bindingTypes, bindingType)
+				}
+			}
+			for _, newTask := range enableBindingsTasks(change.New, bindingTypes) {
+				mainQueue.AddLast(newTask)
+				logEntry.Infof("queue task %s for hook %s", newTask.GetDescription(), change.New.Name)
+			}
+		}
+	})
+
+	op.reloadWebhooks(reload)
+}
+
+// drainHookTasks removes tasks for bindings of the hook from all queues.
+func (op *ShellOperator) drainHookTasks(hookName string, bindingTypes []BindingType) {
+	drained := 0
+	op.TaskQueues.Iterate(func(q *queue.TaskQueue) {
+		q.Filter(func(t task.Task) bool {
+			hookMeta := HookMetadataAccessor(t)
+			if hookMeta.HookName != hookName {
+				return true
+			}
+			var bindingType BindingType
+			switch t.GetType() {
+			case EnableKubernetesBindings:
+				bindingType = OnKubernetesEvent
+			case EnableScheduleBindings:
+				bindingType = Schedule
+			default:
+				bindingType = hookMeta.BindingType
+			}
+			if hasBindingType(bindingTypes, bindingType) {
+				drained++
+				return false
+			}
+			return true
+		})
+	})
+	if drained > 0 {
+		log.WithField("operator.component", "hooksReload").
+			Infof("Drain %d tasks for hook '%s'", drained, hookName)
+	}
+}
+
+// reloadWebhooks enables webhooks for new bindings and updates webhook configurations.
+// Webhook managers are started if there were no webhook bindings before reload.
+func (op *ShellOperator) reloadWebhooks(reload *hook.HooksReload) {
+	logEntry := log.WithField("operator.component", "hooksReload")
+
+	// New hook instances with enabled webhooks.
+	enabled := map[BindingType][]*hook.Hook{}
+	changed := map[BindingType]bool{}
+	for _, bindingType := range []BindingType{KubernetesValidating, KubernetesConversion} {
+		for _, h := range reload.Removed {
+			changed[bindingType] = changed[bindingType] || h.Config.HasBinding(bindingType)
+		}
+		for _, h := range reload.Added {
+			if h.Config.HasBinding(bindingType) {
+				changed[bindingType] = true
+				enabled[bindingType] = append(enabled[bindingType], h)
+			}
+		}
+		for _, change := range reload.Changed {
+			if hasBindingType(change.ChangedBindings, bindingType) {
+				changed[bindingType] = true
+				enabled[bindingType] = append(enabled[bindingType], change.New)
+			}
+		}
+	}
+
+	if changed[KubernetesValidating] && op.ValidatingWebhookManager != nil {
+		var err error
+		if op.ValidatingWebhookManager.Server == nil {
+			err = op.InitValidatingWebhookManager()
+		} else {
+			for _, h := range enabled[KubernetesValidating] {
+				h.HookController.EnableValidatingBindings()
+			}
+			err = op.ValidatingWebhookManager.UpdateConfigurations()
+		}
+		if err != nil {
+			logEntry.Errorf("Update validating webhooks: %v", err)
+		}
+	}
+
+	if changed[KubernetesConversion] && op.ConversionWebhookManager != nil {
+		var err error
+		if op.ConversionWebhookManager.Server == nil {
+			err = op.InitConversionWebhookManager()
+		} else {
+			for _, h := range enabled[KubernetesConversion] {
+				h.HookController.EnableConversionBindings()
+			}
+			err = op.ConversionWebhookManager.UpdateClientConfigs()
+		}
+		if err != nil {
+			logEntry.Errorf("Update conversion webhooks: %v", err)
+		}
+	}
+}
+
+// disableHookBindings stops monitors, schedules and webhooks for binding types.
+func disableHookBindings(h *hook.Hook, bindingTypes []BindingType) {
+	for _, bindingType := range bindingTypes {
+		switch bindingType {
+		case OnKubernetesEvent:
+			h.HookController.StopMonitors()
+		case Schedule:
+			h.HookController.DisableScheduleBindings()
+		case KubernetesValidating:
+			h.HookController.DisableValidatingBindings()
+		case KubernetesConversion:
+			h.HookController.DisableConversionBindings()
+		}
+	}
+}
+
+// enableBindingsTasks returns tasks to run OnStartup and to enable kubernetes
+// and schedule bindings for the reloaded hook.
+func enableBindingsTasks(h *hook.Hook, bindingTypes []BindingType) []task.Task {
+	tasks := make([]task.Task, 0)
+
+	if hasBindingType(bindingTypes, OnStartup) && h.Config.HasBinding(OnStartup) {
+		bc := BindingContext{
+			Binding: string(OnStartup),
+		}
+		bc.Metadata.BindingType = OnStartup
+
+		tasks = append(tasks, task.NewTask(HookRun).
+			WithMetadata(HookMetadata{
+				HookName:       h.Name,
+				BindingType:    OnStartup,
+				BindingContext: []BindingContext{bc},
+			}).
+			WithQueuedAt(time.Now()))
+	}
+
+	if hasBindingType(bindingTypes, OnKubernetesEvent) && h.Config.HasBinding(OnKubernetesEvent) {
+		tasks = append(tasks, task.NewTask(EnableKubernetesBindings).
+			WithMetadata(HookMetadata{
+				HookName: h.Name,
+				Binding:  string(EnableKubernetesBindings),
+			}).
+			WithQueuedAt(time.Now()))
+	}
+
+	if hasBindingType(bindingTypes, Schedule) && h.Config.HasBinding(Schedule) {
+		tasks = append(tasks, task.NewTask(EnableScheduleBindings).
+			WithMetadata(HookMetadata{
+				HookName: h.Name,
+				Binding:  string(EnableScheduleBindings),
+			}).
+			WithQueuedAt(time.Now()))
+	}
+
+	return tasks
+}
+
+func hasBindingType(bindingTypes []BindingType, bindingType BindingType) bool {
+	for _, t := range bindingTypes {
+		if t == bindingType {
+			return true
+		}
+	}
+	return false
+}
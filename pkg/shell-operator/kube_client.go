@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	klient "github.com/flant/kube-client/client"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/flant/shell-operator/pkg/app"
 	"github.com/flant/shell-operator/pkg/kube/object_patch"
@@ -43,6 +45,26 @@ func InitDefaultMainKubeClient(metricStorage *metric_storage.MetricStorage) (kli
 	return kubeClient, nil
 }
 
+// InitDefaultMetadataClient creates a client for metadata-only watches. It uses the same
+// kubeconfig, context and rate limiter settings as the 'main' Kubernetes client.
+// In-cluster config is used if there is no kubeconfig.
+func InitDefaultMetadataClient() (metadata.Interface, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = app.KubeConfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: app.KubeContext}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("initialize Kubernetes metadata client: %s\n", err)
+	}
+	config.QPS = app.KubeClientQps
+	config.Burst = app.KubeClientBurst
+	client, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("initialize Kubernetes metadata client: %s\n", err)
+	}
+	return client, nil
+}
+
 // DefaultObjectPatcherKubeClient initializes a Kubernetes client for ObjectPatcher. Timeout is specified here.
 func DefaultObjectPatcherKubeClient(metricStorage *metric_storage.MetricStorage, metricLabels map[string]string) klient.Client {
 	client := klient.New()
@@ -56,6 +56,8 @@ func RegisterKubeEventsManagerMetrics(metricStorage *metric_storage.MetricStorag
 	metricStorage.RegisterGauge("{PREFIX}kube_snapshot_objects", labels)
 	// Size of snapshot in JSON format.
 	metricStorage.RegisterGauge("{PREFIX}kube_snapshot_bytes", labels)
+	// Count of objects received by the initial LIST, it grows page by page.
+	metricStorage.RegisterGauge("{PREFIX}kube_initial_list_objects", labels)
 	// Duration of jqFilter applying.
 	metricStorage.RegisterHistogram(
 		"{PREFIX}kube_jq_filter_duration_seconds",
@@ -139,8 +141,23 @@ func RegisterHookMetrics(metricStorage *metric_storage.MetricStorage) {
 	metricStorage.RegisterGauge("{PREFIX}hook_run_max_rss_bytes", labels)
 
 	metricStorage.RegisterCounter("{PREFIX}hook_run_errors_total", labels)
+	metricStorage.RegisterCounter("{PREFIX}hook_run_timeouts_total", labels)
+	metricStorage.RegisterCounter("{PREFIX}hook_run_limit_exceeded_total", map[string]string{
+		"hook":    "",
+		"binding": "",
+		"queue":   "",
+		"limit":   "",
+	})
 	metricStorage.RegisterCounter("{PREFIX}hook_run_allowed_errors_total", labels)
 	metricStorage.RegisterCounter("{PREFIX}hook_run_success_total", labels)
 	// hook_run task waiting time
 	metricStorage.RegisterCounter("{PREFIX}task_wait_in_queue_seconds_total", labels)
+
+	// Metrics for hooks reload.
+	metricStorage.RegisterCounter("{PREFIX}hooks_reload_total", map[string]string{})
+	metricStorage.RegisterCounter("{PREFIX}hooks_reload_errors_total", map[string]string{})
+	metricStorage.RegisterGauge("{PREFIX}hooks_invalid", map[string]string{})
+
+	// Hooks disabled at runtime.
+	metricStorage.RegisterGauge("{PREFIX}hook_disabled", map[string]string{"hook": ""})
 }
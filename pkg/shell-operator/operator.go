@@ -8,7 +8,9 @@ import (
 	klient "github.com/flant/kube-client/client"
 	log "github.com/sirupsen/logrus"
 	uuid "gopkg.in/satori/go.uuid.v1"
+	"k8s.io/client-go/metadata"
 
+	"github.com/flant/shell-operator/pkg/executor"
 	"github.com/flant/shell-operator/pkg/hook"
 	. "github.com/flant/shell-operator/pkg/hook/binding_context"
 	"github.com/flant/shell-operator/pkg/hook/controller"
@@ -38,7 +40,9 @@ type ShellOperator struct {
 	// separate metric storage for hook metrics if separate listen port is configured
 	HookMetricStorage *metric_storage.MetricStorage
 	KubeClient        klient.Client
-	ObjectPatcher     *object_patch.ObjectPatcher
+	// A client for kubernetes bindings with "metadata" watch mode.
+	MetadataClient metadata.Interface
+	ObjectPatcher  *object_patch.ObjectPatcher
 
 	ScheduleManager   schedule_manager.ScheduleManager
 	KubeEventsManager kube_events_manager.KubeEventsManager
@@ -76,6 +80,15 @@ func (op *ShellOperator) WithMetricStorage(metricStorage *metric_storage.MetricS
 	op.MetricStorage = metricStorage
 }
 
+// updateInvalidHooksMetric sets a number of hooks quarantined because of invalid configurations.
+func (op *ShellOperator) updateInvalidHooksMetric() {
+	if op.MetricStorage == nil {
+		return
+	}
+	invalidHooks := op.HookManager.GetInvalidHooks()
+	op.MetricStorage.GaugeSet("{PREFIX}hooks_invalid", float64(len(invalidHooks)), map[string]string{})
+}
+
 // InitHookManager load hooks from HooksDir and defines event handlers that emit tasks.
 func (op *ShellOperator) InitHookManager() (err error) {
 	if op.HookManager == nil {
@@ -87,37 +100,10 @@ func (op *ShellOperator) InitHookManager() (err error) {
 		log.Errorf("MAIN Fatal: initialize hook manager: %s\n", err)
 		return err
 	}
+	op.updateInvalidHooksMetric()
 
 	// Define event handlers for schedule event and kubernetes event.
-	op.ManagerEventsHandler.WithKubeEventHandler(func(kubeEvent KubeEvent) []task.Task {
-		logLabels := map[string]string{
-			"event.id": uuid.NewV4().String(),
-			"binding":  string(OnKubernetesEvent),
-		}
-		logEntry := log.WithFields(utils.LabelsToLogFields(logLabels))
-		logEntry.Debugf("Create tasks for 'kubernetes' event '%s'", kubeEvent.String())
-
-		var tasks []task.Task
-		op.HookManager.HandleKubeEvent(kubeEvent, func(hook *hook.Hook, info controller.BindingExecutionInfo) {
-			newTask := task.NewTask(HookRun).
-				WithMetadata(HookMetadata{
-					HookName:       hook.Name,
-					BindingType:    OnKubernetesEvent,
-					BindingContext: info.BindingContext,
-					AllowFailure:   info.AllowFailure,
-					Binding:        info.Binding,
-					Group:          info.Group,
-				}).
-				WithLogLabels(logLabels).
-				WithQueueName(info.QueueName)
-			tasks = append(tasks, newTask.WithQueuedAt(time.Now()))
-
-			logEntry.WithField("queue", info.QueueName).
-				Infof("queue task %s", newTask.GetDescription())
-		})
-
-		return tasks
-	})
+	op.ManagerEventsHandler.WithKubeEventHandler(op.kubeEventTasks)
 	op.ManagerEventsHandler.WithScheduleEventHandler(func(crontab string) []task.Task {
 		logLabels := map[string]string{
 			"event.id": uuid.NewV4().String(),
@@ -151,6 +137,43 @@ func (op *ShellOperator) InitHookManager() (err error) {
 	return nil
 }
 
+// kubeEventTasks returns tasks to run hooks for the kubernetes event.
+func (op *ShellOperator) kubeEventTasks(kubeEvent KubeEvent) []task.Task {
+	logLabels := map[string]string{
+		"event.id": uuid.NewV4().String(),
+		"binding":  string(OnKubernetesEvent),
+	}
+	logEntry := log.WithFields(utils.LabelsToLogFields(logLabels))
+	logEntry.Debugf("Create tasks for 'kubernetes' event '%s'", kubeEvent.String())
+
+	var tasks []task.Task
+	op.HookManager.HandleKubeEvent(kubeEvent, func(hook *hook.Hook, info controller.BindingExecutionInfo) {
+		// Synchronization from resynchronizationPeriod, from a monitor started after its CRD is created
+		// or for the enabled hook.
+		if kubeEvent.Type == TypeSynchronization && !info.KubernetesBinding.ExecuteHookOnSynchronization {
+			return
+		}
+		newTask := task.NewTask(HookRun).
+			WithMetadata(HookMetadata{
+				HookName:                 hook.Name,
+				BindingType:              OnKubernetesEvent,
+				BindingContext:           info.BindingContext,
+				AllowFailure:             info.AllowFailure,
+				Binding:                  info.Binding,
+				Group:                    info.Group,
+				ExecuteOnSynchronization: info.KubernetesBinding.ExecuteHookOnSynchronization,
+			}).
+			WithLogLabels(logLabels).
+			WithQueueName(info.QueueName)
+		tasks = append(tasks, newTask.WithQueuedAt(time.Now()))
+
+		logEntry.WithField("queue", info.QueueName).
+			Infof("queue task %s", newTask.GetDescription())
+	})
+
+	return tasks
+}
+
 // InitValidatingWebhookManager adds kubernetesValidating hooks
 // to a WebhookManager and set a validating event handler.
 func (op *ShellOperator) InitValidatingWebhookManager() (err error) {
@@ -379,6 +402,9 @@ func (op *ShellOperator) Start() {
 
 	// Unlike KubeEventsManager, ScheduleManager has one go-routine.
 	op.ScheduleManager.Start()
+
+	// Watch for changes in the hooks directory.
+	op.RunHooksReload()
 }
 
 // TaskHandler
@@ -387,12 +413,22 @@ func (op *ShellOperator) TaskHandler(t task.Task) queue.TaskResult {
 	var hookMeta = HookMetadataAccessor(t)
 	var res queue.TaskResult
 
+	// Tasks for removed hooks can be queued before hooks reload. The hook is looked up
+	// once: a reload from the hooks directory watcher can remove it while the task runs.
+	taskHook := op.HookManager.LookupHook(hookMeta.HookName)
+	if taskHook == nil {
+		logEntry.WithField("hook", hookMeta.HookName).
+			Warnf("Skip task %s: hook is removed", t.GetDescription())
+		res.Status = "Success"
+		return res
+	}
+
 	switch t.GetType() {
 	case HookRun:
-		res = op.TaskHandleHookRun(t)
+		res = op.TaskHandleHookRun(t, taskHook)
 
 	case EnableKubernetesBindings:
-		res = op.TaskHandleEnableKubernetesBindings(t)
+		res = op.TaskHandleEnableKubernetesBindings(t, taskHook)
 
 	case EnableScheduleBindings:
 		hookLogLabels := map[string]string{}
@@ -403,7 +439,6 @@ func (op *ShellOperator) TaskHandler(t task.Task) queue.TaskResult {
 
 		taskLogEntry := logEntry.WithFields(utils.LabelsToLogFields(hookLogLabels))
 
-		taskHook := op.HookManager.GetHook(hookMeta.HookName)
 		taskHook.HookController.EnableScheduleBindings()
 		taskLogEntry.Infof("Schedule binding for hook enabled successfully")
 		res.Status = "Success"
@@ -413,7 +448,7 @@ func (op *ShellOperator) TaskHandler(t task.Task) queue.TaskResult {
 }
 
 // TaskHandleEnableKubernetesBindings creates task for each Kubernetes binding in the hook and queues them.
-func (op *ShellOperator) TaskHandleEnableKubernetesBindings(t task.Task) queue.TaskResult {
+func (op *ShellOperator) TaskHandleEnableKubernetesBindings(t task.Task, taskHook *hook.Hook) queue.TaskResult {
 	var hookMeta = HookMetadataAccessor(t)
 
 	metricLabels := map[string]string{
@@ -434,8 +469,6 @@ func (op *ShellOperator) TaskHandleEnableKubernetesBindings(t task.Task) queue.T
 
 	taskLogEntry.Info("Enable kubernetes binding for hook")
 
-	taskHook := op.HookManager.GetHook(hookMeta.HookName)
-
 	hookRunTasks := []task.Task{}
 
 	// Run hook for each binding with Synchronization binding context. Ignore queue name here, execute in main queue.
@@ -481,11 +514,10 @@ func (op *ShellOperator) TaskHandleEnableKubernetesBindings(t task.Task) queue.T
 }
 
 // TODO use Context to pass labels and a queue name
-func (op *ShellOperator) TaskHandleHookRun(t task.Task) queue.TaskResult {
+func (op *ShellOperator) TaskHandleHookRun(t task.Task, taskHook *hook.Hook) queue.TaskResult {
 	var hookMeta = HookMetadataAccessor(t)
-	taskHook := op.HookManager.GetHook(hookMeta.HookName)
 
-	err := taskHook.RateLimitWait(context.Background())
+	err := taskHook.RateLimitWait(context.Background(), hookMeta.BindingType, hookMeta.Binding)
 	if err != nil {
 		// This could happen when the Context is canceled, so just repeat the task until the queue is stopped.
 		return queue.TaskResult{
@@ -527,7 +559,7 @@ func (op *ShellOperator) TaskHandleHookRun(t task.Task) queue.TaskResult {
 		}
 	}
 
-	if shouldRunHook && taskHook.Config.Version == "v1" {
+	if shouldRunHook && taskHook.Config.Version != "v0" {
 		// Do not combine Synchronization with Event
 		shouldCombine := true
 		if hookMeta.BindingType == OnKubernetesEvent {
@@ -559,7 +591,18 @@ func (op *ShellOperator) TaskHandleHookRun(t task.Task) queue.TaskResult {
 		success := 0.0
 		errors := 0.0
 		allowed := 0.0
+		timeouts := 0.0
 		err = op.HandleRunHook(t, taskHook, hookMeta, taskLogEntry, hookLogLabels, metricLabels)
+		if executor.IsTimeout(err) {
+			timeouts = 1.0
+		}
+		if limit := executor.ExceededLimit(err); limit != "" {
+			limitLabels := map[string]string{"limit": limit}
+			for k, v := range metricLabels {
+				limitLabels[k] = v
+			}
+			op.MetricStorage.CounterAdd("{PREFIX}hook_run_limit_exceeded_total", 1.0, limitLabels)
+		}
 		if err != nil {
 			if hookMeta.AllowFailure {
 				allowed = 1.0
@@ -567,7 +610,7 @@ func (op *ShellOperator) TaskHandleHookRun(t task.Task) queue.TaskResult {
 				res.Status = "Success"
 			} else {
 				errors = 1.0
-				t.UpdateFailureMessage(err.Error())
+				t.UpdateFailureMessage(hook.FailureMessage(err))
 				t.WithQueuedAt(time.Now()) // Reset queueAt for correct results in 'task_wait_in_queue' metric.
 				taskLogEntry.Errorf("Hook failed. Will retry after delay. Failed count is %d. Error: %s", t.GetFailureCount()+1, err)
 				res.Status = "Fail"
@@ -579,6 +622,7 @@ func (op *ShellOperator) TaskHandleHookRun(t task.Task) queue.TaskResult {
 		}
 		op.MetricStorage.CounterAdd("{PREFIX}hook_run_allowed_errors_total", allowed, metricLabels)
 		op.MetricStorage.CounterAdd("{PREFIX}hook_run_errors_total", errors, metricLabels)
+		op.MetricStorage.CounterAdd("{PREFIX}hook_run_timeouts_total", timeouts, metricLabels)
 		op.MetricStorage.CounterAdd("{PREFIX}hook_run_success_total", success, metricLabels)
 	}
 
@@ -598,7 +642,11 @@ func (op *ShellOperator) HandleRunHook(t task.Task, taskHook *hook.Hook, hookMet
 		taskLogEntry.Debugf("snapshot info: %s", info)
 	}
 
-	result, err := taskHook.Run(hookMeta.BindingType, hookMeta.BindingContext, hookLogLabels)
+	result, err := taskHook.Run(hookMeta.BindingType, hookMeta.BindingContext, hookLogLabels, hook.TaskInfo{
+		Queue:        t.GetQueueName(),
+		TaskId:       t.GetId(),
+		FailureCount: t.GetFailureCount(),
+	})
 	if err != nil {
 		return err
 	}
@@ -767,7 +815,8 @@ func (op *ShellOperator) BootstrapMainQueue(tqs *queue.TaskQueueSet) {
 
 	mainQueue := tqs.GetMain()
 
-	// Add tasks to run OnStartup bindings
+	// Add tasks to run OnStartup bindings. Hooks are sorted by onStartup
+	// order and settings.dependsOn.
 	onStartupHooks, err := op.HookManager.GetHooksInOrder(OnStartup)
 	if err != nil {
 		logEntry.Errorf("%v", err)
@@ -791,7 +840,9 @@ func (op *ShellOperator) BootstrapMainQueue(tqs *queue.TaskQueueSet) {
 		logEntry.Infof("queue task %s with hook %s", newTask.GetDescription(), hookName)
 	}
 
-	// Add tasks to enable kubernetes monitors and schedules for each hook
+	// Add tasks to enable kubernetes monitors and schedules for each hook.
+	// Hooks are sorted by settings.dependsOn, so Synchronization of the hook
+	// runs after Synchronization of hooks it depends on (with waitForSynchronization).
 	for _, hookName := range op.HookManager.GetHookNames() {
 		h := op.HookManager.GetHook(hookName)
 
@@ -877,4 +928,8 @@ func (op *ShellOperator) Shutdown() {
 	op.TaskQueues.Stop()
 	// Wait for queues to stop, but no more than 10 seconds
 	op.TaskQueues.WaitStopWithTimeout(WaitQueuesTimeout)
+	// Stop long-running hook workers.
+	for _, hookName := range op.HookManager.GetHookNames() {
+		op.HookManager.GetHook(hookName).StopWorker()
+	}
 }
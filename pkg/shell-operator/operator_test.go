@@ -2,6 +2,9 @@ package shell_operator
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -9,6 +12,7 @@ import (
 	. "github.com/flant/shell-operator/pkg/hook/task_metadata"
 	. "github.com/flant/shell-operator/pkg/hook/types"
 	"github.com/flant/shell-operator/pkg/task"
+	"github.com/flant/shell-operator/pkg/task/queue"
 )
 
 func Test_Operator_startup_tasks(t *testing.T) {
@@ -61,3 +65,38 @@ func Test_Operator_startup_tasks(t *testing.T) {
 		i++
 	})
 }
+
+func Test_Operator_TaskHandler_removed_hook(t *testing.T) {
+	g := NewWithT(t)
+
+	hooksDir := t.TempDir()
+	for _, name := range []string{"hook01.sh", "hook02.sh"} {
+		script := "#!/usr/bin/env bash\nif [[ $1 == \"--config\" ]] ; then\necho '{\"configVersion\":\"v1\", \"onStartup\": 1}'\nfi\n"
+		err := ioutil.WriteFile(filepath.Join(hooksDir, name), []byte(script), 0755)
+		g.Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	op := NewShellOperator()
+	op.WithContext(context.Background())
+	SetupEventManagers(op)
+	SetupHookManagers(op, hooksDir, t.TempDir())
+
+	err := op.InitHookManager()
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	op.BootstrapMainQueue(op.TaskQueues)
+	head := op.TaskQueues.GetMain().GetFirst()
+	g.Expect(head).ShouldNot(BeNil())
+	g.Expect(HookMetadataAccessor(head).HookName).To(Equal("hook01.sh"))
+
+	// The hook is removed while its task is at the head of the queue: hook indices
+	// are already updated, but the task is not drained yet.
+	g.Expect(os.Remove(filepath.Join(hooksDir, "hook01.sh"))).To(Succeed())
+	reload, err := op.HookManager.Reload()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(reload.Removed).To(HaveLen(1))
+
+	var res queue.TaskResult
+	g.Expect(func() { res = op.TaskHandler(head) }).ShouldNot(Panic())
+	g.Expect(res.Status).To(Equal(queue.Success))
+}
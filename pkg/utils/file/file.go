@@ -33,6 +33,22 @@ func IsFileExecutable(f os.FileInfo) bool {
 	return f.Mode()&0111 != 0
 }
 
+// StaticConfigExtensions are extensions of sidecar config files:
+// a config for 'hooks/pods.sh' is 'hooks/pods.sh.yaml' or 'hooks/pods.sh.json'.
+var StaticConfigExtensions = []string{".yaml", ".json"}
+
+// IsStaticConfigFile returns true if the file is a sidecar config of an existing file.
+func IsStaticConfigFile(path string) bool {
+	for _, ext := range StaticConfigExtensions {
+		if !strings.HasSuffix(path, ext) {
+			continue
+		}
+		stat, err := os.Stat(strings.TrimSuffix(path, ext))
+		return err == nil && !stat.IsDir()
+	}
+	return false
+}
+
 // RecursiveGetExecutablePaths finds recursively all executable files
 // inside a dir directory. Hidden directories and files are ignored.
 func RecursiveGetExecutablePaths(dir string) ([]string, error) {
@@ -56,7 +72,17 @@ func RecursiveGetExecutablePaths(dir string) ([]string, error) {
 			return nil
 		}
 
+		// Sidecar configs are skipped regardless of mode: files from ConfigMap volumes
+		// are symlinks or can be mounted with the executable mode.
+		if IsStaticConfigFile(path) {
+			return nil
+		}
+
 		if !IsFileExecutable(f) {
+			// Do not warn about static configs for hooks.
+			if ext := filepath.Ext(f.Name()); ext == ".yaml" || ext == ".json" {
+				return nil
+			}
 			log.Warnf("File '%s' is skipped: no executable permissions, chmod +x is required to run this hook", path)
 			return nil
 		}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_RecursiveGetExecutablePaths_sidecar_configs(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := t.TempDir()
+
+	writeFile := func(path string, mode os.FileMode) {
+		if err := ioutil.WriteFile(path, []byte("{}"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(filepath.Join(dir, "a.sh"), 0755)
+	// Sidecar config mounted with the executable mode.
+	writeFile(filepath.Join(dir, "a.sh.yaml"), 0755)
+	writeFile(filepath.Join(dir, "b.sh"), 0755)
+	// Sidecar config from a ConfigMap volume is a symlink.
+	writeFile(filepath.Join(dataDir, "b.sh.json"), 0644)
+	if err := os.Symlink(filepath.Join(dataDir, "b.sh.json"), filepath.Join(dir, "b.sh.json")); err != nil {
+		t.Fatal(err)
+	}
+	// Executable without a sibling hook is a hook.
+	writeFile(filepath.Join(dir, "c.yaml"), 0755)
+
+	paths, err := RecursiveGetExecutablePaths(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "a.sh"),
+		filepath.Join(dir, "b.sh"),
+		filepath.Join(dir, "c.yaml"),
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, paths)
+	}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, paths)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package conversion
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/hashicorp/go-multierror"
+
 	"github.com/flant/shell-operator/pkg/utils/string_helper"
 )
 
@@ -250,3 +254,42 @@ func VersionsMatched(v0, v1 string) bool {
 	}
 	return false
 }
+
+// Versions returns sorted versions from base paths of the chain.
+func (c Chain) Versions() []string {
+	versions := map[string]struct{}{}
+	for fromVer := range c.BaseFromToIndex {
+		versions[fromVer] = struct{}{}
+		for toVer := range c.BaseFromToIndex[fromVer] {
+			versions[toVer] = struct{}{}
+		}
+	}
+	res := make([]string, 0, len(versions))
+	for ver := range versions {
+		res = append(res, ver)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// CheckChain returns an error for every pair of versions of the CRD that
+// cannot be converted to each other with the base paths.
+func (cs ChainStorage) CheckChain(crdName string) (allErr error) {
+	chain, ok := cs.Chains[crdName]
+	if !ok {
+		return nil
+	}
+	versions := chain.Versions()
+	for _, fromVer := range versions {
+		for _, toVer := range versions {
+			if VersionsMatched(fromVer, toVer) {
+				continue
+			}
+			rule := Rule{FromVersion: fromVer, ToVersion: toVer}
+			if len(cs.FindConversionChain(crdName, rule)) == 0 {
+				allErr = multierror.Append(allErr, fmt.Errorf("crd '%s': no conversion path from '%s' to '%s'", crdName, fromVer, toVer))
+			}
+		}
+	}
+	return allErr
+}
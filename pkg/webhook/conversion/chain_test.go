@@ -38,3 +38,26 @@ func Test_VersionsMatched(t *testing.T) {
 	res = VersionsMatched(v0, v1)
 	g.Expect(res).Should(BeFalse(), "Expect that '%s' is not matching '%s'.")
 }
+
+func Test_ChainStorage_CheckChain(t *testing.T) {
+	g := NewWithT(t)
+
+	cs := NewChainStorage()
+	chain := cs.Get("crontabs.stable.example.com")
+	chain.Put(Rule{FromVersion: "v1alpha1", ToVersion: "v1beta1"})
+	chain.Put(Rule{FromVersion: "v1beta1", ToVersion: "v1alpha1"})
+	chain.Put(Rule{FromVersion: "v1beta1", ToVersion: "v1"})
+	chain.Put(Rule{FromVersion: "v1", ToVersion: "v1beta1"})
+
+	g.Expect(chain.Versions()).To(Equal([]string{"v1", "v1alpha1", "v1beta1"}))
+	g.Expect(cs.CheckChain("crontabs.stable.example.com")).Should(Succeed())
+
+	// There is no way back to v1alpha1.
+	cs = NewChainStorage()
+	chain = cs.Get("crontabs.stable.example.com")
+	chain.Put(Rule{FromVersion: "v1alpha1", ToVersion: "v1"})
+
+	err := cs.CheckChain("crontabs.stable.example.com")
+	g.Expect(err).Should(HaveOccurred())
+	g.Expect(err.Error()).Should(ContainSubstring("no conversion path from 'v1' to 'v1alpha1'"))
+}
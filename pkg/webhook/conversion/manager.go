@@ -72,8 +72,14 @@ func (m *WebhookManager) Start() error {
 		return err
 	}
 
+	return m.UpdateClientConfigs()
+}
+
+// UpdateClientConfigs updates spec.conversion in all registered CRDs.
+// It is also used to register CRDs for webhooks added by reloaded hooks.
+func (m *WebhookManager) UpdateClientConfigs() error {
 	for _, clientCfg := range m.ClientConfigs {
-		err = clientCfg.Update()
+		err := clientCfg.Update()
 		if err != nil {
 			return err
 		}
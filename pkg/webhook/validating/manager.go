@@ -19,10 +19,12 @@ const DefaultConfigurationId = "hooks"
 
 // WebhookManager is a public interface to be used from operator.go.
 //
-// No dynamic configuration for now. The steps are:
+// The steps are:
 //   - Init manager
 //   - Call AddWEbhook for every binding in hooks
 //   - Start() to run server and create ValidatingWebhookConfiguration
+//   - Call AddWebhook and RemoveWebhook and then UpdateConfigurations()
+//     to apply changes in reloaded hooks
 type WebhookManager struct {
 	KubeClient klient.Client
 
@@ -105,6 +107,16 @@ func (m *WebhookManager) AddWebhook(config *ValidatingWebhookConfig) {
 	r.AddWebhook(config)
 }
 
+func (m *WebhookManager) RemoveWebhook(config *ValidatingWebhookConfig) {
+	confId := config.Metadata.ConfigurationId
+	if confId == "" {
+		confId = m.DefaultConfigurationId
+	}
+	if r, ok := m.Resources[confId]; ok {
+		r.RemoveWebhook(config)
+	}
+}
+
 func (m *WebhookManager) Start() error {
 	err := m.Server.Start()
 	if err != nil {
@@ -120,3 +132,16 @@ func (m *WebhookManager) Start() error {
 
 	return nil
 }
+
+// UpdateConfigurations updates ValidatingWebhookConfigurations after webhooks
+// are added or removed in the started manager.
+func (m *WebhookManager) UpdateConfigurations() error {
+	for _, r := range m.Resources {
+		err := r.CreateConfiguration()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
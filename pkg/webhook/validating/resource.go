@@ -31,6 +31,10 @@ func (w *WebhookResource) AddWebhook(config *ValidatingWebhookConfig) {
 	w.Webhooks[config.Metadata.WebhookId] = config
 }
 
+func (w *WebhookResource) RemoveWebhook(config *ValidatingWebhookConfig) {
+	delete(w.Webhooks, config.Metadata.WebhookId)
+}
+
 func (w *WebhookResource) CreateConfiguration() error {
 	equivalent := v1.Equivalent
 
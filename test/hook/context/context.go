@@ -0,0 +1,47 @@
+// Package context is the Binding Context Generator for hooks tests.
+// The generator is implemented in pkg/hook/context_generator.
+package context
+
+import (
+	"github.com/flant/kube-client/fake"
+
+	"github.com/flant/shell-operator/pkg/hook/binding_context"
+	"github.com/flant/shell-operator/pkg/hook/context_generator"
+	kubeeventsmanager "github.com/flant/shell-operator/pkg/kube_events_manager"
+)
+
+const (
+	TestTaskType  = context_generator.TestTaskType
+	TestQueueName = context_generator.TestQueueName
+)
+
+type (
+	GeneratedBindingContexts = context_generator.GeneratedBindingContexts
+	BindingContextController = context_generator.BindingContextController
+	StateController          = context_generator.StateController
+	ContextCombiner          = context_generator.ContextCombiner
+	GeneratedStep            = context_generator.GeneratedStep
+	GenerateOptions          = context_generator.GenerateOptions
+	NamedState               = context_generator.NamedState
+)
+
+func NewBindingContextController(config string, version ...fake.ClusterVersion) *BindingContextController {
+	return context_generator.NewBindingContextController(config, version...)
+}
+
+func NewStateController(fc *fake.Cluster, ev kubeeventsmanager.KubeEventsManager) *StateController {
+	return context_generator.NewStateController(fc, ev)
+}
+
+func NewContextCombiner() *ContextCombiner {
+	return context_generator.NewContextCombiner()
+}
+
+func ConvertToGeneratedBindingContexts(bindingContexts []binding_context.BindingContext) (GeneratedBindingContexts, error) {
+	return context_generator.ConvertToGeneratedBindingContexts(bindingContexts)
+}
+
+// Generate returns binding contexts for each step. See context_generator.Generate.
+func Generate(config string, opts GenerateOptions) ([]GeneratedStep, error) {
+	return context_generator.Generate(config, opts)
+}